@@ -0,0 +1,96 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+
+// Package client is a typed Go client for the AdGuard Home control API.
+//
+// A Client talks to one server described by a common.ServerConfig. Every
+// method takes a context.Context so callers can cancel in-flight requests.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+// Client talks to a single AdGuard Home server
+type Client struct {
+	server  *common.ServerConfig
+	baseURL url.URL
+	timeout time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout bounds every request the client makes. Zero means no timeout
+// beyond whatever deadline the caller's context carries.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// New returns a Client for server. A nil server uses the legacy viper config.
+func New(server *common.ServerConfig, opts ...Option) (*Client, error) {
+	baseURL, err := common.GetBaseURL(server)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{server: server, baseURL: baseURL}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// Server returns the server configuration the client was built from
+func (c *Client) Server() *common.ServerConfig {
+	return c.server
+}
+
+// do sends a request to path and, if out is non-nil, decodes the JSON response into it
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// the base URL may carry a path prefix when AdGuard Home is behind a reverse proxy
+	u := c.baseURL
+	u.Path = c.baseURL.Path + path
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	ca := common.CommandArgs{
+		Method:      method,
+		URL:         u,
+		RequestBody: body,
+		Server:      c.server,
+	}
+
+	resp, err := common.SendCommandContext(ctx, ca)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(resp, out); err != nil {
+		return fmt.Errorf("error unmarshaling %s response: %w", path, err)
+	}
+
+	return nil
+}
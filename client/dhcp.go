@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+)
+
+// DHCPStatus is the response from /control/dhcp/status
+type DHCPStatus struct {
+	Enabled       bool           `json:"enabled"`
+	InterfaceName string         `json:"interface_name"`
+	V4            V4Config       `json:"v4"`
+	V6            V6Config       `json:"v6"`
+	Leases        []LeaseDynamic `json:"leases"`
+	StaticLeases  []LeaseStatic  `json:"static_leases"`
+}
+
+// DHCPConfig is the body for /control/dhcp/set_config
+type DHCPConfig struct {
+	Enabled       bool     `json:"enabled"`
+	InterfaceName string   `json:"interface_name"`
+	V4            V4Config `json:"v4"`
+	V6            V6Config `json:"v6"`
+}
+
+type V4Config struct {
+	GatewayIP     string `json:"gateway_ip"`
+	SubnetMask    string `json:"subnet_mask"`
+	RangeStart    string `json:"range_start"`
+	RangeEnd      string `json:"range_end"`
+	LeaseDuration uint32 `json:"lease_duration"`
+}
+
+type V6Config struct {
+	RangeStart    string `json:"range_start"`
+	LeaseDuration uint32 `json:"lease_duration"`
+}
+
+type LeaseDynamic struct {
+	IP       string `json:"ip"`
+	MAC      string `json:"mac"`
+	Hostname string `json:"hostname"`
+	Expires  string `json:"expires"`
+}
+
+type LeaseStatic struct {
+	IP       string `json:"ip"`
+	MAC      string `json:"mac"`
+	Hostname string `json:"hostname"`
+}
+
+// DHCPCheckResponse is the response from /control/dhcp/find_active_dhcp
+type DHCPCheckResponse struct {
+	V4 DHCPCheckV4 `json:"v4"`
+	V6 DHCPCheckV6 `json:"v6"`
+}
+
+type DHCPCheckV4 struct {
+	OtherServer DHCPCheckOtherServer `json:"other_server"`
+	StaticIP    DHCPCheckStaticIP    `json:"static_ip"`
+}
+
+type DHCPCheckV6 struct {
+	OtherServer DHCPCheckOtherServer `json:"other_server"`
+}
+
+type DHCPCheckOtherServer struct {
+	Found string `json:"found"`
+	Error string `json:"error,omitempty"`
+}
+
+type DHCPCheckStaticIP struct {
+	Static string `json:"static"`
+	IP     string `json:"ip,omitempty"`
+}
+
+// DHCPStatus gets DHCP configuration and leases
+func (c *Client) DHCPStatus(ctx context.Context) (DHCPStatus, error) {
+	var ret DHCPStatus
+	err := c.do(ctx, "GET", "/control/dhcp/status", nil, nil, &ret)
+	return ret, err
+}
+
+// FindActiveDHCP checks for other DHCP servers on an interface
+func (c *Client) FindActiveDHCP(ctx context.Context, interfaceName string) (DHCPCheckResponse, error) {
+	var ret DHCPCheckResponse
+	req := map[string]any{"interface": interfaceName}
+	err := c.do(ctx, "POST", "/control/dhcp/find_active_dhcp", nil, req, &ret)
+	return ret, err
+}
+
+// SetDHCPConfig replaces the DHCP server configuration
+func (c *Client) SetDHCPConfig(ctx context.Context, config DHCPConfig) error {
+	return c.do(ctx, "POST", "/control/dhcp/set_config", nil, config, nil)
+}
+
+// ResetDHCP resets the DHCP configuration to defaults
+func (c *Client) ResetDHCP(ctx context.Context) error {
+	return c.do(ctx, "POST", "/control/dhcp/reset", nil, nil, nil)
+}
+
+// ResetDHCPLeases clears all dynamic leases
+func (c *Client) ResetDHCPLeases(ctx context.Context) error {
+	return c.do(ctx, "POST", "/control/dhcp/reset_leases", nil, nil, nil)
+}
+
+// AddStaticLease adds a static lease
+func (c *Client) AddStaticLease(ctx context.Context, lease LeaseStatic) error {
+	return c.do(ctx, "POST", "/control/dhcp/add_static_lease", nil, lease, nil)
+}
+
+// RemoveStaticLease removes a static lease. Only the non-empty fields of lease are sent.
+func (c *Client) RemoveStaticLease(ctx context.Context, lease LeaseStatic) error {
+	return c.do(ctx, "POST", "/control/dhcp/remove_static_lease", nil, leaseFields(lease), nil)
+}
+
+// UpdateStaticLease updates the static lease with lease.IP. Empty MAC or
+// hostname fields are left unchanged.
+func (c *Client) UpdateStaticLease(ctx context.Context, lease LeaseStatic) error {
+	req := leaseFields(lease)
+	req["ip"] = lease.IP
+	return c.do(ctx, "POST", "/control/dhcp/update_static_lease", nil, req, nil)
+}
+
+// leaseFields turns a lease into a request body with only the fields that are set
+func leaseFields(lease LeaseStatic) map[string]any {
+	ret := make(map[string]any)
+	if lease.IP != "" {
+		ret["ip"] = lease.IP
+	}
+	if lease.MAC != "" {
+		ret["mac"] = lease.MAC
+	}
+	if lease.Hostname != "" {
+		ret["hostname"] = lease.Hostname
+	}
+	return ret
+}
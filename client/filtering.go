@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"net/url"
+)
+
+// CheckHostRule is one rule matched by /control/filtering/check_host
+type CheckHostRule struct {
+	Text         string `json:"text"`
+	FilterListID int64  `json:"filter_list_id"`
+}
+
+// CheckHostResult is the response from /control/filtering/check_host
+type CheckHostResult struct {
+	Reason      string          `json:"reason"`
+	Rule        string          `json:"rule"`
+	Rules       []CheckHostRule `json:"rules"`
+	ServiceName string          `json:"service_name"`
+	CNAME       string          `json:"cname"`
+	IPAddrs     []string        `json:"ip_addrs"`
+	FilterID    int64           `json:"filter_id"`
+}
+
+// CheckHostParams are the query parameters for /control/filtering/check_host.
+// Empty fields are not sent.
+type CheckHostParams struct {
+	Name string
+	// Client is the IP address or ClientID to check as, so its own settings apply
+	Client string
+	// QType is the DNS record type to check, A if empty
+	QType string
+}
+
+// CheckHost checks whether a name would be filtered and by which rules
+func (c *Client) CheckHost(ctx context.Context, params CheckHostParams) (CheckHostResult, error) {
+	var ret CheckHostResult
+
+	query := url.Values{}
+	query.Add("name", params.Name)
+	if params.Client != "" {
+		query.Add("client", params.Client)
+	}
+	if params.QType != "" {
+		query.Add("qtype", params.QType)
+	}
+
+	err := c.do(ctx, "GET", "/control/filtering/check_host", query, nil, &ret)
+	return ret, err
+}
+
+// Filter is one block or allow list
+type Filter struct {
+	Enabled     bool   `json:"enabled"`
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	RulesCount  uint32 `json:"rules_count"`
+	URL         string `json:"url"`
+	LastUpdated string `json:"last_updated,omitempty"`
+}
+
+// FilteringStatus is the response from /control/filtering/status
+type FilteringStatus struct {
+	Enabled bool `json:"enabled"`
+	// Interval is how often lists are updated, in hours
+	Interval         uint32   `json:"interval"`
+	Filters          []Filter `json:"filters"`
+	WhitelistFilters []Filter `json:"whitelist_filters"`
+	UserRules        []string `json:"user_rules"`
+}
+
+// FilterURLData is what /control/filtering/set_url changes about a list
+type FilterURLData struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Enabled bool   `json:"enabled"`
+}
+
+type addFilterURL struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Whitelist bool   `json:"whitelist"`
+}
+
+type removeFilterURL struct {
+	URL       string `json:"url"`
+	Whitelist bool   `json:"whitelist"`
+}
+
+type setFilterURL struct {
+	URL       string        `json:"url"`
+	Whitelist bool          `json:"whitelist"`
+	Data      FilterURLData `json:"data"`
+}
+
+type refreshFilters struct {
+	Whitelist bool `json:"whitelist"`
+}
+
+type refreshFiltersResult struct {
+	Updated int `json:"updated"`
+}
+
+// FilteringStatus gets the block and allow lists and the user rules
+func (c *Client) FilteringStatus(ctx context.Context) (FilteringStatus, error) {
+	var ret FilteringStatus
+	err := c.do(ctx, "GET", "/control/filtering/status", nil, nil, &ret)
+	return ret, err
+}
+
+// AddFilterURL adds a block list, or an allow list if whitelist is set
+func (c *Client) AddFilterURL(ctx context.Context, name string, listURL string, whitelist bool) error {
+	return c.do(ctx, "POST", "/control/filtering/add_url", nil, addFilterURL{Name: name, URL: listURL, Whitelist: whitelist}, nil)
+}
+
+// RemoveFilterURL removes the block or allow list with this URL
+func (c *Client) RemoveFilterURL(ctx context.Context, listURL string, whitelist bool) error {
+	return c.do(ctx, "POST", "/control/filtering/remove_url", nil, removeFilterURL{URL: listURL, Whitelist: whitelist}, nil)
+}
+
+// SetFilterURL changes the name, URL or enabled state of the list with this URL
+func (c *Client) SetFilterURL(ctx context.Context, listURL string, whitelist bool, data FilterURLData) error {
+	return c.do(ctx, "POST", "/control/filtering/set_url", nil, setFilterURL{URL: listURL, Whitelist: whitelist, Data: data}, nil)
+}
+
+// RefreshFilters downloads the block lists, or the allow lists if whitelist
+// is set, and says how many of them changed
+func (c *Client) RefreshFilters(ctx context.Context, whitelist bool) (int, error) {
+	var ret refreshFiltersResult
+	err := c.do(ctx, "POST", "/control/filtering/refresh", nil, refreshFilters{Whitelist: whitelist}, &ret)
+	return ret.Updated, err
+}
+
+type setRules struct {
+	Rules []string `json:"rules"`
+}
+
+// SetRules replaces the user rules
+func (c *Client) SetRules(ctx context.Context, rules []string) error {
+	return c.do(ctx, "POST", "/control/filtering/set_rules", nil, setRules{Rules: rules}, nil)
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+// QueryLogParams are the query parameters for /control/querylog.
+// Empty fields are not sent.
+type QueryLogParams struct {
+	Limit          string
+	OlderThan      string
+	ResponseStatus string
+	Search         string
+}
+
+// QueryLog is the response from /control/querylog
+type QueryLog struct {
+	Data   []LogEntry `json:"data"`
+	Oldest string     `json:"oldest"`
+}
+
+// LogEntry is one entry in the query log
+type LogEntry struct {
+	Answer         []DNSAnswer     `json:"answer,omitempty"`
+	OriginalAnswer []DNSAnswer     `json:"original_answer,omitempty"`
+	AnswerDNSSEC   bool            `json:"answer_dnssec"`
+	Cached         bool            `json:"cached"`
+	Client         string          `json:"client"`
+	ClientID       string          `json:"client_id,omitempty"`
+	ClientInfo     *LogClientInfo  `json:"client_info,omitempty"`
+	ClientProto    string          `json:"client_proto"`
+	ECS            string          `json:"ecs,omitempty"`
+	ElapsedMs      string          `json:"elapsedMs"`
+	Question       LogQuestion     `json:"question"`
+	Reason         string          `json:"reason"`
+	Rules          []CheckHostRule `json:"rules"`
+	ServiceName    string          `json:"service_name,omitempty"`
+	Status         string          `json:"status"`
+	Time           string          `json:"time"`
+	Upstream       string          `json:"upstream"`
+}
+
+// At is when the query arrived
+func (e LogEntry) At() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, e.Time)
+}
+
+// Elapsed is how long AdGuard Home took to answer, or 0 if it didn't say
+func (e LogEntry) Elapsed() time.Duration {
+	ms, err := strconv.ParseFloat(e.ElapsedMs, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// ClientAddr is the client's IP address. Its ok is false if the client isn't an IP.
+func (e LogEntry) ClientAddr() (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(e.Client)
+	return addr.Unmap(), err == nil
+}
+
+// Blocked reports whether AdGuard Home blocked the query rather than answering it
+func (e LogEntry) Blocked() bool {
+	switch e.Reason {
+	case "FilteredBlackList", "FilteredSafeBrowsing", "FilteredParental",
+		"FilteredInvalid", "FilteredBlockedService":
+		return true
+	}
+	return false
+}
+
+// DNSAnswer is one resource record in a query log answer
+type DNSAnswer struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+	TTL   uint32 `json:"ttl"`
+}
+
+// LogQuestion is the DNS question of a query log entry
+type LogQuestion struct {
+	Class       string `json:"class"`
+	Name        string `json:"name"`
+	UnicodeName string `json:"unicode_name,omitempty"`
+	Type        string `json:"type"`
+}
+
+// LogClientInfo is what AdGuard Home knows about the client of a query
+type LogClientInfo struct {
+	Whois          map[string]string `json:"whois"`
+	Name           string            `json:"name"`
+	DisallowedRule string            `json:"disallowed_rule"`
+	Disallowed     bool              `json:"disallowed"`
+}
+
+// QueryLog fetches query log entries
+func (c *Client) QueryLog(ctx context.Context, params QueryLogParams) (QueryLog, error) {
+	var ret QueryLog
+
+	query := url.Values{}
+	if params.Limit != "" {
+		query.Add("limit", params.Limit)
+	}
+	if params.OlderThan != "" {
+		query.Add("older_than", params.OlderThan)
+	}
+	if params.ResponseStatus != "" {
+		query.Add("response_status", params.ResponseStatus)
+	}
+	if params.Search != "" {
+		query.Add("search", params.Search)
+	}
+
+	err := c.do(ctx, "GET", "/control/querylog", query, nil, &ret)
+	return ret, err
+}
+
+// QueryLogConfig is the response from /control/querylog/config
+type QueryLogConfig struct {
+	Enabled bool `json:"enabled"`
+	// Interval is in milliseconds
+	Interval          uint64   `json:"interval"`
+	AnonymizeClientIP bool     `json:"anonymize_client_ip"`
+	Ignored           []string `json:"ignored"`
+	IgnoredEnabled    bool     `json:"ignored_enabled,omitempty"`
+}
+
+// queryLogInfo is the pre-v0.107.30 /control/querylog_info response, interval in days
+type queryLogInfo struct {
+	Enabled           bool    `json:"enabled"`
+	Interval          float64 `json:"interval"`
+	AnonymizeClientIP bool    `json:"anonymize_client_ip"`
+}
+
+// QueryLogConfig gets the query log settings. Servers too old for
+// /control/querylog/config fall back to /control/querylog_info, which has no ignored list.
+func (c *Client) QueryLogConfig(ctx context.Context) (QueryLogConfig, error) {
+	var ret QueryLogConfig
+	err := c.do(ctx, "GET", "/control/querylog/config", nil, nil, &ret)
+
+	var notFound *common.NotFoundError
+	if !errors.As(err, &notFound) {
+		return ret, err
+	}
+
+	var info queryLogInfo
+	if err := c.do(ctx, "GET", "/control/querylog_info", nil, nil, &info); err != nil {
+		return ret, err
+	}
+	return QueryLogConfig{
+		Enabled:           info.Enabled,
+		Interval:          uint64(info.Interval * float64(24*time.Hour/time.Millisecond)),
+		AnonymizeClientIP: info.AnonymizeClientIP,
+	}, nil
+}
+
+// legacyQueryLogIntervals are the only intervals /control/querylog_config takes
+var legacyQueryLogIntervals = []time.Duration{6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour, 90 * 24 * time.Hour}
+
+// SetQueryLogConfig replaces the query log settings. Servers too old for
+// /control/querylog/config/update get them, interval in days, through
+// /control/querylog_config, which only takes 6h, 1, 7, 30 or 90 days.
+func (c *Client) SetQueryLogConfig(ctx context.Context, config QueryLogConfig) error {
+	err := c.do(ctx, "PUT", "/control/querylog/config/update", nil, config, nil)
+
+	var notFound *common.NotFoundError
+	if !errors.As(err, &notFound) {
+		return err
+	}
+
+	interval := time.Duration(config.Interval) * time.Millisecond
+	if !slices.Contains(legacyQueryLogIntervals, interval) {
+		return fmt.Errorf("this server only keeps the query log for 6h, 1d, 7d, 30d or 90d, not %s", interval)
+	}
+
+	return c.do(ctx, "POST", "/control/querylog_config", nil, queryLogInfo{
+		Enabled:           config.Enabled,
+		Interval:          float64(config.Interval) / float64(24*time.Hour/time.Millisecond),
+		AnonymizeClientIP: config.AnonymizeClientIP,
+	}, nil)
+}
+
+// ClearQueryLog deletes the whole query log
+func (c *Client) ClearQueryLog(ctx context.Context) error {
+	return c.do(ctx, "POST", "/control/querylog_clear", nil, nil, nil)
+}
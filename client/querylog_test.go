@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+// fakeRequest is one request a fakeAdGuard saw
+type fakeRequest struct {
+	method, path, body string
+}
+
+// fakeAdGuard answers GETs from responses by path and records every request.
+// When legacy, the /config endpoints 404 as they do before v0.107.30.
+type fakeAdGuard struct {
+	mu        sync.Mutex
+	legacy    bool
+	responses map[string]string
+	requests  []fakeRequest
+}
+
+func newFakeAdGuard(t *testing.T, legacy bool, responses map[string]string) (*fakeAdGuard, *Client) {
+	t.Helper()
+
+	f := &fakeAdGuard{legacy: legacy, responses: responses}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	c, err := New(&common.ServerConfig{Name: "test", Host: srv.URL, Username: "admin", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, c
+}
+
+func (f *fakeAdGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, fakeRequest{r.Method, r.URL.Path, strings.TrimSpace(string(body))})
+
+	if f.legacy && strings.Contains(r.URL.Path, "/config") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		w.Write([]byte(f.responses[r.URL.Path]))
+	}
+}
+
+// sent is the requests other than GETs
+func (f *fakeAdGuard) sent() []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ret []fakeRequest
+	for _, r := range f.requests {
+		if r.method != "GET" {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+const day = 24 * time.Hour
+
+func ms(d time.Duration) uint64 { return uint64(d.Milliseconds()) }
+
+func TestQueryLogConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		legacy   bool
+		response string
+		want     QueryLogConfig
+	}{
+		{
+			name:     "current",
+			response: `{"enabled": true, "interval": 7776000000, "anonymize_client_ip": true, "ignored": ["example.com"], "ignored_enabled": true}`,
+			want:     QueryLogConfig{Enabled: true, Interval: ms(90 * day), AnonymizeClientIP: true, Ignored: []string{"example.com"}, IgnoredEnabled: true},
+		},
+		{
+			name:     "legacy days",
+			legacy:   true,
+			response: `{"enabled": true, "interval": 7, "anonymize_client_ip": false}`,
+			want:     QueryLogConfig{Enabled: true, Interval: ms(7 * day)},
+		},
+		{
+			name:     "legacy quarter day",
+			legacy:   true,
+			response: `{"enabled": false, "interval": 0.25, "anonymize_client_ip": true}`,
+			want:     QueryLogConfig{Interval: ms(6 * time.Hour), AnonymizeClientIP: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/control/querylog/config"
+			if tt.legacy {
+				path = "/control/querylog_info"
+			}
+			_, c := newFakeAdGuard(t, tt.legacy, map[string]string{path: tt.response})
+
+			got, err := c.QueryLogConfig(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalJSON(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetQueryLogConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		legacy  bool
+		config  QueryLogConfig
+		want    fakeRequest
+		wantErr bool
+	}{
+		{
+			name:   "current",
+			config: QueryLogConfig{Enabled: true, Interval: ms(6 * time.Hour), Ignored: []string{"example.com"}, IgnoredEnabled: true},
+			want:   fakeRequest{"PUT", "/control/querylog/config/update", `{"enabled":true,"interval":21600000,"anonymize_client_ip":false,"ignored":["example.com"],"ignored_enabled":true}`},
+		},
+		{
+			name:   "current takes any interval",
+			config: QueryLogConfig{Enabled: true, Interval: ms(12 * time.Hour)},
+			want:   fakeRequest{"PUT", "/control/querylog/config/update", `{"enabled":true,"interval":43200000,"anonymize_client_ip":false,"ignored":null}`},
+		},
+		{
+			name:   "legacy quarter day",
+			legacy: true,
+			config: QueryLogConfig{Enabled: true, Interval: ms(6 * time.Hour), AnonymizeClientIP: true},
+			want:   fakeRequest{"POST", "/control/querylog_config", `{"enabled":true,"interval":0.25,"anonymize_client_ip":true}`},
+		},
+		{
+			name:   "legacy days",
+			legacy: true,
+			config: QueryLogConfig{Interval: ms(90 * day)},
+			want:   fakeRequest{"POST", "/control/querylog_config", `{"enabled":false,"interval":90,"anonymize_client_ip":false}`},
+		},
+		{name: "legacy half day", legacy: true, config: QueryLogConfig{Enabled: true, Interval: ms(12 * time.Hour)}, wantErr: true},
+		{name: "legacy 14 days", legacy: true, config: QueryLogConfig{Enabled: true, Interval: ms(14 * day)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeAdGuard(t, tt.legacy, nil)
+
+			err := c.SetQueryLogConfig(context.Background(), tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetQueryLogConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			sent := f.sent()
+			if tt.wantErr {
+				// only the PUT that 404ed, nothing the old API would misread
+				if len(sent) != 1 || sent[0].method != "PUT" {
+					t.Errorf("sent %+v", sent)
+				}
+				return
+			}
+			if len(sent) == 0 || sent[len(sent)-1] != tt.want {
+				t.Errorf("sent %+v, want %+v last", sent, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearQueryLog(t *testing.T) {
+	f, c := newFakeAdGuard(t, false, nil)
+	if err := c.ClearQueryLog(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sent := f.sent(); len(sent) != 1 || sent[0].method != "POST" || sent[0].path != "/control/querylog_clear" {
+		t.Errorf("sent %+v", sent)
+	}
+}
+
+// equalJSON compares two values by their JSON encoding, so nil and empty lists differ
+func equalJSON(a, b any) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+)
+
+// RewriteEntry is one DNS rewrite
+type RewriteEntry struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+	// Enabled is nil from servers that can't turn a rewrite off, and left out
+	// when adding means enabled
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// RewriteUpdate is the body of /control/rewrite/update
+type RewriteUpdate struct {
+	Target RewriteEntry `json:"target"`
+	Update RewriteEntry `json:"update"`
+}
+
+// RewriteList lists all DNS rewrites
+func (c *Client) RewriteList(ctx context.Context) ([]RewriteEntry, error) {
+	var ret []RewriteEntry
+	err := c.do(ctx, "GET", "/control/rewrite/list", nil, nil, &ret)
+	return ret, err
+}
+
+// AddRewrite adds a DNS rewrite
+func (c *Client) AddRewrite(ctx context.Context, entry RewriteEntry) error {
+	return c.do(ctx, "POST", "/control/rewrite/add", nil, entry, nil)
+}
+
+// DeleteRewrite deletes a DNS rewrite
+func (c *Client) DeleteRewrite(ctx context.Context, entry RewriteEntry) error {
+	return c.do(ctx, "POST", "/control/rewrite/delete", nil, entry, nil)
+}
+
+// UpdateRewrite replaces the rewrite target with update
+func (c *Client) UpdateRewrite(ctx context.Context, target RewriteEntry, update RewriteEntry) error {
+	return c.do(ctx, "POST", "/control/rewrite/update", nil, RewriteUpdate{Target: target, Update: update}, nil)
+}
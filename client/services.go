@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+)
+
+// BlockableService is one service from /control/blocked_services/all
+type BlockableService struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	GroupID string   `json:"group_id,omitempty"`
+	Rules   []string `json:"rules,omitempty"`
+}
+
+// BlockableServices is the response from /control/blocked_services/all
+type BlockableServices struct {
+	BlockedServices []BlockableService `json:"blocked_services"`
+}
+
+// BlockedServices is the set of currently blocked service IDs and their schedule.
+// A nil schedule means the services are blocked all the time.
+type BlockedServices struct {
+	Schedule map[string]any `json:"schedule"`
+	IDs      []string       `json:"ids"`
+}
+
+// BlockableServices lists every service AdGuard Home knows how to block
+func (c *Client) BlockableServices(ctx context.Context) (BlockableServices, error) {
+	var ret BlockableServices
+	err := c.do(ctx, "GET", "/control/blocked_services/all", nil, nil, &ret)
+	return ret, err
+}
+
+// BlockedServices gets the currently blocked services
+func (c *Client) BlockedServices(ctx context.Context) (BlockedServices, error) {
+	var ret BlockedServices
+	err := c.do(ctx, "GET", "/control/blocked_services/get", nil, nil, &ret)
+	return ret, err
+}
+
+// UpdateBlockedServices replaces the set of blocked services
+func (c *Client) UpdateBlockedServices(ctx context.Context, blocked BlockedServices) error {
+	return c.do(ctx, "PUT", "/control/blocked_services/update", nil, blocked, nil)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+// Stats is the response from /control/stats. The top_* lists are one
+// single-key object per entry, e.g. [{"example.com": 42}, ...], most first.
+type Stats struct {
+	TimeUnits             string               `json:"time_units"`
+	TopQueriedDomains     []map[string]uint64  `json:"top_queried_domains"`
+	TopClients            []map[string]uint64  `json:"top_clients"`
+	TopBlockedDomains     []map[string]uint64  `json:"top_blocked_domains"`
+	TopUpstreamsResponses []map[string]uint64  `json:"top_upstreams_responses"`
+	TopUpstreamsAvgTime   []map[string]float64 `json:"top_upstreams_avg_time"`
+	DNSQueries            []uint64             `json:"dns_queries"`
+	BlockedFiltering      []uint64             `json:"blocked_filtering"`
+	ReplacedSafebrowsing  []uint64             `json:"replaced_safebrowsing"`
+	ReplacedParental      []uint64             `json:"replaced_parental"`
+
+	NumDNSQueries           uint64 `json:"num_dns_queries"`
+	NumBlockedFiltering     uint64 `json:"num_blocked_filtering"`
+	NumReplacedSafebrowsing uint64 `json:"num_replaced_safebrowsing"`
+	NumReplacedSafesearch   uint64 `json:"num_replaced_safesearch"`
+	NumReplacedParental     uint64 `json:"num_replaced_parental"`
+	// AvgProcessingTime is in seconds
+	AvgProcessingTime float64 `json:"avg_processing_time"`
+}
+
+// StatsConfig is the response from /control/stats/config
+type StatsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Interval is in milliseconds
+	Interval       uint64   `json:"interval"`
+	Ignored        []string `json:"ignored"`
+	IgnoredEnabled bool     `json:"ignored_enabled,omitempty"`
+}
+
+// statsInfo is the pre-v0.107.30 /control/stats_info response, interval in days
+type statsInfo struct {
+	Interval uint32 `json:"interval"`
+}
+
+// Stats gets query statistics
+func (c *Client) Stats(ctx context.Context) (Stats, error) {
+	var ret Stats
+	err := c.do(ctx, "GET", "/control/stats", nil, nil, &ret)
+	return ret, err
+}
+
+// StatsConfig gets the statistics settings. Servers too old for
+// /control/stats/config fall back to /control/stats_info, which only knows the interval.
+func (c *Client) StatsConfig(ctx context.Context) (StatsConfig, error) {
+	var ret StatsConfig
+	err := c.do(ctx, "GET", "/control/stats/config", nil, nil, &ret)
+
+	var notFound *common.NotFoundError
+	if !errors.As(err, &notFound) {
+		return ret, err
+	}
+
+	var info statsInfo
+	if err := c.do(ctx, "GET", "/control/stats_info", nil, nil, &info); err != nil {
+		return ret, err
+	}
+	return StatsConfig{
+		Enabled:  info.Interval > 0,
+		Interval: uint64(time.Duration(info.Interval) * 24 * time.Hour / time.Millisecond),
+	}, nil
+}
+
+// legacyStatsDays are the only intervals /control/stats_config takes
+var legacyStatsDays = []uint32{1, 7, 30, 90}
+
+// SetStatsConfig replaces the statistics settings. Servers too old for
+// /control/stats/config/update get the interval through /control/stats_config,
+// which only takes 1, 7, 30 or 90 days.
+func (c *Client) SetStatsConfig(ctx context.Context, config StatsConfig) error {
+	err := c.do(ctx, "PUT", "/control/stats/config/update", nil, config, nil)
+
+	var notFound *common.NotFoundError
+	if !errors.As(err, &notFound) {
+		return err
+	}
+
+	// 0 days turns statistics off there
+	interval := time.Duration(config.Interval) * time.Millisecond
+	days := uint32(interval / (24 * time.Hour))
+	if !config.Enabled {
+		days = 0
+	} else if interval%(24*time.Hour) != 0 || !slices.Contains(legacyStatsDays, days) {
+		return fmt.Errorf("this server only keeps statistics for 1, 7, 30 or 90 days, not %s", interval)
+	}
+	return c.do(ctx, "POST", "/control/stats_config", nil, statsInfo{Interval: days}, nil)
+}
+
+// ResetStats clears all statistics
+func (c *Client) ResetStats(ctx context.Context) error {
+	return c.do(ctx, "POST", "/control/stats_reset", nil, nil, nil)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatsConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		legacy   bool
+		response string
+		want     StatsConfig
+	}{
+		{
+			name:     "current",
+			response: `{"enabled": true, "interval": 86400000, "ignored": [], "ignored_enabled": false}`,
+			want:     StatsConfig{Enabled: true, Interval: ms(day), Ignored: []string{}},
+		},
+		{
+			name:     "legacy days",
+			legacy:   true,
+			response: `{"interval": 30}`,
+			want:     StatsConfig{Enabled: true, Interval: ms(30 * day)},
+		},
+		{
+			name:     "legacy off",
+			legacy:   true,
+			response: `{"interval": 0}`,
+			want:     StatsConfig{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/control/stats/config"
+			if tt.legacy {
+				path = "/control/stats_info"
+			}
+			_, c := newFakeAdGuard(t, tt.legacy, map[string]string{path: tt.response})
+
+			got, err := c.StatsConfig(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalJSON(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetStatsConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		legacy  bool
+		config  StatsConfig
+		want    fakeRequest
+		wantErr bool
+	}{
+		{
+			name:   "current",
+			config: StatsConfig{Enabled: true, Interval: ms(6 * time.Hour), Ignored: []string{"example.com"}, IgnoredEnabled: true},
+			want:   fakeRequest{"PUT", "/control/stats/config/update", `{"enabled":true,"interval":21600000,"ignored":["example.com"],"ignored_enabled":true}`},
+		},
+		{
+			name:   "legacy days",
+			legacy: true,
+			config: StatsConfig{Enabled: true, Interval: ms(7 * day)},
+			want:   fakeRequest{"POST", "/control/stats_config", `{"interval":7}`},
+		},
+		{
+			name:   "legacy off",
+			legacy: true,
+			config: StatsConfig{Interval: ms(7 * day)},
+			want:   fakeRequest{"POST", "/control/stats_config", `{"interval":0}`},
+		},
+		{name: "legacy hours", legacy: true, config: StatsConfig{Enabled: true, Interval: ms(6 * time.Hour)}, wantErr: true},
+		{name: "legacy partial day", legacy: true, config: StatsConfig{Enabled: true, Interval: ms(36 * time.Hour)}, wantErr: true},
+		{name: "legacy 14 days", legacy: true, config: StatsConfig{Enabled: true, Interval: ms(14 * day)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, c := newFakeAdGuard(t, tt.legacy, nil)
+
+			err := c.SetStatsConfig(context.Background(), tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetStatsConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			sent := f.sent()
+			if tt.wantErr {
+				if len(sent) != 1 || sent[0].method != "PUT" {
+					t.Errorf("sent %+v", sent)
+				}
+				return
+			}
+			if len(sent) == 0 || sent[len(sent)-1] != tt.want {
+				t.Errorf("sent %+v, want %+v last", sent, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Status is the response from /control/status
+type Status struct {
+	Version                    string   `json:"version"`
+	Language                   string   `json:"language"`
+	DNSAddresses               []string `json:"dns_addresses"`
+	DNSPort                    uint16   `json:"dns_port"`
+	HTTPPort                   uint16   `json:"http_port"`
+	ProtectionEnabled          bool     `json:"protection_enabled"`
+	ProtectionDisabledDuration uint64   `json:"protection_disabled_duration"`
+	DHCPAvailable              bool     `json:"dhcp_available"`
+	Running                    bool     `json:"running"`
+}
+
+// ProtectionRequest is the body for /control/protection
+type ProtectionRequest struct {
+	Enabled bool `json:"enabled"`
+	// Duration in milliseconds, 0 means no timeout
+	Duration uint64 `json:"duration"`
+}
+
+// Status gets the server status
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var ret Status
+	err := c.do(ctx, "GET", "/control/status", nil, nil, &ret)
+	return ret, err
+}
+
+// SetProtection enables or disables protection. A non-zero duration only
+// applies when disabling and re-enables protection once it expires.
+func (c *Client) SetProtection(ctx context.Context, enabled bool, duration time.Duration) error {
+	req := ProtectionRequest{
+		Enabled:  enabled,
+		Duration: uint64(duration.Milliseconds()),
+	}
+	return c.do(ctx, "POST", "/control/protection", nil, req, nil)
+}
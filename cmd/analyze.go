@@ -0,0 +1,338 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/spf13/cobra"
+)
+
+var logAnalyzeCmd = &cobra.Command{
+	Use:   "analyze <file|->",
+	Short: "Summarize a saved query log, no server needed",
+	Long: `Summarize a query log saved earlier, from a file or - for stdin. It reads what
+log get -o json, log tail -o ndjson and log export to NDJSON write, gzipped or
+not, including the output for several servers. CSV exports leave out fields the
+summary needs, so they can't be read.
+
+The summary has the block, cache hit and NXDOMAIN rates, the top domains and
+blocked domains, counts per client, and latency percentiles per upstream.
+Latencies only count queries an upstream answered, not cached or blocked ones.
+In table output the percent column is the share of all queries.
+
+The log filters from log get pick which queries to count.`,
+	Example: `  adctl log analyze querylog.ndjson.gz
+  adctl log get 0 | adctl log analyze - --top 5 -o table
+  adctl log analyze querylog.ndjson.gz --client 192.168.1.0/24 --since 2025-01-01T00:00:00Z`,
+	Args: cobra.ExactArgs(1),
+	RunE: logAnalyzeCmdE,
+}
+
+var analyzeTop int
+
+func init() {
+	logCmd.AddCommand(logAnalyzeCmd)
+
+	logAnalyzeCmd.Flags().IntVar(&analyzeTop, "top", 10, "How many domains and clients to list (0 for all)")
+	addLogFilterFlags(logAnalyzeCmd)
+}
+
+// LogAnalysis is what log analyze prints
+type LogAnalysis struct {
+	Queries         uint64            `json:"queries"`
+	First           string            `json:"first"`
+	Last            string            `json:"last"`
+	Blocked         uint64            `json:"blocked"`
+	BlockedPercent  float64           `json:"blocked_percent"`
+	Cached          uint64            `json:"cached"`
+	CachedPercent   float64           `json:"cached_percent"`
+	NXDomain        uint64            `json:"nxdomain"`
+	NXDomainPercent float64           `json:"nxdomain_percent"`
+	TopDomains      []TopCount        `json:"top_domains"`
+	TopBlocked      []TopCount        `json:"top_blocked_domains"`
+	Clients         []ClientCounts    `json:"clients"`
+	Upstreams       []UpstreamLatency `json:"upstreams"`
+}
+
+// ClientCounts is how many queries one client made, and what became of them
+type ClientCounts struct {
+	Client          string  `json:"client"`
+	Name            string  `json:"name,omitempty"`
+	Queries         uint64  `json:"queries"`
+	Blocked         uint64  `json:"blocked"`
+	BlockedPercent  float64 `json:"blocked_percent"`
+	NXDomain        uint64  `json:"nxdomain"`
+	NXDomainPercent float64 `json:"nxdomain_percent"`
+}
+
+// UpstreamLatency is how quickly one upstream answered
+type UpstreamLatency struct {
+	Upstream string  `json:"upstream"`
+	Queries  uint64  `json:"queries"`
+	P50Ms    float64 `json:"p50_ms"`
+	P90Ms    float64 `json:"p90_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
+
+// Table lists the totals and then each list, one row per entry
+func (a LogAnalysis) Table() ([]string, [][]string) {
+	share := func(n uint64) string { return fmt.Sprint(percent(n, a.Queries)) }
+
+	rows := [][]string{
+		{"totals", "queries", fmt.Sprint(a.Queries), "", "", "", ""},
+		{"totals", "blocked", fmt.Sprint(a.Blocked), share(a.Blocked), "", "", ""},
+		{"totals", "cached", fmt.Sprint(a.Cached), share(a.Cached), "", "", ""},
+		{"totals", "nxdomain", fmt.Sprint(a.NXDomain), share(a.NXDomain), "", "", ""},
+	}
+
+	for _, top := range []struct {
+		section string
+		list    []TopCount
+	}{
+		{"top_domains", a.TopDomains},
+		{"top_blocked_domains", a.TopBlocked},
+	} {
+		for _, e := range top.list {
+			rows = append(rows, []string{top.section, e.Name, fmt.Sprint(e.Count), share(e.Count), "", "", ""})
+		}
+	}
+
+	for _, c := range a.Clients {
+		name := c.Client
+		if c.Name != "" {
+			name = fmt.Sprintf("%s (%s)", c.Client, c.Name)
+		}
+		rows = append(rows, []string{"clients", name, fmt.Sprint(c.Queries), share(c.Queries), "", "", ""})
+	}
+
+	for _, u := range a.Upstreams {
+		rows = append(rows, []string{"upstreams", u.Upstream, fmt.Sprint(u.Queries), share(u.Queries),
+			fmt.Sprint(u.P50Ms), fmt.Sprint(u.P90Ms), fmt.Sprint(u.P99Ms)})
+	}
+
+	return []string{"section", "name", "count", "percent", "p50_ms", "p90_ms", "p99_ms"}, rows
+}
+
+func logAnalyzeCmdE(cmd *cobra.Command, args []string) error {
+	if analyzeTop < 0 {
+		return fmt.Errorf("--top can't be negative")
+	}
+	match, err := newLogMatcher(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	entries, err := readLogDump(r)
+	if err != nil {
+		return fmt.Errorf("can't read %s: %w", args[0], err)
+	}
+
+	entries = slices.DeleteFunc(entries, func(e client.LogEntry) bool { return !match.match(e) })
+	return render(analyzeLog(entries, analyzeTop))
+}
+
+// readLogDump reads the query log entries out of anything adctl prints them
+// in: a /control/querylog response, a list of them per server, or one entry
+// per line. It may be gzipped.
+func readLogDump(r io.Reader) ([]client.LogEntry, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	entries := []client.LogEntry{}
+	dec := json.NewDecoder(br)
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not JSON or NDJSON: %w", err)
+		}
+		if err := collectLogEntries(raw, &entries); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// collectLogEntries appends the entries in raw to entries. An object with a
+// question is an entry, and an object with data holds entries further down.
+func collectLogEntries(raw json.RawMessage, entries *[]client.LogEntry) error {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || string(raw) == "null":
+		return nil
+
+	case raw[0] == '[':
+		var list []json.RawMessage
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+		for _, item := range list {
+			if err := collectLogEntries(item, entries); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case raw[0] == '{':
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return err
+		}
+		if _, ok := probe["question"]; ok {
+			var e client.LogEntry
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return err
+			}
+			*entries = append(*entries, e)
+			return nil
+		}
+		if data, ok := probe["data"]; ok {
+			return collectLogEntries(data, entries)
+		}
+	}
+
+	return fmt.Errorf("doesn't look like a query log")
+}
+
+// analyzeLog summarizes entries, listing the top domains and clients
+func analyzeLog(entries []client.LogEntry, top int) LogAnalysis {
+	ret := LogAnalysis{Queries: uint64(len(entries))}
+
+	domains := make(map[string]uint64)
+	blocked := make(map[string]uint64)
+	clients := make(map[string]*ClientCounts)
+	latencies := make(map[string][]float64)
+	var first, last time.Time
+
+	for _, e := range entries {
+		if at, err := e.At(); err == nil {
+			if first.IsZero() || at.Before(first) {
+				first, ret.First = at, e.Time
+			}
+			if last.IsZero() || at.After(last) {
+				last, ret.Last = at, e.Time
+			}
+		}
+
+		c := clients[e.Client]
+		if c == nil {
+			c = &ClientCounts{Client: e.Client}
+			clients[e.Client] = c
+		}
+		if e.ClientInfo != nil && e.ClientInfo.Name != "" {
+			c.Name = e.ClientInfo.Name
+		}
+		c.Queries++
+
+		domains[e.Question.Name]++
+		nxdomain := e.Status == "NXDOMAIN"
+		if nxdomain {
+			ret.NXDomain++
+			c.NXDomain++
+		}
+
+		if e.Cached {
+			ret.Cached++
+		}
+		if e.Blocked() {
+			ret.Blocked++
+			c.Blocked++
+			blocked[e.Question.Name]++
+		} else if !e.Cached && e.Upstream != "" {
+			latencies[e.Upstream] = append(latencies[e.Upstream], float64(e.Elapsed())/float64(time.Millisecond))
+		}
+	}
+
+	ret.BlockedPercent = percent(ret.Blocked, ret.Queries)
+	ret.CachedPercent = percent(ret.Cached, ret.Queries)
+	ret.NXDomainPercent = percent(ret.NXDomain, ret.Queries)
+	ret.TopDomains = topCounts([]map[string]uint64{domains}, top)
+	ret.TopBlocked = topCounts([]map[string]uint64{blocked}, top)
+
+	ret.Clients = []ClientCounts{}
+	for _, c := range clients {
+		c.BlockedPercent = percent(c.Blocked, c.Queries)
+		c.NXDomainPercent = percent(c.NXDomain, c.Queries)
+		ret.Clients = append(ret.Clients, *c)
+	}
+	slices.SortFunc(ret.Clients, func(a, b ClientCounts) int {
+		if c := cmp.Compare(b.Queries, a.Queries); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Client, b.Client)
+	})
+	if top > 0 && len(ret.Clients) > top {
+		ret.Clients = ret.Clients[:top]
+	}
+
+	ret.Upstreams = []UpstreamLatency{}
+	for upstream, ms := range latencies {
+		slices.Sort(ms)
+		ret.Upstreams = append(ret.Upstreams, UpstreamLatency{
+			Upstream: upstream,
+			Queries:  uint64(len(ms)),
+			P50Ms:    round2(percentile(ms, 50)),
+			P90Ms:    round2(percentile(ms, 90)),
+			P99Ms:    round2(percentile(ms, 99)),
+			MaxMs:    round2(ms[len(ms)-1]),
+		})
+	}
+	slices.SortFunc(ret.Upstreams, func(a, b UpstreamLatency) int {
+		if c := cmp.Compare(b.Queries, a.Queries); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Upstream, b.Upstream)
+	})
+
+	return ret
+}
+
+// percent is n as a percentage of total, to two places
+func percent(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round2(float64(n) * 100 / float64(total))
+}
+
+// percentile is the nearest-rank percentile p of sorted, which mustn't be empty
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_analyzeLog(t *testing.T) {
+	f, err := os.Open("testdata/querylog/querylog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	entries, err := readLogDump(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := analyzeLog(entries, 2)
+	want := LogAnalysis{
+		Queries:         8,
+		First:           "2025-01-01T10:00:02Z",
+		Last:            "2025-01-01T10:00:09.5Z",
+		Blocked:         3,
+		BlockedPercent:  37.5,
+		Cached:          1,
+		CachedPercent:   12.5,
+		NXDomain:        1,
+		NXDomainPercent: 12.5,
+		TopDomains:      []TopCount{{"example.com", 3}, {"ads.example", 2}},
+		TopBlocked:      []TopCount{{"ads.example", 2}, {"tracker.example", 1}},
+		Clients: []ClientCounts{
+			{Client: "192.168.1.10", Name: "laptop", Queries: 4, Blocked: 1, BlockedPercent: 25},
+			{Client: "192.168.1.20", Queries: 3, Blocked: 1, BlockedPercent: 33.33, NXDomain: 1, NXDomainPercent: 33.33},
+		},
+		Upstreams: []UpstreamLatency{
+			{Upstream: "1.1.1.1:53", Queries: 3, P50Ms: 30, P90Ms: 40, P99Ms: 40, MaxMs: 40},
+			{Upstream: "9.9.9.9:53", Queries: 1, P50Ms: 10, P90Ms: 10, P99Ms: 10, MaxMs: 10},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func Test_readLogDump(t *testing.T) {
+	entry := `{"time":"2025-01-01T10:00:00Z","client":"10.0.0.1","question":{"name":"example.com","type":"A"}}`
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(entry + "\n" + entry + "\n"))
+	gz.Close()
+
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"ndjson", entry + "\n" + entry + "\n", 2},
+		{"querylog", `{"data":[` + entry + `],"oldest":""}`, 1},
+		{"several servers", `[{"server":"a","ok":true,"data":{"data":[` + entry + `,` + entry + `]}},{"server":"b","ok":false,"data":null}]`, 2},
+		{"gzip", gzipped.String(), 2},
+		{"empty", "", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := readLogDump(strings.NewReader(tt.in))
+		if err != nil || len(got) != tt.want {
+			t.Errorf("%s: got %d entries, %v; want %d", tt.name, len(got), err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"time,client\n", `{"status":"running"}`} {
+		if _, err := readLogDump(strings.NewReader(bad)); err == nil {
+			t.Errorf("readLogDump(%q) didn't fail", bad)
+		}
+	}
+
+	// what log tail -o ndjson prints reads back too
+	b, _ := json.Marshal(TailEntry{Server: "a"})
+	if got, err := readLogDump(bytes.NewReader(b)); err != nil || len(got) != 1 {
+		t.Errorf("tail entry: got %d entries, %v", len(got), err)
+	}
+}
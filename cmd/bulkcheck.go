@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+)
+
+// BulkCheck is what filter check --file prints: every name's verdict on
+// every server
+type BulkCheck struct {
+	Servers []string           `json:"servers"`
+	Names   []BulkCheckName    `json:"names"`
+	Summary []BulkCheckSummary `json:"summary"`
+}
+
+// BulkCheckName is one name's result on each server, in Servers order
+type BulkCheckName struct {
+	Name   string            `json:"name"`
+	Checks []BulkCheckResult `json:"checks"`
+}
+
+// BulkCheckResult is one name on one server
+type BulkCheckResult struct {
+	Server string `json:"server,omitempty"`
+	// Verdict is blocked, allowed, rewritten, not filtered, or error
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	List    string `json:"list,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkCheckSummary counts each verdict on one server
+type BulkCheckSummary struct {
+	Server      string `json:"server,omitempty"`
+	Checked     int    `json:"checked"`
+	Blocked     int    `json:"blocked"`
+	Allowed     int    `json:"allowed"`
+	Rewritten   int    `json:"rewritten"`
+	NotFiltered int    `json:"not_filtered"`
+	Errors      int    `json:"errors"`
+}
+
+func (b BulkCheck) Table() ([]string, [][]string) {
+	header := []string{"name"}
+	for _, s := range b.Servers {
+		if s == "" {
+			s = "verdict"
+		}
+		header = append(header, s)
+	}
+
+	rows := make([][]string, len(b.Names))
+	for i, n := range b.Names {
+		rows[i] = []string{n.Name}
+		for _, c := range n.Checks {
+			rows[i] = append(rows[i], c.Verdict)
+		}
+	}
+	return header, rows
+}
+
+func (s BulkCheckSummary) String() string {
+	ret := fmt.Sprintf("%d checked: %d blocked, %d allowed, %d rewritten, %d not filtered, %d errors",
+		s.Checked, s.Blocked, s.Allowed, s.Rewritten, s.NotFiltered, s.Errors)
+	if s.Server != "" {
+		ret = s.Server + ": " + ret
+	}
+	return ret
+}
+
+// readCheckNames reads the names to check, one per line. Blank lines and
+// # or ! comments are skipped, hosts file lines give their names and
+// ||example.com^$important and @@|example.com| give example.com, so a block
+// list can be checked as it is. /regex/ rules, cosmetic rules such as
+// example.com##.ad and wildcards don't name one host, so they're skipped.
+// Each name is only returned once.
+func readCheckNames(r io.Reader) ([]string, error) {
+	var ret []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			ret = append(ret, name)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "##") || strings.Contains(line, "#@#") || strings.Contains(line, "#$#") {
+			continue
+		}
+		fields := strings.Fields(stripComment(line))
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "!") {
+			continue
+		}
+
+		if _, err := netip.ParseAddr(fields[0]); err == nil && len(fields) > 1 {
+			for _, f := range fields[1:] {
+				add(f)
+			}
+			continue
+		}
+
+		// regex rules don't name a host to check
+		if strings.HasPrefix(fields[0], "/") {
+			continue
+		}
+		name := strings.TrimPrefix(fields[0], "@@")
+		name = strings.TrimLeft(name, "|")
+		if i := strings.IndexAny(name, "^$|"); i >= 0 {
+			name = name[:i]
+			if !isHostname(name) {
+				continue
+			}
+		}
+		if strings.Contains(name, "*") {
+			continue
+		}
+		add(name)
+	}
+	return ret, scanner.Err()
+}
+
+// stripComment cuts line at a # that starts it or follows whitespace, which
+// is a comment in hosts files and block lists
+func stripComment(line string) string {
+	for i, r := range line {
+		if r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// readCheckFile reads names from file, or stdin if it's -
+func readCheckFile(file string) ([]string, error) {
+	if file == "-" {
+		return readCheckNames(os.Stdin)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readCheckNames(f)
+}
+
+// checkNames checks every name on one server, concurrency at a time
+func checkNames(ctx context.Context, server *common.ServerConfig, cfa CheckFilterArgs, names []string, concurrency int) ([]BulkCheckResult, error) {
+	c, err := newClient(server)
+	if err != nil {
+		return nil, err
+	}
+
+	// one look at the lists names every rule's list
+	status, err := c.FilteringStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]BulkCheckResult, len(names))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				args := cfa
+				args.name = names[i]
+				result, err := c.CheckHost(ctx, client.CheckHostParams{Name: args.name, Client: args.client, QType: args.qtype})
+				if err != nil {
+					ret[i] = BulkCheckResult{Verdict: "error", Error: err.Error()}
+					continue
+				}
+				ret[i] = bulkCheckResult(explainCheck(args, result, status))
+			}
+		}()
+	}
+
+	for i := range names {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	// a cancelled run would otherwise look like a list of errors
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func bulkCheckResult(e FilterExplanation) BulkCheckResult {
+	ret := BulkCheckResult{Verdict: e.Verdict, Reason: e.Reason}
+	if len(e.Rules) > 0 {
+		ret.Rule, ret.List = e.Rules[0].Text, e.Rules[0].ListName
+	}
+	return ret
+}
+
+// newBulkCheck lays the per-server results out by name and counts them
+func newBulkCheck(names []string, fanned []common.FanOutResult[[]BulkCheckResult]) BulkCheck {
+	ret := BulkCheck{
+		Servers: make([]string, len(fanned)),
+		Names:   make([]BulkCheckName, len(names)),
+		Summary: make([]BulkCheckSummary, len(fanned)),
+	}
+
+	for i, name := range names {
+		ret.Names[i] = BulkCheckName{Name: name, Checks: make([]BulkCheckResult, len(fanned))}
+	}
+
+	for s, r := range fanned {
+		ret.Servers[s] = r.Server
+		sum := BulkCheckSummary{Server: r.Server, Checked: len(names)}
+
+		for i := range names {
+			check := BulkCheckResult{Verdict: "error"}
+			if r.Err != nil {
+				check.Error = r.Err.Error()
+			} else {
+				check = r.Value[i]
+			}
+			check.Server = r.Server
+			ret.Names[i].Checks[s] = check
+
+			switch check.Verdict {
+			case "blocked":
+				sum.Blocked++
+			case "allowed":
+				sum.Allowed++
+			case "rewritten":
+				sum.Rewritten++
+			case "not filtered":
+				sum.NotFiltered++
+			default:
+				sum.Errors++
+			}
+		}
+		ret.Summary[s] = sum
+	}
+
+	return ret
+}
+
+// keepNames keeps the names blocked on at least one server, or with blocked
+// false the names no server blocks
+func (b *BulkCheck) keepNames(blocked bool) {
+	b.Names = slices.DeleteFunc(b.Names, func(n BulkCheckName) bool {
+		anyBlocked, anyError := false, false
+		for _, c := range n.Checks {
+			anyBlocked = anyBlocked || c.Verdict == "blocked"
+			anyError = anyError || c.Verdict == "error"
+		}
+		if blocked {
+			return !anyBlocked
+		}
+		return anyBlocked || anyError
+	})
+}
+
+func bulkCheckCmdE(ctx context.Context, cfa CheckFilterArgs) error {
+	names, err := readCheckFile(checkFilterFile)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no names to check in %s", checkFilterFile)
+	}
+
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+
+	check := func(ctx context.Context, server *common.ServerConfig) ([]BulkCheckResult, error) {
+		return checkNames(ctx, server, cfa, names, checkFilterConcurrency)
+	}
+
+	var fanned []common.FanOutResult[[]BulkCheckResult]
+	if isMultiServer(servers) {
+		fanned = fanOut(ctx, servers, check)
+	} else {
+		// nil means the legacy single-server config
+		var server *common.ServerConfig
+		if len(servers) > 0 {
+			server = &servers[0]
+		}
+		r := common.FanOutResult[[]BulkCheckResult]{}
+		if server != nil {
+			r.Server = server.Name
+		}
+		start := time.Now()
+		r.Value, r.Err = check(ctx, server)
+		r.Duration = time.Since(start)
+		if r.Err != nil {
+			return r.Err
+		}
+		fanned = append(fanned, r)
+	}
+
+	ret := newBulkCheck(names, fanned)
+	switch {
+	case checkFilterOnlyBlocked:
+		ret.keepNames(true)
+	case checkFilterOnlyAllowed:
+		ret.keepNames(false)
+	}
+
+	if err := render(ret); err != nil {
+		return err
+	}
+
+	// a table has no room for the counts, so they go under it
+	if (outputFlag == "table" || outputFlag == "csv") && outputQuery == nil && outputTemplate == nil {
+		for _, s := range ret.Summary {
+			fmt.Fprintln(os.Stderr, s)
+		}
+	}
+
+	return common.FanOutErr(fanned)
+}
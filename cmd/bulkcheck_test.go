@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+func Test_readCheckNames(t *testing.T) {
+	in := `! Title: test list
+# a comment
+ads.example.com
+||tracker.example.com^$important
+@@||allowed.example.com^
+/banner[0-9]+\.example\.com/
+example.com##.ad
+example.com#@#.ad
+example.com#$#.ad { display: none; }
+||*.wild.example.com^
+ads.*.example.com
+||third.example.com^$third-party
+|anchored.example.com|
+||bare.example.com$important
+||bad_name!^
+hash#tag.example.com
+0.0.0.0 one.example.com two.example.com # hosts
+Ads.Example.com.
+
+::1 localhost
+`
+	got, err := readCheckNames(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ads.example.com", "tracker.example.com", "allowed.example.com", "third.example.com", "anchored.example.com", "bare.example.com", "hash#tag.example.com", "one.example.com", "two.example.com", "localhost"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func Test_newBulkCheck(t *testing.T) {
+	names := []string{"ads.example.com", "example.org", "bad"}
+	fanned := []common.FanOutResult[[]BulkCheckResult]{
+		{Server: "a", Value: []BulkCheckResult{
+			{Verdict: "blocked", Rule: "||ads.example.com^"},
+			{Verdict: "not filtered"},
+			{Verdict: "error", Error: "invalid host"},
+		}},
+		{Server: "b", Err: errors.New("can't reach b")},
+	}
+
+	got := newBulkCheck(names, fanned)
+
+	wantSummary := []BulkCheckSummary{
+		{Server: "a", Checked: 3, Blocked: 1, NotFiltered: 1, Errors: 1},
+		{Server: "b", Checked: 3, Errors: 3},
+	}
+	if !reflect.DeepEqual(got.Summary, wantSummary) {
+		t.Errorf("summary: got %+v, want %+v", got.Summary, wantSummary)
+	}
+
+	header, rows := got.Table()
+	wantRows := [][]string{
+		{"ads.example.com", "blocked", "error"},
+		{"example.org", "not filtered", "error"},
+		{"bad", "error", "error"},
+	}
+	if !reflect.DeepEqual(header, []string{"name", "a", "b"}) || !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("table: got %q %q", header, rows)
+	}
+
+	blocked := got
+	blocked.Names = append([]BulkCheckName(nil), got.Names...)
+	blocked.keepNames(true)
+	if len(blocked.Names) != 1 || blocked.Names[0].Name != "ads.example.com" {
+		t.Errorf("only blocked: got %+v", blocked.Names)
+	}
+
+	// b failed, so nothing is known to be allowed everywhere
+	got.keepNames(false)
+	if len(got.Names) != 0 {
+		t.Errorf("only allowed: got %+v", got.Names)
+	}
+}
@@ -0,0 +1,11 @@
+package cmd
+
+import (
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+)
+
+// newClient returns an API client for a server (nil means legacy/viper config)
+func newClient(server *common.ServerConfig) (*client.Client, error) {
+	return client.New(server, client.WithTimeout(requestTimeout))
+}
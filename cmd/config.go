@@ -5,10 +5,12 @@ No header.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 
 	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/viper"
@@ -73,18 +75,33 @@ func GetServers() ([]common.ServerConfig, error) {
 	}
 
 	// Check for legacy single-server format and migrate
-	host := viper.GetString("host")
-	username := viper.GetString("username")
-	password := viper.GetString("password")
+	legacy := common.ServerConfig{
+		Name:            "default",
+		Host:            viper.GetString("host"),
+		Username:        viper.GetString("username"),
+		Password:        viper.GetString("password"),
+		PasswordEnv:     viper.GetString("password_env"),
+		PasswordCommand: viper.GetString("password_command"),
+		PasswordSecret:  viper.GetString("password_secret"),
+	}
 
-	if host != "" && username != "" && password != "" {
+	if legacy.Host != "" && legacy.Username != "" && common.HasPassword(legacy) {
 		// Legacy config found, create a default server
 		servers = []common.ServerConfig{
 			{
-				Name:     "default",
-				Host:     host,
-				Username: username,
-				Password: password,
+				Name:               legacy.Name,
+				Host:               legacy.Host,
+				Username:           legacy.Username,
+				Password:           legacy.Password,
+				PasswordEnv:        legacy.PasswordEnv,
+				PasswordCommand:    legacy.PasswordCommand,
+				PasswordSecret:     legacy.PasswordSecret,
+				Scheme:             viper.GetString("scheme"),
+				CACert:             viper.GetString("ca_cert"),
+				ClientCert:         viper.GetString("client_cert"),
+				ClientKey:          viper.GetString("client_key"),
+				InsecureSkipVerify: viper.GetBool("insecure_skip_verify"),
+				Auth:               viper.GetString("auth"),
 			},
 		}
 		// Optionally migrate to new format (we'll do this on write)
@@ -116,11 +133,8 @@ func GetServer(name string) (*common.ServerConfig, error) {
 // AddServer adds a new server to the configuration
 func AddServer(server common.ServerConfig) error {
 	// Validate server name
-	if server.Name == "" {
-		return fmt.Errorf("server name cannot be empty")
-	}
-	if server.Name == ReservedServerName {
-		return fmt.Errorf("'%s' is a reserved server name", ReservedServerName)
+	if err := validateServerName(server.Name); err != nil {
+		return err
 	}
 
 	// Validate required fields
@@ -130,8 +144,8 @@ func AddServer(server common.ServerConfig) error {
 	if server.Username == "" {
 		return fmt.Errorf("server username cannot be empty")
 	}
-	if server.Password == "" {
-		return fmt.Errorf("server password cannot be empty")
+	if !common.HasPassword(server) {
+		return fmt.Errorf("server needs a password, password_env, password_command or password_secret")
 	}
 
 	// Check for duplicate names
@@ -153,8 +167,129 @@ func AddServer(server common.ServerConfig) error {
 	return SaveServers(servers)
 }
 
+// legacyKeys are the single-server settings that the servers list replaces.
+// They're dropped the first time the servers list is saved.
+var legacyKeys = []string{
+	"host", "username", "password", "password_env", "password_command", "password_secret",
+	"scheme", "ca_cert", "client_cert", "client_key", "insecure_skip_verify", "auth",
+}
+
 // SaveServers writes the server list to the config file
 func SaveServers(servers []common.ServerConfig) error {
+	changes := map[string]any{"servers": servers}
+	for _, key := range legacyKeys {
+		changes[key] = nil
+	}
+	return writeConfig(changes)
+}
+
+// GetDefaultServer returns the server --server defaults to, or "all" if none is set
+func GetDefaultServer() string {
+	if name := viper.GetString("default_server"); name != "" {
+		return name
+	}
+	return ReservedServerName
+}
+
+// SetDefaultServer sets what --server defaults to, which can be any selector.
+// Empty or "all" clears it.
+func SetDefaultServer(name string) error {
+	if name == "" || name == ReservedServerName {
+		return writeConfig(map[string]any{"default_server": nil})
+	}
+	servers, err := GetServers()
+	if err != nil {
+		return err
+	}
+	if _, err := selectServers(servers, name); err != nil {
+		return err
+	}
+	return writeConfig(map[string]any{"default_server": name})
+}
+
+// RemoveServer deletes a server from the configuration, clearing the default if it was the default
+func RemoveServer(name string) error {
+	servers, err := GetServers()
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(servers, func(s common.ServerConfig) bool { return s.Name == name })
+	if i < 0 {
+		return fmt.Errorf("server '%s' not found", name)
+	}
+
+	changes := map[string]any{"servers": slices.Delete(servers, i, i+1)}
+	if viper.GetString("default_server") == name {
+		changes["default_server"] = nil
+	}
+	for _, key := range legacyKeys {
+		changes[key] = nil
+	}
+
+	return writeConfig(changes)
+}
+
+// UpdateServer replaces the configuration of the server with the same name
+func UpdateServer(server common.ServerConfig) error {
+	if server.Host == "" {
+		return fmt.Errorf("server host cannot be empty")
+	}
+	if server.Username == "" {
+		return fmt.Errorf("server username cannot be empty")
+	}
+	if !common.HasPassword(server) {
+		return fmt.Errorf("server needs a password, password_env, password_command or password_secret")
+	}
+
+	servers, err := GetServers()
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(servers, func(s common.ServerConfig) bool { return s.Name == server.Name })
+	if i < 0 {
+		return fmt.Errorf("server '%s' not found", server.Name)
+	}
+	servers[i] = server
+
+	return SaveServers(servers)
+}
+
+// RenameServer renames a server, carrying the default along with it
+func RenameServer(oldName, newName string) error {
+	if err := validateServerName(newName); err != nil {
+		return err
+	}
+
+	servers, err := GetServers()
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(servers, func(s common.ServerConfig) bool { return s.Name == oldName })
+	if i < 0 {
+		return fmt.Errorf("server '%s' not found", oldName)
+	}
+	if slices.ContainsFunc(servers, func(s common.ServerConfig) bool { return s.Name == newName }) {
+		return fmt.Errorf("server '%s' already exists", newName)
+	}
+	servers[i].Name = newName
+
+	changes := map[string]any{"servers": servers}
+	if viper.GetString("default_server") == oldName {
+		changes["default_server"] = newName
+	}
+	for _, key := range legacyKeys {
+		changes[key] = nil
+	}
+
+	return writeConfig(changes)
+}
+
+// writeConfig rewrites the config file with changes applied on top of whatever
+// is already in it, so settings we don't know about survive. A nil value deletes the key.
+func writeConfig(changes map[string]any) error {
 	// Ensure config directory exists
 	if err := EnsureConfigDir(); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
@@ -165,13 +300,31 @@ func SaveServers(servers []common.ServerConfig) error {
 		return err
 	}
 
+	// Read what's there now with a separate viper instance so env vars and
+	// flags don't leak into the file
+	existing := viper.New()
+	existing.SetConfigType("yaml")
+	existing.SetConfigFile(configPath)
+	if err := existing.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	settings := existing.AllSettings()
+	for key, value := range changes {
+		if value == nil {
+			delete(settings, key)
+		} else {
+			settings[key] = value
+		}
+	}
+
 	// Create a new viper instance for writing
 	writeViper := viper.New()
 	writeViper.SetConfigType("yaml")
 	writeViper.SetConfigFile(configPath)
-
-	// Set the servers
-	writeViper.Set("servers", servers)
+	for key, value := range settings {
+		writeViper.Set(key, value)
+	}
 
 	// Write the config file
 	if err := writeViper.WriteConfig(); err != nil {
@@ -204,22 +357,21 @@ func ServerExists(name string) bool {
 }
 
 // GetCurrentServer returns the server config for the current server flag
-// Returns nil if "all" is selected (caller should handle multi-server case)
+// Returns nil if the flag selects several servers (caller should handle multi-server case)
 func GetCurrentServer() (*common.ServerConfig, error) {
-	if serverFlag == ReservedServerName {
-		return nil, nil // nil means "all"
+	servers, err := GetCurrentServers()
+	if err != nil || len(servers) != 1 {
+		return nil, err
 	}
-	return GetServer(serverFlag)
+	return &servers[0], nil
 }
 
-// GetCurrentServers returns all servers to target based on the server flag
+// GetCurrentServers returns all servers to target based on the server flag,
+// which may be a name, "all", or a selector (see selectServers)
 func GetCurrentServers() ([]common.ServerConfig, error) {
-	if serverFlag == ReservedServerName {
-		return GetServers()
-	}
-	server, err := GetServer(serverFlag)
+	servers, err := GetServers()
 	if err != nil {
 		return nil, err
 	}
-	return []common.ServerConfig{*server}, nil
+	return selectServers(servers, serverFlag)
 }
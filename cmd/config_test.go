@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// useTempConfig points the config at an empty temp home for the length of the test
+func useTempConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", "")
+	path, err := GetConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureConfigDir(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		viper.Reset()
+		initConfig()
+	})
+
+	return path
+}
+
+func Test_serverLifecycle(t *testing.T) {
+	path := useTempConfig(t, "default_server: a\nsome_other_setting: keep me\nservers:\n  - name: a\n    host: a:80\n    username: admin\n    password: pw\n")
+
+	if err := AddServer(common.ServerConfig{Name: "b", Host: "b:80", Username: "admin", PasswordEnv: "B_PW"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenameServer("a", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetDefaultServer(); got != "c" {
+		t.Errorf("default after rename = %q, want c", got)
+	}
+	if err := RenameServer("c", "b"); err == nil {
+		t.Error("renamed onto an existing server")
+	}
+
+	server, err := GetServer("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Host = "b:8080"
+	if err := UpdateServer(*server); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveServer("c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetDefaultServer(); got != ReservedServerName {
+		t.Errorf("default after removing it = %q, want %q", got, ReservedServerName)
+	}
+	if err := SetDefaultServer("nope"); err == nil {
+		t.Error("set default to a server that doesn't exist")
+	}
+
+	servers, err := GetServers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 1 || servers[0].Name != "b" || servers[0].Host != "b:8080" || servers[0].PasswordEnv != "B_PW" {
+		t.Errorf("servers = %+v", servers)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "keep me") {
+		t.Errorf("config lost a setting adctl doesn't know about:\n%s", data)
+	}
+	if strings.Contains(string(data), "password:") {
+		t.Errorf("config has a password for a server that uses password_env:\n%s", data)
+	}
+}
+
+func Test_SaveServersDropsLegacyKeys(t *testing.T) {
+	path := useTempConfig(t, "host: router:80\nusername: admin\npassword: pw\n")
+
+	servers, err := GetServers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveServers(servers); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(string(data), "host:") || strings.Contains(string(data), "\nhost:") {
+		t.Errorf("legacy keys survived migration:\n%s", data)
+	}
+	if !strings.Contains(string(data), "name: default") {
+		t.Errorf("migrated server missing:\n%s", data)
+	}
+}
+
+func Test_applyServerFlags(t *testing.T) {
+	tests := []struct {
+		flags   []string
+		changed bool
+		auth    string
+		err     bool
+	}{
+		{flags: []string{"-o", "table"}},
+		{flags: []string{"--auth", "session"}, changed: true, auth: "session"},
+		{flags: []string{"--auth", "Basic"}, changed: true, auth: "Basic"},
+		{flags: []string{"--auth", "cookie"}, changed: true, err: true},
+	}
+
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("output", "o", "", "")
+		addServerFlags(cmd)
+		if err := cmd.ParseFlags(tt.flags); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := serverFlagsChanged(cmd); got != tt.changed {
+			t.Errorf("%q: serverFlagsChanged = %v", tt.flags, got)
+		}
+		var server common.ServerConfig
+		err := applyServerFlags(cmd, &server)
+		if (err != nil) != tt.err || (!tt.err && server.Auth != tt.auth) {
+			t.Errorf("%q: auth %q, %v", tt.flags, server.Auth, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// confirm asks a yes/no question on the terminal before something destructive.
+// yes skips the question, and without a terminal to ask on it refuses.
+func confirm(yes bool, prompt string) error {
+	if yes {
+		return nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("can't ask for confirmation without a terminal, use --yes")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("cancelled")
+}
+
+// serverNames lists who a command is about to touch, for confirmation prompts
+func serverNames() (string, error) {
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return "", err
+	}
+	if len(servers) == 0 {
+		return "the configured server", nil
+	}
+
+	names := make([]string, len(servers))
+	for i, s := range servers {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ", "), nil
+}
@@ -4,9 +4,10 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 
+	"github.com/ewosborne/adctl/client"
 	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/cobra"
 )
@@ -97,67 +98,6 @@ var dhcpStaticLeaseUpdateCmd = &cobra.Command{
 	RunE:  dhcpStaticLeaseUpdateCmdE,
 }
 
-// DHCP status data structures
-type DHCPStatus struct {
-	Enabled       bool          `json:"enabled"`
-	InterfaceName string        `json:"interface_name"`
-	V4            V4Config      `json:"v4"`
-	V6            V6Config      `json:"v6"`
-	Leases        []LeaseDynamic `json:"leases"`
-	StaticLeases  []LeaseStatic  `json:"static_leases"`
-}
-
-type V4Config struct {
-	GatewayIP     string `json:"gateway_ip"`
-	SubnetMask    string `json:"subnet_mask"`
-	RangeStart    string `json:"range_start"`
-	RangeEnd      string `json:"range_end"`
-	LeaseDuration uint32 `json:"lease_duration"`
-}
-
-type V6Config struct {
-	RangeStart    string `json:"range_start"`
-	LeaseDuration uint32 `json:"lease_duration"`
-}
-
-type LeaseDynamic struct {
-	IP       string `json:"ip"`
-	MAC      string `json:"mac"`
-	Hostname string `json:"hostname"`
-	Expires  string `json:"expires"`
-}
-
-type LeaseStatic struct {
-	IP       string `json:"ip"`
-	MAC      string `json:"mac"`
-	Hostname string `json:"hostname"`
-}
-
-// DHCP check response structures
-type DHCPCheckResponse struct {
-	V4 DHCPCheckV4 `json:"v4"`
-	V6 DHCPCheckV6 `json:"v6"`
-}
-
-type DHCPCheckV4 struct {
-	OtherServer DHCPCheckOtherServer `json:"other_server"`
-	StaticIP    DHCPCheckStaticIP    `json:"static_ip"`
-}
-
-type DHCPCheckV6 struct {
-	OtherServer DHCPCheckOtherServer `json:"other_server"`
-}
-
-type DHCPCheckOtherServer struct {
-	Found string `json:"found"`
-	Error string `json:"error,omitempty"`
-}
-
-type DHCPCheckStaticIP struct {
-	Static string `json:"static"`
-	IP     string `json:"ip,omitempty"`
-}
-
 // Flags for config command
 var dhcpConfigEnabled bool
 var dhcpConfigInterface string
@@ -221,60 +161,12 @@ func init() {
 
 // dhcpStatusCmdE handles the dhcp status command
 func dhcpStatusCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpStatusCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	status, err := getDHCPStatus(server)
-	if err != nil {
-		return err
-	}
-
-	output, err := json.MarshalIndent(status, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal status: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
+	return forServers(cmd.Context(), getDHCPStatus)
 }
 
 // dhcpLeasesCmdE handles the dhcp leases command
 func dhcpLeasesCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpLeasesCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	status, err := getDHCPStatus(server)
-	if err != nil {
-		return err
-	}
-
-	output, err := json.MarshalIndent(status.Leases, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal leases: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
+	return forServers(cmd.Context(), getDHCPLeases)
 }
 
 // dhcpCheckCmdE handles the dhcp check command
@@ -284,160 +176,58 @@ func dhcpCheckCmdE(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("interface name cannot be empty")
 	}
 
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpCheckCommandAll(servers, interfaceName)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	result, err := checkDHCP(server, interfaceName)
-	if err != nil {
-		return err
-	}
-
-	output, err := json.MarshalIndent(result, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal check result: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (client.DHCPCheckResponse, error) {
+		return checkDHCP(ctx, server, interfaceName)
+	})
 }
 
 // dhcpConfigCmdE handles the dhcp config command
 func dhcpConfigCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpConfigCommandAll(servers, cmd)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	err = setDHCPConfig(server, cmd)
-	if err != nil {
-		return err
-	}
-
-	// Return updated status
-	return dhcpStatusCmdE(cmd, args)
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (client.DHCPStatus, error) {
+		if err := setDHCPConfig(ctx, server, cmd); err != nil {
+			return client.DHCPStatus{}, err
+		}
+		// Return updated status
+		return getDHCPStatus(ctx, server)
+	})
 }
 
 // dhcpResetCmdE handles the dhcp reset command
 func dhcpResetCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpResetCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	err = resetDHCP(server)
-	if err != nil {
-		return err
-	}
-
-	// Return updated status
-	return dhcpStatusCmdE(cmd, args)
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (client.DHCPStatus, error) {
+		if err := resetDHCP(ctx, server); err != nil {
+			return client.DHCPStatus{}, err
+		}
+		// Return updated status
+		return getDHCPStatus(ctx, server)
+	})
 }
 
 // dhcpResetLeasesCmdE handles the dhcp reset-leases command
 func dhcpResetLeasesCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpResetLeasesCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	err = resetDHCPLeases(server)
-	if err != nil {
-		return err
-	}
-
-	// Return updated leases
-	return dhcpLeasesCmdE(cmd, args)
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) ([]client.LeaseDynamic, error) {
+		if err := resetDHCPLeases(ctx, server); err != nil {
+			return nil, err
+		}
+		// Return updated leases
+		return getDHCPLeases(ctx, server)
+	})
 }
 
 // dhcpStaticLeaseListCmdE handles the static-lease list command
 func dhcpStaticLeaseListCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpStaticLeaseListCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	status, err := getDHCPStatus(server)
-	if err != nil {
-		return err
-	}
-
-	output, err := json.MarshalIndent(status.StaticLeases, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal static leases: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
+	return forServers(cmd.Context(), getStaticLeases)
 }
 
 // dhcpStaticLeaseAddCmdE handles the static-lease add command
 func dhcpStaticLeaseAddCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpStaticLeaseAddCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	err = addStaticLease(server, staticLeaseIP, staticLeaseMAC, staticLeaseHostname)
-	if err != nil {
-		return err
-	}
-
-	// Return updated list
-	return dhcpStaticLeaseListCmdE(cmd, args)
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) ([]client.LeaseStatic, error) {
+		if err := addStaticLease(ctx, server, staticLeaseIP, staticLeaseMAC, staticLeaseHostname); err != nil {
+			return nil, err
+		}
+		// Return updated list
+		return getStaticLeases(ctx, server)
+	})
 }
 
 // dhcpStaticLeaseRemoveCmdE handles the static-lease remove command
@@ -446,212 +236,125 @@ func dhcpStaticLeaseRemoveCmdE(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("at least one of --ip or --mac is required")
 	}
 
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpStaticLeaseRemoveCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	err = removeStaticLease(server, staticLeaseIP, staticLeaseMAC)
-	if err != nil {
-		return err
-	}
-
-	// Return updated list
-	return dhcpStaticLeaseListCmdE(cmd, args)
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) ([]client.LeaseStatic, error) {
+		if err := removeStaticLease(ctx, server, staticLeaseIP, staticLeaseMAC); err != nil {
+			return nil, err
+		}
+		// Return updated list
+		return getStaticLeases(ctx, server)
+	})
 }
 
 // dhcpStaticLeaseUpdateCmdE handles the static-lease update command
 func dhcpStaticLeaseUpdateCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		return dhcpStaticLeaseUpdateCommandAll(servers)
-	}
-
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	err = updateStaticLease(server, staticLeaseIP, staticLeaseMAC, staticLeaseHostname)
-	if err != nil {
-		return err
-	}
-
-	// Return updated list
-	return dhcpStaticLeaseListCmdE(cmd, args)
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) ([]client.LeaseStatic, error) {
+		if err := updateStaticLease(ctx, server, staticLeaseIP, staticLeaseMAC, staticLeaseHostname); err != nil {
+			return nil, err
+		}
+		// Return updated list
+		return getStaticLeases(ctx, server)
+	})
 }
 
 // getDHCPStatus gets DHCP status for a server
-func getDHCPStatus(server *common.ServerConfig) (DHCPStatus, error) {
-	var ret DHCPStatus
-
-	baseURL, err := common.GetBaseURL(server)
+func getDHCPStatus(ctx context.Context, server *common.ServerConfig) (client.DHCPStatus, error) {
+	c, err := newClient(server)
 	if err != nil {
-		return ret, err
-	}
-	baseURL.Path = "/control/dhcp/status"
-
-	statusQuery := common.CommandArgs{
-		Method: "GET",
-		URL:    baseURL,
-		Server: server,
+		return client.DHCPStatus{}, err
 	}
 
-	body, err := common.SendCommand(statusQuery)
+	ret, err := c.DHCPStatus(ctx)
 	if err != nil {
 		return ret, fmt.Errorf("failed to get DHCP status: %w", err)
 	}
 
-	err = json.Unmarshal(body, &ret)
-	if err != nil {
-		return ret, fmt.Errorf("failed to unmarshal DHCP status: %w", err)
-	}
-
 	return ret, nil
 }
 
-// checkDHCP checks for active DHCP servers on an interface
-func checkDHCP(server *common.ServerConfig, interfaceName string) (DHCPCheckResponse, error) {
-	var ret DHCPCheckResponse
-
-	baseURL, err := common.GetBaseURL(server)
-	if err != nil {
-		return ret, err
-	}
-	baseURL.Path = "/control/dhcp/find_active_dhcp"
-
-	requestBody := make(map[string]any)
-	requestBody["interface"] = interfaceName
+// getDHCPLeases gets the active dynamic leases for a server
+func getDHCPLeases(ctx context.Context, server *common.ServerConfig) ([]client.LeaseDynamic, error) {
+	status, err := getDHCPStatus(ctx, server)
+	return status.Leases, err
+}
 
-	checkQuery := common.CommandArgs{
-		Method:      "POST",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
+// getStaticLeases gets the static leases for a server
+func getStaticLeases(ctx context.Context, server *common.ServerConfig) ([]client.LeaseStatic, error) {
+	status, err := getDHCPStatus(ctx, server)
+	return status.StaticLeases, err
+}
 
-	body, err := common.SendCommand(checkQuery)
+// checkDHCP checks for active DHCP servers on an interface
+func checkDHCP(ctx context.Context, server *common.ServerConfig, interfaceName string) (client.DHCPCheckResponse, error) {
+	c, err := newClient(server)
 	if err != nil {
-		return ret, fmt.Errorf("failed to check DHCP: %w", err)
+		return client.DHCPCheckResponse{}, err
 	}
 
-	err = json.Unmarshal(body, &ret)
+	ret, err := c.FindActiveDHCP(ctx, interfaceName)
 	if err != nil {
-		return ret, fmt.Errorf("failed to unmarshal DHCP check result: %w", err)
+		return ret, fmt.Errorf("failed to check DHCP: %w", err)
 	}
 
 	return ret, nil
 }
 
 // setDHCPConfig sets DHCP configuration
-func setDHCPConfig(server *common.ServerConfig, cmd *cobra.Command) error {
+func setDHCPConfig(ctx context.Context, server *common.ServerConfig, cmd *cobra.Command) error {
 	// Get current status to preserve existing config
-	currentStatus, err := getDHCPStatus(server)
+	currentStatus, err := getDHCPStatus(ctx, server)
 	if err != nil {
 		return fmt.Errorf("failed to get current DHCP status: %w", err)
 	}
 
-	requestBody := make(map[string]any)
+	// Start from the current config and override whatever flags were given
+	config := client.DHCPConfig{
+		Enabled:       currentStatus.Enabled,
+		InterfaceName: currentStatus.InterfaceName,
+		V4:            currentStatus.V4,
+		V6:            currentStatus.V6,
+	}
 
 	// Set enabled flag if provided, otherwise preserve current
 	if cmd.Flags().Changed("enabled") {
-		requestBody["enabled"] = dhcpConfigEnabled
-	} else {
-		requestBody["enabled"] = currentStatus.Enabled
+		config.Enabled = dhcpConfigEnabled
 	}
 
 	// Set interface if provided
 	if dhcpConfigInterface != "" {
-		requestBody["interface_name"] = dhcpConfigInterface
-	} else {
-		requestBody["interface_name"] = currentStatus.InterfaceName
+		config.InterfaceName = dhcpConfigInterface
 	}
 
 	// Build v4 config
-	v4Config := make(map[string]any)
 	if dhcpConfigV4Gateway != "" {
-		v4Config["gateway_ip"] = dhcpConfigV4Gateway
-	} else if currentStatus.V4.GatewayIP != "" {
-		v4Config["gateway_ip"] = currentStatus.V4.GatewayIP
+		config.V4.GatewayIP = dhcpConfigV4Gateway
 	}
-
 	if dhcpConfigV4Subnet != "" {
-		v4Config["subnet_mask"] = dhcpConfigV4Subnet
-	} else if currentStatus.V4.SubnetMask != "" {
-		v4Config["subnet_mask"] = currentStatus.V4.SubnetMask
+		config.V4.SubnetMask = dhcpConfigV4Subnet
 	}
-
 	if dhcpConfigV4RangeStart != "" {
-		v4Config["range_start"] = dhcpConfigV4RangeStart
-	} else if currentStatus.V4.RangeStart != "" {
-		v4Config["range_start"] = currentStatus.V4.RangeStart
+		config.V4.RangeStart = dhcpConfigV4RangeStart
 	}
-
 	if dhcpConfigV4RangeEnd != "" {
-		v4Config["range_end"] = dhcpConfigV4RangeEnd
-	} else if currentStatus.V4.RangeEnd != "" {
-		v4Config["range_end"] = currentStatus.V4.RangeEnd
+		config.V4.RangeEnd = dhcpConfigV4RangeEnd
 	}
-
 	if dhcpConfigV4LeaseDuration > 0 {
-		v4Config["lease_duration"] = dhcpConfigV4LeaseDuration
-	} else if currentStatus.V4.LeaseDuration > 0 {
-		v4Config["lease_duration"] = currentStatus.V4.LeaseDuration
-	}
-
-	if len(v4Config) > 0 {
-		requestBody["v4"] = v4Config
-	} else {
-		requestBody["v4"] = currentStatus.V4
+		config.V4.LeaseDuration = dhcpConfigV4LeaseDuration
 	}
 
 	// Build v6 config
-	v6Config := make(map[string]any)
 	if dhcpConfigV6RangeStart != "" {
-		v6Config["range_start"] = dhcpConfigV6RangeStart
-	} else if currentStatus.V6.RangeStart != "" {
-		v6Config["range_start"] = currentStatus.V6.RangeStart
+		config.V6.RangeStart = dhcpConfigV6RangeStart
 	}
-
 	if dhcpConfigV6LeaseDuration > 0 {
-		v6Config["lease_duration"] = dhcpConfigV6LeaseDuration
-	} else if currentStatus.V6.LeaseDuration > 0 {
-		v6Config["lease_duration"] = currentStatus.V6.LeaseDuration
-	}
-
-	if len(v6Config) > 0 {
-		requestBody["v6"] = v6Config
-	} else {
-		requestBody["v6"] = currentStatus.V6
+		config.V6.LeaseDuration = dhcpConfigV6LeaseDuration
 	}
 
-	baseURL, err := common.GetBaseURL(server)
+	c, err := newClient(server)
 	if err != nil {
 		return err
 	}
-	baseURL.Path = "/control/dhcp/set_config"
-
-	configQuery := common.CommandArgs{
-		Method:      "POST",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
 
-	_, err = common.SendCommand(configQuery)
+	err = c.SetDHCPConfig(ctx, config)
 	if err != nil {
 		return fmt.Errorf("failed to set DHCP config: %w", err)
 	}
@@ -660,20 +363,13 @@ func setDHCPConfig(server *common.ServerConfig, cmd *cobra.Command) error {
 }
 
 // resetDHCP resets DHCP configuration
-func resetDHCP(server *common.ServerConfig) error {
-	baseURL, err := common.GetBaseURL(server)
+func resetDHCP(ctx context.Context, server *common.ServerConfig) error {
+	c, err := newClient(server)
 	if err != nil {
 		return err
 	}
-	baseURL.Path = "/control/dhcp/reset"
 
-	resetQuery := common.CommandArgs{
-		Method: "POST",
-		URL:    baseURL,
-		Server: server,
-	}
-
-	_, err = common.SendCommand(resetQuery)
+	err = c.ResetDHCP(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to reset DHCP: %w", err)
 	}
@@ -682,20 +378,13 @@ func resetDHCP(server *common.ServerConfig) error {
 }
 
 // resetDHCPLeases resets DHCP leases (clears active leases)
-func resetDHCPLeases(server *common.ServerConfig) error {
-	baseURL, err := common.GetBaseURL(server)
+func resetDHCPLeases(ctx context.Context, server *common.ServerConfig) error {
+	c, err := newClient(server)
 	if err != nil {
 		return err
 	}
-	baseURL.Path = "/control/dhcp/reset_leases"
-
-	resetQuery := common.CommandArgs{
-		Method: "POST",
-		URL:    baseURL,
-		Server: server,
-	}
 
-	_, err = common.SendCommand(resetQuery)
+	err = c.ResetDHCPLeases(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to reset DHCP leases: %w", err)
 	}
@@ -704,26 +393,14 @@ func resetDHCPLeases(server *common.ServerConfig) error {
 }
 
 // addStaticLease adds a static lease
-func addStaticLease(server *common.ServerConfig, ip, mac, hostname string) error {
-	requestBody := make(map[string]any)
-	requestBody["ip"] = ip
-	requestBody["mac"] = mac
-	requestBody["hostname"] = hostname
-
-	baseURL, err := common.GetBaseURL(server)
+func addStaticLease(ctx context.Context, server *common.ServerConfig, ip, mac, hostname string) error {
+	c, err := newClient(server)
 	if err != nil {
 		return err
 	}
-	baseURL.Path = "/control/dhcp/add_static_lease"
-
-	addQuery := common.CommandArgs{
-		Method:      "POST",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
 
-	_, err = common.SendCommand(addQuery)
+	lease := client.LeaseStatic{IP: ip, MAC: mac, Hostname: hostname}
+	err = c.AddStaticLease(ctx, lease)
 	if err != nil {
 		return fmt.Errorf("failed to add static lease: %w", err)
 	}
@@ -732,29 +409,14 @@ func addStaticLease(server *common.ServerConfig, ip, mac, hostname string) error
 }
 
 // removeStaticLease removes a static lease
-func removeStaticLease(server *common.ServerConfig, ip, mac string) error {
-	requestBody := make(map[string]any)
-	if ip != "" {
-		requestBody["ip"] = ip
-	}
-	if mac != "" {
-		requestBody["mac"] = mac
-	}
-
-	baseURL, err := common.GetBaseURL(server)
+func removeStaticLease(ctx context.Context, server *common.ServerConfig, ip, mac string) error {
+	c, err := newClient(server)
 	if err != nil {
 		return err
 	}
-	baseURL.Path = "/control/dhcp/remove_static_lease"
-
-	removeQuery := common.CommandArgs{
-		Method:      "POST",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
 
-	_, err = common.SendCommand(removeQuery)
+	lease := client.LeaseStatic{IP: ip, MAC: mac}
+	err = c.RemoveStaticLease(ctx, lease)
 	if err != nil {
 		return fmt.Errorf("failed to remove static lease: %w", err)
 	}
@@ -763,335 +425,17 @@ func removeStaticLease(server *common.ServerConfig, ip, mac string) error {
 }
 
 // updateStaticLease updates a static lease
-func updateStaticLease(server *common.ServerConfig, ip, mac, hostname string) error {
-	requestBody := make(map[string]any)
-	requestBody["ip"] = ip
-	if mac != "" {
-		requestBody["mac"] = mac
-	}
-	if hostname != "" {
-		requestBody["hostname"] = hostname
-	}
-
-	baseURL, err := common.GetBaseURL(server)
+func updateStaticLease(ctx context.Context, server *common.ServerConfig, ip, mac, hostname string) error {
+	c, err := newClient(server)
 	if err != nil {
 		return err
 	}
-	baseURL.Path = "/control/dhcp/update_static_lease"
-
-	updateQuery := common.CommandArgs{
-		Method:      "POST",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
 
-	_, err = common.SendCommand(updateQuery)
+	lease := client.LeaseStatic{IP: ip, MAC: mac, Hostname: hostname}
+	err = c.UpdateStaticLease(ctx, lease)
 	if err != nil {
 		return fmt.Errorf("failed to update static lease: %w", err)
 	}
 
 	return nil
 }
-
-// Multi-server support functions
-
-func dhcpStatusCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string     `json:"server"`
-		Result DHCPStatus `json:"result,omitempty"`
-		Error  string     `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		status, err := getDHCPStatus(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = status
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpLeasesCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string          `json:"server"`
-		Result []LeaseDynamic `json:"result,omitempty"`
-		Error  string          `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		status, err := getDHCPStatus(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = status.Leases
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpCheckCommandAll(servers []common.ServerConfig, interfaceName string) error {
-	type ServerResult struct {
-		Server string            `json:"server"`
-		Result DHCPCheckResponse `json:"result,omitempty"`
-		Error  string            `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		checkResult, err := checkDHCP(&server, interfaceName)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = checkResult
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpConfigCommandAll(servers []common.ServerConfig, cmd *cobra.Command) error {
-	type ServerResult struct {
-		Server string     `json:"server"`
-		Result DHCPStatus `json:"result,omitempty"`
-		Error  string     `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := setDHCPConfig(&server, cmd)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			status, err := getDHCPStatus(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Result = status
-			}
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpResetCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string     `json:"server"`
-		Result DHCPStatus `json:"result,omitempty"`
-		Error  string     `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := resetDHCP(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			status, err := getDHCPStatus(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Result = status
-			}
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpResetLeasesCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string          `json:"server"`
-		Result []LeaseDynamic `json:"result,omitempty"`
-		Error  string          `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := resetDHCPLeases(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			status, err := getDHCPStatus(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Result = status.Leases
-			}
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpStaticLeaseListCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string         `json:"server"`
-		Result []LeaseStatic `json:"result,omitempty"`
-		Error  string         `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		status, err := getDHCPStatus(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = status.StaticLeases
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpStaticLeaseAddCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string         `json:"server"`
-		Result []LeaseStatic `json:"result,omitempty"`
-		Error  string         `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := addStaticLease(&server, staticLeaseIP, staticLeaseMAC, staticLeaseHostname)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			status, err := getDHCPStatus(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Result = status.StaticLeases
-			}
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpStaticLeaseRemoveCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string         `json:"server"`
-		Result []LeaseStatic `json:"result,omitempty"`
-		Error  string         `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := removeStaticLease(&server, staticLeaseIP, staticLeaseMAC)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			status, err := getDHCPStatus(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Result = status.StaticLeases
-			}
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func dhcpStaticLeaseUpdateCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string         `json:"server"`
-		Result []LeaseStatic `json:"result,omitempty"`
-		Error  string         `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := updateStaticLease(&server, staticLeaseIP, staticLeaseMAC, staticLeaseHostname)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			status, err := getDHCPStatus(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Result = status.StaticLeases
-			}
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-	fmt.Println(string(output))
-	return nil
-}
@@ -4,36 +4,46 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/cobra"
 )
 
-func disableCommand(server *common.ServerConfig, dTime DisableTime) (Status, error) {
-	var err error
+func disableCommand(ctx context.Context, server *common.ServerConfig, dTime DisableTime) (Status, error) {
+	var duration time.Duration
 
 	if dTime.HasTimeout {
-		err = common.AbleCommand(server, false, dTime.Duration)
-	} else {
-		err = common.AbleCommand(server, false, "")
+		var err error
+		duration, err = time.ParseDuration(dTime.Duration)
+		if err != nil {
+			return Status{}, fmt.Errorf("time.ParseDuration: %w", err)
+		}
 	}
 
+	c, err := newClient(server)
 	if err != nil {
 		return Status{}, err
 	}
 
-	s, err := GetStatus(server)
+	err = c.SetProtection(ctx, false, duration)
+	if err != nil {
+		return Status{}, err
+	}
+
+	s, err := GetStatus(ctx, server)
 
 	return s, err
 }
 
 var statusDisableCmd = &cobra.Command{
-	Use:   "disable",
-	Short: "Disable ad blocker. Optional duration in time.Duration format.",
-	Args:  cobra.RangeArgs(0, 1),
-	RunE:  StatusDisableCmdE,
+	Use:         "disable",
+	Short:       "Disable ad blocker. Optional duration in time.Duration format.",
+	Args:        cobra.RangeArgs(0, 1),
+	RunE:        StatusDisableCmdE,
+	Annotations: map[string]string{timeoutAnnotation: "5s"},
 }
 
 func init() {
@@ -41,6 +51,7 @@ func init() {
 }
 
 func StatusDisableCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
 
 	var dTime = DisableTime{}
 
@@ -54,58 +65,12 @@ func StatusDisableCmdE(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("only one arg allowed for disable")
 	}
 
-	return printDisable(dTime)
-}
-
-func printDisable(dTime DisableTime) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return disableCommandAll(servers, dTime)
-	}
-
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	status, err := disableCommand(server, dTime)
-	if err != nil {
-		return err
-	}
-
-	PrintStatus(status)
-	return nil
+	return printDisable(ctx, dTime)
 }
 
-func disableCommandAll(servers []common.ServerConfig, dTime DisableTime) error {
-	type ServerResult struct {
-		Server string `json:"server"`
-		Status Status `json:"status,omitempty"`
-		Error  string `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		status, err := disableCommand(&server, dTime)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Status = status
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(output))
-	return nil
+func printDisable(ctx context.Context, dTime DisableTime) error {
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (ReadableStatus, error) {
+		s, err := disableCommand(ctx, server, dTime)
+		return readable(s), err
+	})
 }
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+// Exit codes, so scripts can tell a partial failure across servers from a total one,
+// and a wrong password from a server that's down
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitPartial     = 2 // some servers failed
+	exitAllFailed   = 3 // every server failed
+	exitAuth        = 4 // credentials rejected
+	exitNotFound    = 5 // endpoint or object doesn't exist
+	exitValidation  = 6 // server refused the request as invalid
+	exitUnreachable = 7 // couldn't connect at all
+	exitTimeout     = 8 // request took too long
+	exitInterrupted = 130
+)
+
+// exitCode maps the error a command returned to a process exit code
+func exitCode(ctx context.Context, err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	if ctx.Err() != nil {
+		// conventional exit status for SIGINT
+		return exitInterrupted
+	}
+
+	var fanOutErr *common.FanOutError
+	if errors.As(err, &fanOutErr) {
+		if fanOutErr.AllFailed() {
+			return exitAllFailed
+		}
+		return exitPartial
+	}
+
+	var authErr *common.AuthError
+	var notFoundErr *common.NotFoundError
+	var validationErr *common.ValidationError
+	var unreachableErr *common.UnreachableError
+	var timeoutErr *common.TimeoutError
+	switch {
+	case errors.As(err, &authErr):
+		return exitAuth
+	case errors.As(err, &notFoundErr):
+		return exitNotFound
+	case errors.As(err, &validationErr):
+		return exitValidation
+	case errors.As(err, &unreachableErr):
+		return exitUnreachable
+	case errors.As(err, &timeoutErr):
+		return exitTimeout
+	}
+
+	return exitError
+}
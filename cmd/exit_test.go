@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+func Test_exitCode(t *testing.T) {
+	some := []common.FanOutResult[int]{{Server: "a"}, {Server: "b", Err: fmt.Errorf("down")}}
+	all := []common.FanOutResult[int]{{Server: "a", Err: fmt.Errorf("down")}, {Server: "b", Err: fmt.Errorf("down")}}
+	none := []common.FanOutResult[int]{{Server: "a"}, {Server: "b"}}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var tt = []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected int
+	}{
+		{name: "ok", ctx: context.Background(), err: common.FanOutErr(none), expected: exitOK},
+		{name: "partial", ctx: context.Background(), err: common.FanOutErr(some), expected: exitPartial},
+		{name: "all failed", ctx: context.Background(), err: common.FanOutErr(all), expected: exitAllFailed},
+		{name: "wrapped", ctx: context.Background(), err: fmt.Errorf("wrapped: %w", common.FanOutErr(some)), expected: exitPartial},
+		{name: "plain", ctx: context.Background(), err: fmt.Errorf("boom"), expected: exitError},
+		{name: "auth", ctx: context.Background(), err: &common.AuthError{}, expected: exitAuth},
+		{name: "not found", ctx: context.Background(), err: &common.NotFoundError{}, expected: exitNotFound},
+		{name: "validation", ctx: context.Background(), err: fmt.Errorf("failed to set DHCP config: %w", &common.ValidationError{}), expected: exitValidation},
+		{name: "unreachable", ctx: context.Background(), err: &common.UnreachableError{}, expected: exitUnreachable},
+		{name: "timeout", ctx: context.Background(), err: &common.TimeoutError{}, expected: exitTimeout},
+		{name: "other http", ctx: context.Background(), err: &common.HTTPError{StatusCode: 500}, expected: exitError},
+		{name: "interrupted", ctx: cancelled, err: fmt.Errorf("boom"), expected: exitInterrupted},
+	}
+
+	for _, entry := range tt {
+		got := exitCode(entry.ctx, entry.err)
+		if got != entry.expected {
+			t.Errorf("%s: expected exit code %d, got %d", entry.name, entry.expected, got)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var logExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Save the whole query log to a file, a page at a time",
+	Long: `Save the whole query log, newest first, as NDJSON or CSV. adctl pages back
+through the log with older_than, --page-size queries per request, so a busy
+server never has to answer one huge request.
+
+The format comes from --format, or else from the file name: .csv is CSV and
+anything else is NDJSON. A .gz on the end, or --gzip, compresses it.
+
+While writing to a file adctl keeps a cursor next to it (FILE.cursor) saying how
+far it got. If the export is interrupted, run the same command with --resume
+to carry on from there. The cursor is removed once the export finishes.
+
+The log filters from log get apply here too.`,
+	Example: `  adctl log export -O querylog.ndjson.gz
+  adctl log export -O blocked.csv --filter blocked --since 7d
+  adctl log export -O querylog.ndjson.gz --resume`,
+	Args: cobra.NoArgs,
+	RunE: logExportCmdE,
+}
+
+var exportFile string
+var exportFormat string
+var exportGzip bool
+var exportPageSize int
+var exportResume bool
+
+var exportFormats = []string{"ndjson", "csv"}
+
+func init() {
+	logCmd.AddCommand(logExportCmd)
+
+	logExportCmd.Flags().StringVarP(&exportFile, "file", "O", "-", "File to write, - for stdout")
+	logExportCmd.Flags().StringVar(&exportFormat, "format", "", "ndjson or csv (default from the file name, else ndjson)")
+	logExportCmd.Flags().BoolVar(&exportGzip, "gzip", false, "Compress with gzip (the default for a .gz file)")
+	logExportCmd.Flags().IntVar(&exportPageSize, "page-size", 1000, "Queries to fetch per request")
+	logExportCmd.Flags().BoolVar(&exportResume, "resume", false, "Carry on from where an interrupted export to the same file stopped")
+	logExportCmd.Flags().StringVar(&filter, "filter", "all", fmt.Sprintf("one of: %#v", allowedFilters))
+	logExportCmd.Flags().StringVar(&searchQuery, "search", "", "string to search for in logs.")
+	addLogFilterFlags(logExportCmd)
+
+	logExportCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(exportFormats, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// exportCursor is saved next to the export file after every page, so an
+// interrupted export can carry on
+type exportCursor struct {
+	Server    string `json:"server"`
+	Format    string `json:"format"`
+	Gzip      bool   `json:"gzip"`
+	OlderThan string `json:"older_than"`
+	Exported  int    `json:"exported"`
+	// Offset is how long the file was when the cursor was saved. Anything
+	// after it was written after the cursor, so --resume cuts it off.
+	Offset int64 `json:"offset"`
+}
+
+func logExportCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	if !slices.Contains(allowedFilters, filter) {
+		return fmt.Errorf("filter value %s not allowed", filter)
+	}
+	if exportPageSize <= 0 {
+		return fmt.Errorf("--page-size must be positive")
+	}
+	match, err := newLogMatcher(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+
+	toStdout := exportFile == "-"
+	if toStdout && exportResume {
+		return fmt.Errorf("--resume needs a file to carry on writing, give one with --file")
+	}
+
+	format, gz, err := exportFileFormat(exportFile, exportFormat, exportGzip)
+	if err != nil {
+		return err
+	}
+
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+	if isMultiServer(servers) {
+		return fmt.Errorf("log export works on one server at a time, pick one with -s")
+	}
+	var server *common.ServerConfig
+	serverName := ""
+	if len(servers) > 0 {
+		server = &servers[0]
+		serverName = server.Name
+	}
+
+	cursor := exportCursor{Server: serverName, Format: format, Gzip: gz}
+	if !match.until.IsZero() {
+		cursor.OlderThan = match.until.Format(time.RFC3339Nano)
+	}
+	cursorFile := exportFile + ".cursor"
+
+	if exportResume {
+		saved, err := readExportCursor(cursorFile)
+		if err != nil {
+			return err
+		}
+		if saved.Server != cursor.Server || saved.Format != cursor.Format || saved.Gzip != cursor.Gzip {
+			return fmt.Errorf("%s is for a %s export from server %q, not this one", cursorFile, describeExport(saved), saved.Server)
+		}
+		cursor = saved
+	}
+
+	c, err := newClient(server)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if !toStdout {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if exportResume {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(exportFile, flags, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if exportResume {
+			if err := truncateExport(f, cursor.Offset); err != nil {
+				return err
+			}
+		}
+		out, file = f, f
+	}
+
+	w := newExportWriter(out, format, gz, !exportResume)
+	progress := !toStdout && term.IsTerminal(int(os.Stderr.Fd()))
+
+	params := client.QueryLogParams{
+		OlderThan:      cursor.OlderThan,
+		ResponseStatus: filter,
+		Search:         searchQuery,
+	}
+	walkErr := walkLog(ctx, c, params, exportPageSize, func(page client.QueryLog) (bool, error) {
+		more := true
+		var keep []client.LogEntry
+		for _, e := range page.Data {
+			if match.pastSince(e) {
+				more = false
+				break
+			}
+			if match.match(e) {
+				keep = append(keep, e)
+			}
+		}
+
+		if err := w.write(keep); err != nil {
+			return false, err
+		}
+		cursor.OlderThan = page.Oldest
+		cursor.Exported += len(keep)
+
+		// the page has to be on disk before the cursor says it is
+		if !toStdout {
+			if err := w.flush(); err != nil {
+				return false, err
+			}
+			info, err := file.Stat()
+			if err != nil {
+				return false, err
+			}
+			cursor.Offset = info.Size()
+			if err := writeExportCursor(cursorFile, cursor); err != nil {
+				return false, err
+			}
+		}
+		if progress {
+			fmt.Fprintf(os.Stderr, "\rexported %d queries, back to %s ", cursor.Exported, page.Oldest)
+		}
+		return more, nil
+	})
+
+	closeErr := w.close()
+	if progress {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if walkErr != nil {
+		if !toStdout && cursor.Exported > 0 {
+			fmt.Fprintf(os.Stderr, "stopped after %d queries, run the same command with --resume to carry on\n", cursor.Exported)
+		}
+		return walkErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	if !toStdout {
+		if err := os.Remove(cursorFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "exported %d queries to %s\n", cursor.Exported, exportFile)
+	}
+	return nil
+}
+
+// exportFileFormat works out the format and compression from the flags, or
+// failing that from the file name
+func exportFileFormat(file, format string, gz bool) (string, bool, error) {
+	name := strings.ToLower(file)
+	if base, ok := strings.CutSuffix(name, ".gz"); ok {
+		name = base
+		gz = true
+	}
+
+	if format == "" {
+		format = "ndjson"
+		if strings.HasSuffix(name, ".csv") {
+			format = "csv"
+		}
+	}
+	if !slices.Contains(exportFormats, format) {
+		return "", false, fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(exportFormats, ", "))
+	}
+
+	return format, gz, nil
+}
+
+func describeExport(c exportCursor) string {
+	if c.Gzip {
+		return "gzipped " + c.Format
+	}
+	return c.Format
+}
+
+func readExportCursor(path string) (exportCursor, error) {
+	var c exportCursor
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, fmt.Errorf("nothing to resume, there's no %s", path)
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("can't read %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// truncateExport cuts f back to where the cursor was saved
+func truncateExport(f *os.File, offset int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < offset {
+		return fmt.Errorf("%s is shorter than its cursor says, start the export again without --resume", f.Name())
+	}
+	return f.Truncate(offset)
+}
+
+// writeExportCursor replaces the cursor file in one go, so it's never half written
+func writeExportCursor(path string, c exportCursor) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// exportWriter writes query log entries as NDJSON or CSV, maybe gzipped
+type exportWriter struct {
+	buf *bufio.Writer
+	gz  *gzip.Writer
+	csv *csv.Writer
+	enc *json.Encoder
+	// header is whether the CSV header is still to be written
+	header bool
+	// gzDone is whether flush finished the gzip member, so the next write
+	// starts another
+	gzDone bool
+}
+
+// newExportWriter writes to w. header is false when appending to an earlier
+// export, which already has the CSV header. Each flush finishes a gzip member
+// and gzip readers treat several members as one stream, so the file can be
+// cut back to any flush and appended to.
+func newExportWriter(w io.Writer, format string, gz bool, header bool) *exportWriter {
+	ew := &exportWriter{buf: bufio.NewWriter(w), header: header}
+
+	var out io.Writer = ew.buf
+	if gz {
+		ew.gz = gzip.NewWriter(ew.buf)
+		out = ew.gz
+	}
+
+	if format == "csv" {
+		ew.csv = csv.NewWriter(out)
+	} else {
+		ew.enc = json.NewEncoder(out)
+	}
+	return ew
+}
+
+func (ew *exportWriter) write(entries []client.LogEntry) error {
+	if len(entries) == 0 && !ew.header {
+		return nil
+	}
+	if ew.gzDone {
+		ew.gz.Reset(ew.buf)
+		ew.gzDone = false
+	}
+
+	if ew.enc != nil {
+		for _, e := range entries {
+			if err := ew.enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	header, rows := logOutput{Data: entries}.Table()
+	if ew.header {
+		if err := ew.csv.Write(header); err != nil {
+			return err
+		}
+		ew.header = false
+	}
+	return ew.csv.WriteAll(rows)
+}
+
+// flush pushes everything written so far through to the file, leaving it
+// complete as it stands
+func (ew *exportWriter) flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if ew.gz != nil && !ew.gzDone {
+		if err := ew.gz.Close(); err != nil {
+			return err
+		}
+		ew.gzDone = true
+	}
+	return ew.buf.Flush()
+}
+
+func (ew *exportWriter) close() error {
+	return ew.flush()
+}
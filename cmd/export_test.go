@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ewosborne/adctl/client"
+)
+
+func Test_exportFileFormat(t *testing.T) {
+	tests := []struct {
+		file, format string
+		gzip         bool
+		wantFormat   string
+		wantGzip     bool
+		err          bool
+	}{
+		{"-", "", false, "ndjson", false, false},
+		{"log.csv", "", false, "csv", false, false},
+		{"log.CSV.gz", "", false, "csv", true, false},
+		{"log.ndjson.gz", "", false, "ndjson", true, false},
+		{"log.txt", "csv", true, "csv", true, false},
+		{"log.json", "yaml", false, "", false, true},
+	}
+
+	for _, tt := range tests {
+		format, gz, err := exportFileFormat(tt.file, tt.format, tt.gzip)
+		if (err != nil) != tt.err || format != tt.wantFormat || gz != tt.wantGzip {
+			t.Errorf("exportFileFormat(%q, %q, %v) = %q, %v, %v", tt.file, tt.format, tt.gzip, format, gz, err)
+		}
+	}
+}
+
+func Test_exportWriter_resume(t *testing.T) {
+	page := func(names ...string) []client.LogEntry {
+		var ret []client.LogEntry
+		for _, n := range names {
+			e := client.LogEntry{Time: "2025-01-01T00:00:00Z", Client: "10.0.0.1"}
+			e.Question.Name = n
+			ret = append(ret, e)
+		}
+		return ret
+	}
+
+	// a first run, then a resumed one appending to the same gzipped file
+	var buf bytes.Buffer
+	w := newExportWriter(&buf, "csv", true, true)
+	if err := w.write(page("a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	w = newExportWriter(&buf, "csv", true, false)
+	if err := w.write(page("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(got)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "time,") || !strings.Contains(lines[3], ",c,") {
+		t.Errorf("got\n%s", got)
+	}
+}
+
+func Test_exportWriter_truncate(t *testing.T) {
+	entry := func(name string) []client.LogEntry {
+		e := client.LogEntry{Time: "2025-01-01T00:00:00Z", Client: "10.0.0.1"}
+		e.Question.Name = name
+		return []client.LogEntry{e}
+	}
+
+	// a page the cursor saw, then one it didn't before the export stopped
+	var buf bytes.Buffer
+	w := newExportWriter(&buf, "ndjson", true, true)
+	if err := w.write(entry("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	offset := buf.Len()
+	if err := w.write(entry("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// --resume cuts it back to the cursor and carries on
+	buf.Truncate(offset)
+	w = newExportWriter(&buf, "ndjson", true, false)
+	if err := w.write(entry("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(got), "\n"); n != 2 || !strings.Contains(string(got), `"a"`) || !strings.Contains(string(got), `"c"`) || strings.Contains(string(got), `"b"`) {
+		t.Errorf("got\n%s", got)
+	}
+}
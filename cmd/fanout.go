@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+var parallelFlag int
+var serverTimeoutFlag time.Duration
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&parallelFlag, "parallel", common.DefaultParallel, "Maximum number of servers to talk to at once")
+	rootCmd.PersistentFlags().DurationVar(&serverTimeoutFlag, "server-timeout", 0, "Overall time limit for each server when targeting several (0 means no limit beyond --timeout)")
+}
+
+// fanOut runs fn against every server using the --parallel and --server-timeout settings
+func fanOut[T any](ctx context.Context, servers []common.ServerConfig, fn func(ctx context.Context, server *common.ServerConfig) (T, error)) []common.FanOutResult[T] {
+	return common.FanOut(ctx, servers, parallelFlag, serverTimeoutFlag, fn)
+}
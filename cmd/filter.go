@@ -6,126 +6,284 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/url"
+	"strings"
 
+	"github.com/ewosborne/adctl/client"
 	"github.com/ewosborne/adctl/common"
 )
 
 // filterCmd represents the filter command
 var filterCmd = &cobra.Command{
 	Use:   "filter",
-	Short: "Check filter for entities",
+	Short: "Manage filter lists and check hosts against them",
+	Long: `Manage the block and allow lists: list, add, remove, rename, enable, disable,
+set-url and refresh them. check says whether a host would be filtered, and by
+which rules. Custom rules are under adctl rules.`,
 }
 
 // checkCmd represents the check command
 var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Check filters for a specific host, see if and where it's blocked. Single parameter required.",
-	Long:  `long help TBD`,
-	RunE:  CheckFilterCmdE,
+	Long: `Check whether AdGuard Home would filter a host, and by which rules.
+
+--client checks as a particular client, by IP address or ClientID, so that
+client's own settings and $client rules apply. --qtype checks a record type
+other than A.
+
+--explain says in plain words what would happen and names the list each
+matching rule comes from.
+
+--file checks every name in a file, or stdin with -, at once on each server
+and shows a table of each name's verdict per server. The file has a name per
+line; comments, hosts file lines and ||example.com^ rules are understood, so a
+block list can be checked as it is. The counts for each server are in the
+summary, printed under a table or csv.`,
+	Example: `  adctl filter check www.doubleclick.net
+  adctl filter check --explain --client 192.168.1.20 --qtype AAAA ads.example.com
+  adctl filter check -s all --file domains.txt --only-blocked -o table`,
+	RunE: CheckFilterCmdE,
 }
 
+var checkFilterExplain bool
+var checkFilterClient string
+var checkFilterQType string
+var checkFilterFile string
+var checkFilterConcurrency int
+var checkFilterOnlyBlocked bool
+var checkFilterOnlyAllowed bool
+
 func init() {
 	rootCmd.AddCommand(filterCmd)
 	filterCmd.AddCommand(checkCmd)
 
+	checkCmd.Flags().BoolVar(&checkFilterExplain, "explain", false, "Explain the result and name the list each rule is from")
+	checkCmd.Flags().StringVar(&checkFilterClient, "client", "", "Check as this client, an IP address or ClientID")
+	checkCmd.Flags().StringVar(&checkFilterQType, "qtype", "", "DNS record type to check (default A)")
+	checkCmd.Flags().StringVarP(&checkFilterFile, "file", "f", "", "Check every name in this file, - for stdin")
+	checkCmd.Flags().IntVar(&checkFilterConcurrency, "concurrency", 8, "With --file, how many names to check at once on each server")
+	checkCmd.Flags().BoolVar(&checkFilterOnlyBlocked, "only-blocked", false, "With --file, only show names blocked on at least one server")
+	checkCmd.Flags().BoolVar(&checkFilterOnlyAllowed, "only-allowed", false, "With --file, only show names no server blocks")
+	checkCmd.MarkFlagsMutuallyExclusive("only-blocked", "only-allowed")
 }
 
 type CheckFilterArgs struct {
-	name string
+	name    string
+	client  string
+	qtype   string
+	explain bool
 }
 
 func CheckFilterCmdE(cmd *cobra.Command, args []string) error {
-	if len(args) != 1 {
-		return fmt.Errorf("need exactly one argument to checkfilter")
+	ctx := cmd.Context()
+	if checkFilterFile != "" {
+		if len(args) != 0 {
+			return fmt.Errorf("give names to check either as an argument or in --file, not both")
+		}
+	} else {
+		if len(args) != 1 {
+			return fmt.Errorf("need exactly one argument to checkfilter")
+		}
+		if checkFilterOnlyBlocked || checkFilterOnlyAllowed {
+			return fmt.Errorf("--only-blocked and --only-allowed need --file")
+		}
 	}
 
-	cfa := CheckFilterArgs{name: args[0]}
-
-	return PrintFilter(cfa)
-
-}
+	if checkFilterQType != "" && !isDNSType(checkFilterQType) {
+		return fmt.Errorf("--qtype %q isn't a DNS record type", checkFilterQType)
+	}
 
-func PrintFilter(cfa CheckFilterArgs) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
+	cfa := CheckFilterArgs{
+		client:  checkFilterClient,
+		qtype:   strings.ToUpper(checkFilterQType),
+		explain: checkFilterExplain,
 	}
 
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return GetFilterAll(servers, cfa)
+	if checkFilterFile != "" {
+		return bulkCheckCmdE(ctx, cfa)
 	}
 
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
+	cfa.name = args[0]
+	return PrintFilter(ctx, cfa)
+
+}
+
+func PrintFilter(ctx context.Context, cfa CheckFilterArgs) error {
+	if cfa.explain {
+		return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (FilterExplanation, error) {
+			return ExplainFilter(ctx, server, cfa)
+		})
 	}
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (client.CheckHostResult, error) {
+		return GetFilter(ctx, server, cfa)
+	})
+}
 
-	body, err := GetFilter(server, cfa)
+func GetFilter(ctx context.Context, server *common.ServerConfig, cfa CheckFilterArgs) (client.CheckHostResult, error) {
+	c, err := newClient(server)
 	if err != nil {
-		return err
+		return client.CheckHostResult{}, err
 	}
-	fmt.Println(body.String())
-	return nil
+
+	return c.CheckHost(ctx, client.CheckHostParams{Name: cfa.name, Client: cfa.client, QType: cfa.qtype})
 }
 
-func GetFilter(server *common.ServerConfig, cfa CheckFilterArgs) (bytes.Buffer, error) {
-	var ret bytes.Buffer
+// FilterExplanation is what filter check --explain prints
+type FilterExplanation struct {
+	Name   string `json:"name"`
+	Client string `json:"client,omitempty"`
+	QType  string `json:"qtype,omitempty"`
+	// Verdict is blocked, allowed, rewritten or not filtered
+	Verdict     string          `json:"verdict"`
+	Reason      string          `json:"reason"`
+	Explanation string          `json:"explanation"`
+	Rules       []ExplainedRule `json:"rules"`
+	ServiceName string          `json:"service_name,omitempty"`
+	CNAME       string          `json:"cname,omitempty"`
+	IPAddrs     []string        `json:"ip_addrs,omitempty"`
+}
 
-	baseURL, err := common.GetBaseURL(server)
-	if err != nil {
-		return ret, err
-	}
-	baseURL.Path = "/control/filtering/check_host"
-	queryValues := url.Values{}
-	queryValues.Add("name", cfa.name)
+// ExplainedRule is a matching rule along with the list it's from
+type ExplainedRule struct {
+	Text     string `json:"text"`
+	ListID   int64  `json:"list_id"`
+	ListName string `json:"list_name"`
+	ListURL  string `json:"list_url,omitempty"`
+	// ListType is block or allow for a filter list, empty for anything else
+	ListType string `json:"list_type,omitempty"`
+}
 
-	baseURL.RawQuery = queryValues.Encode()
+func (f FilterExplanation) Table() ([]string, [][]string) {
+	headers := []string{"name", "verdict", "explanation", "rule", "list"}
+	if len(f.Rules) == 0 {
+		return headers, [][]string{{f.Name, f.Verdict, f.Explanation, "", ""}}
+	}
+	rows := make([][]string, len(f.Rules))
+	for i, r := range f.Rules {
+		rows[i] = []string{f.Name, f.Verdict, f.Explanation, r.Text, r.ListName}
+	}
+	return headers, rows
+}
 
-	statusQuery := common.CommandArgs{
-		Method: "GET",
-		URL:    baseURL,
-		Server: server,
+func ExplainFilter(ctx context.Context, server *common.ServerConfig, cfa CheckFilterArgs) (FilterExplanation, error) {
+	c, err := newClient(server)
+	if err != nil {
+		return FilterExplanation{}, err
 	}
 
-	body, err := common.SendCommand(statusQuery)
+	result, err := c.CheckHost(ctx, client.CheckHostParams{Name: cfa.name, Client: cfa.client, QType: cfa.qtype})
 	if err != nil {
-		return ret, err
+		return FilterExplanation{}, err
 	}
 
-	json.Indent(&ret, body, "", "  ")
+	// only rules from filter lists need the lists' names
+	var status client.FilteringStatus
+	if len(result.Rules) > 0 || result.Rule != "" {
+		if status, err = c.FilteringStatus(ctx); err != nil {
+			return FilterExplanation{}, err
+		}
+	}
 
-	return ret, nil
+	return explainCheck(cfa, result, status), nil
 }
 
-func GetFilterAll(servers []common.ServerConfig, cfa CheckFilterArgs) error {
-	type ServerResult struct {
-		Server string `json:"server"`
-		Result string `json:"result,omitempty"`
-		Error  string `json:"error,omitempty"`
+// checkReasons are the verdict and plain words for each check_host reason
+var checkReasons = map[string]struct{ verdict, text string }{
+	"NotFilteredNotFound":    {"not filtered", "no rule matched"},
+	"NotFilteredWhiteList":   {"allowed", "an allowlist rule matched"},
+	"NotFilteredError":       {"not filtered", "AdGuard Home had an error checking it"},
+	"FilteredBlackList":      {"blocked", "a blocklist rule matched"},
+	"FilteredSafeBrowsing":   {"blocked", "Safe Browsing lists it as malware or phishing"},
+	"FilteredParental":       {"blocked", "Parental Control lists it as adult content"},
+	"FilteredInvalid":        {"blocked", "it isn't a valid name"},
+	"FilteredSafeSearch":     {"rewritten", "Safe Search sends it to the search engine's safe version"},
+	"FilteredBlockedService": {"blocked", "it belongs to a blocked service"},
+	"Rewrite":                {"rewritten", "a DNS rewrite matched"},
+	"RewriteEtcHosts":        {"rewritten", "the server's hosts file has it"},
+	"RewriteRule":            {"rewritten", "a $dnsrewrite rule matched"},
+}
+
+// specialLists are the filter_list_id values AdGuard Home uses for rules that
+// don't come from a filter list
+var specialLists = map[int64]string{
+	0:  "custom filtering rules", // older servers
+	-1: "custom filtering rules",
+	-2: "hosts file",
+	-3: "blocked services",
+	-4: "parental control",
+	-5: "safe browsing",
+	-6: "safe search",
+}
+
+// explainCheck turns a check_host result into plain words, naming the list
+// each rule is from out of status
+func explainCheck(cfa CheckFilterArgs, result client.CheckHostResult, status client.FilteringStatus) FilterExplanation {
+	ret := FilterExplanation{
+		Name:        cfa.name,
+		Client:      cfa.client,
+		QType:       cfa.qtype,
+		Reason:      result.Reason,
+		Rules:       []ExplainedRule{},
+		ServiceName: result.ServiceName,
+		CNAME:       result.CNAME,
+		IPAddrs:     result.IPAddrs,
 	}
 
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		filterResult, err := GetFilter(&server, cfa)
-		if err != nil {
-			result.Error = err.Error()
+	reason, ok := checkReasons[result.Reason]
+	if !ok {
+		reason.verdict = "unknown"
+		reason.text = fmt.Sprintf("adctl doesn't know the reason %q", result.Reason)
+	}
+	ret.Verdict = reason.verdict
+	ret.Explanation = reason.text
+
+	switch {
+	case result.ServiceName != "":
+		ret.Explanation += " (" + result.ServiceName + ")"
+	case result.CNAME != "":
+		ret.Explanation += ", answering with " + result.CNAME
+	case len(result.IPAddrs) > 0:
+		ret.Explanation += ", answering with " + strings.Join(result.IPAddrs, ", ")
+	}
+
+	// an allowlist rule comes from an allow list, anything else from a block
+	// list; the two can share IDs
+	first, second := status.Filters, status.WhitelistFilters
+	firstType, secondType := "block", "allow"
+	if result.Reason == "NotFilteredWhiteList" {
+		first, second = second, first
+		firstType, secondType = secondType, firstType
+	}
+
+	rules := result.Rules
+	if len(rules) == 0 && result.Rule != "" {
+		// older servers only give the one rule
+		rules = []client.CheckHostRule{{Text: result.Rule, FilterListID: result.FilterID}}
+	}
+	for _, r := range rules {
+		er := ExplainedRule{Text: r.Text, ListID: r.FilterListID}
+		if name, ok := specialLists[r.FilterListID]; ok {
+			er.ListName = name
+		} else if f, ok := filterByID(first, r.FilterListID); ok {
+			er.ListName, er.ListURL, er.ListType = f.Name, f.URL, firstType
+		} else if f, ok := filterByID(second, r.FilterListID); ok {
+			er.ListName, er.ListURL, er.ListType = f.Name, f.URL, secondType
 		} else {
-			result.Result = filterResult.String()
+			er.ListName = fmt.Sprintf("list %d, no longer on the server", r.FilterListID)
 		}
-		results = append(results, result)
+		ret.Rules = append(ret.Rules, er)
 	}
 
-	output, err := json.MarshalIndent(results, "", "  ")
-	if err != nil {
-		return err
+	return ret
+}
+
+func filterByID(filters []client.Filter, id int64) (client.Filter, bool) {
+	for _, f := range filters {
+		if f.ID == id {
+			return f, true
+		}
 	}
-	fmt.Println(string(output))
-	return nil
+	return client.Filter{}, false
 }
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"reflect"
 	"testing"
+
+	"github.com/ewosborne/adctl/client"
 )
 
 func TestFilterCheck(t *testing.T) {
@@ -11,8 +15,73 @@ func TestFilterCheck(t *testing.T) {
 	}
 
 	cfa := CheckFilterArgs{name: "www.doubleclick.net"}
-	_, err := GetFilter(nil, cfa)
+	_, err := GetFilter(context.Background(), nil, cfa)
 	if err != nil {
 		t.Errorf("error in GetFilter: %v", err)
 	}
 }
+
+func Test_explainCheck(t *testing.T) {
+	status := client.FilteringStatus{
+		Filters:          []client.Filter{{ID: 1, Name: "AdGuard DNS filter", URL: "https://example.com/1.txt"}},
+		WhitelistFilters: []client.Filter{{ID: 1, Name: "Work", URL: "https://example.com/allow.txt"}},
+	}
+	cfa := CheckFilterArgs{name: "ads.example.com", client: "192.168.1.20"}
+
+	tests := []struct {
+		name   string
+		result client.CheckHostResult
+		want   FilterExplanation
+	}{
+		{
+			name: "blocked",
+			result: client.CheckHostResult{Reason: "FilteredBlackList", Rules: []client.CheckHostRule{
+				{Text: "||example.com^", FilterListID: 1},
+				{Text: "||ads.example.com^", FilterListID: -1},
+			}},
+			want: FilterExplanation{Verdict: "blocked", Explanation: "a blocklist rule matched", Rules: []ExplainedRule{
+				{Text: "||example.com^", ListID: 1, ListName: "AdGuard DNS filter", ListURL: "https://example.com/1.txt", ListType: "block"},
+				{Text: "||ads.example.com^", ListID: -1, ListName: "custom filtering rules"},
+			}},
+		},
+		{
+			name:   "allowed",
+			result: client.CheckHostResult{Reason: "NotFilteredWhiteList", Rules: []client.CheckHostRule{{Text: "@@||example.com^", FilterListID: 1}}},
+			want: FilterExplanation{Verdict: "allowed", Explanation: "an allowlist rule matched", Rules: []ExplainedRule{
+				{Text: "@@||example.com^", ListID: 1, ListName: "Work", ListURL: "https://example.com/allow.txt", ListType: "allow"},
+			}},
+		},
+		{
+			name:   "old server, gone list",
+			result: client.CheckHostResult{Reason: "FilteredBlackList", Rule: "||example.com^", FilterID: 7},
+			want: FilterExplanation{Verdict: "blocked", Explanation: "a blocklist rule matched", Rules: []ExplainedRule{
+				{Text: "||example.com^", ListID: 7, ListName: "list 7, no longer on the server"},
+			}},
+		},
+		{
+			name:   "rewrite",
+			result: client.CheckHostResult{Reason: "Rewrite", CNAME: "example.org"},
+			want:   FilterExplanation{Verdict: "rewritten", Explanation: "a DNS rewrite matched, answering with example.org", CNAME: "example.org", Rules: []ExplainedRule{}},
+		},
+		{
+			name:   "service",
+			result: client.CheckHostResult{Reason: "FilteredBlockedService", ServiceName: "tiktok"},
+			want:   FilterExplanation{Verdict: "blocked", Explanation: "it belongs to a blocked service (tiktok)", ServiceName: "tiktok", Rules: []ExplainedRule{}},
+		},
+		{
+			name:   "not filtered",
+			result: client.CheckHostResult{Reason: "NotFilteredNotFound", Rules: []client.CheckHostRule{}},
+			want:   FilterExplanation{Verdict: "not filtered", Explanation: "no rule matched", Rules: []ExplainedRule{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Name, tt.want.Client, tt.want.Reason = cfa.name, cfa.client, tt.result.Reason
+			got := explainCheck(cfa, tt.result, status)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
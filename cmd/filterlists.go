@@ -0,0 +1,380 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+)
+
+// filterListRefHelp explains the LIST argument, for the Long help
+const filterListRefHelp = `LIST is the list's URL, its ID or its name. IDs can differ between servers,
+URLs don't. Both block and allow lists are searched unless --whitelist says
+which.`
+
+var filterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the block and allow lists",
+	Args:  cobra.NoArgs,
+	RunE:  filterListCmdE,
+}
+
+var filterAddCmd = &cobra.Command{
+	Use:   "add URL",
+	Short: "Add a block list, or an allow list with --whitelist",
+	Long: `Add a block list, or an allow list with --whitelist. URL can also be the path
+of a file on the server. AdGuard Home downloads the list before it's added.`,
+	Example: `  adctl filter add https://adguardteam.github.io/HostlistsRegistry/assets/filter_9.txt --name "The Big List of Hacked Malware Web Sites"
+  adctl filter add -s all https://example.com/allow.txt --whitelist`,
+	Args: cobra.ExactArgs(1),
+	RunE: filterAddCmdE,
+	// AdGuard Home downloads the lists before answering
+	Annotations: map[string]string{timeoutAnnotation: "2m"},
+}
+
+var filterRemoveCmd = &cobra.Command{
+	Use:   "remove LIST",
+	Short: "Remove a block or allow list",
+	Long:  "Remove a block or allow list.\n\n" + filterListRefHelp,
+	Args:  cobra.ExactArgs(1),
+	RunE:  filterRemoveCmdE,
+}
+
+var filterSetURLCmd = &cobra.Command{
+	Use:   "set-url LIST URL",
+	Short: "Point a block or allow list at a new URL",
+	Long:  "Point a block or allow list at a new URL, keeping its name and whether it's enabled.\n\n" + filterListRefHelp,
+	Args:  cobra.ExactArgs(2),
+	RunE:  filterSetURLCmdE,
+	// AdGuard Home downloads the list before answering
+	Annotations: map[string]string{timeoutAnnotation: "2m"},
+}
+
+var filterRenameCmd = &cobra.Command{
+	Use:   "rename LIST NAME",
+	Short: "Rename a block or allow list",
+	Long:  "Rename a block or allow list.\n\n" + filterListRefHelp,
+	Args:  cobra.ExactArgs(2),
+	RunE:  filterRenameCmdE,
+}
+
+var filterEnableCmd = &cobra.Command{
+	Use:   "enable LIST",
+	Short: "Start using a block or allow list",
+	Long:  "Start using a block or allow list.\n\n" + filterListRefHelp,
+	Args:  cobra.ExactArgs(1),
+	RunE:  func(cmd *cobra.Command, args []string) error { return setFilterEnabled(cmd, args[0], true) },
+}
+
+var filterDisableCmd = &cobra.Command{
+	Use:   "disable LIST",
+	Short: "Stop using a block or allow list without removing it",
+	Long:  "Stop using a block or allow list without removing it.\n\n" + filterListRefHelp,
+	Args:  cobra.ExactArgs(1),
+	RunE:  func(cmd *cobra.Command, args []string) error { return setFilterEnabled(cmd, args[0], false) },
+}
+
+var filterRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Download the block lists again, or the allow lists with --whitelist",
+	Long: `Download the enabled block lists again, or the allow lists with --whitelist,
+and show which of them changed.`,
+	Args: cobra.NoArgs,
+	RunE: filterRefreshCmdE,
+	// AdGuard Home downloads the lists before answering
+	Annotations: map[string]string{timeoutAnnotation: "2m"},
+}
+
+var filterWhitelist bool
+var filterAddName string
+
+func init() {
+	filterCmd.AddCommand(filterListCmd)
+	filterCmd.AddCommand(filterAddCmd)
+	filterCmd.AddCommand(filterRemoveCmd)
+	filterCmd.AddCommand(filterSetURLCmd)
+	filterCmd.AddCommand(filterRenameCmd)
+	filterCmd.AddCommand(filterEnableCmd)
+	filterCmd.AddCommand(filterDisableCmd)
+	filterCmd.AddCommand(filterRefreshCmd)
+
+	filterListCmd.Flags().BoolVar(&filterWhitelist, "whitelist", false, "Only allow lists, or with --whitelist=false only block lists")
+	filterAddCmd.Flags().BoolVar(&filterWhitelist, "whitelist", false, "Add an allow list rather than a block list")
+	filterAddCmd.Flags().StringVar(&filterAddName, "name", "", "Name to show for the list (default is the URL)")
+	filterRefreshCmd.Flags().BoolVar(&filterWhitelist, "whitelist", false, "Refresh the allow lists rather than the block lists")
+	for _, c := range []*cobra.Command{filterRemoveCmd, filterSetURLCmd, filterRenameCmd, filterEnableCmd, filterDisableCmd} {
+		c.Flags().BoolVar(&filterWhitelist, "whitelist", false, "Only look for LIST among the allow lists, or with --whitelist=false the block lists")
+	}
+}
+
+// FilterList is one block or allow list as filter list prints it
+type FilterList struct {
+	ID          int64  `json:"id"`
+	Type        string `json:"type"`
+	Enabled     bool   `json:"enabled"`
+	Name        string `json:"name"`
+	RulesCount  uint32 `json:"rules_count"`
+	LastUpdated string `json:"last_updated"`
+	URL         string `json:"url"`
+}
+
+func newFilterList(f client.Filter, whitelist bool) FilterList {
+	return FilterList{
+		ID:          f.ID,
+		Type:        filterListType(whitelist),
+		Enabled:     f.Enabled,
+		Name:        f.Name,
+		RulesCount:  f.RulesCount,
+		LastUpdated: f.LastUpdated,
+		URL:         f.URL,
+	}
+}
+
+func filterListType(whitelist bool) string {
+	if whitelist {
+		return "allow"
+	}
+	return "block"
+}
+
+// filterLists is the block lists then the allow lists, narrowed to one kind
+// if cmd's --whitelist flag was given
+func filterLists(cmd *cobra.Command, status client.FilteringStatus) []FilterList {
+	only := cmd.Flags().Changed("whitelist")
+
+	ret := []FilterList{}
+	if !only || !filterWhitelist {
+		for _, f := range status.Filters {
+			ret = append(ret, newFilterList(f, false))
+		}
+	}
+	if !only || filterWhitelist {
+		for _, f := range status.WhitelistFilters {
+			ret = append(ret, newFilterList(f, true))
+		}
+	}
+	return ret
+}
+
+// findFilterList finds the list ref names, by URL, then ID, then name
+func findFilterList(lists []FilterList, ref string) (FilterList, error) {
+	matchers := []func(FilterList) bool{
+		func(l FilterList) bool { return l.URL == ref },
+		func(l FilterList) bool { return strconv.FormatInt(l.ID, 10) == ref },
+		func(l FilterList) bool { return strings.EqualFold(l.Name, ref) },
+	}
+
+	for _, match := range matchers {
+		var found []FilterList
+		for _, l := range lists {
+			if match(l) {
+				found = append(found, l)
+			}
+		}
+
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], nil
+		default:
+			var which []string
+			for _, l := range found {
+				which = append(which, fmt.Sprintf("%s list %d", l.Type, l.ID))
+			}
+			return FilterList{}, fmt.Errorf("'%s' matches %s, use --whitelist or the URL to pick one", ref, strings.Join(which, " and "))
+		}
+	}
+
+	return FilterList{}, fmt.Errorf("filter list '%s' not found", ref)
+}
+
+func filterListCmdE(cmd *cobra.Command, args []string) error {
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) ([]FilterList, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return nil, err
+		}
+		status, err := c.FilteringStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return filterLists(cmd, status), nil
+	})
+}
+
+func filterAddCmdE(cmd *cobra.Command, args []string) error {
+	listURL := args[0]
+	name := filterAddName
+	if name == "" {
+		name = listURL
+	}
+
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (FilterList, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return FilterList{}, err
+		}
+		if err := c.AddFilterURL(ctx, name, listURL, filterWhitelist); err != nil {
+			return FilterList{}, err
+		}
+		return lookupFilterList(ctx, c, listURL, filterWhitelist)
+	})
+}
+
+// lookupFilterList fetches the list with this URL, to show what a change did
+func lookupFilterList(ctx context.Context, c *client.Client, listURL string, whitelist bool) (FilterList, error) {
+	status, err := c.FilteringStatus(ctx)
+	if err != nil {
+		return FilterList{}, err
+	}
+
+	lists := status.Filters
+	if whitelist {
+		lists = status.WhitelistFilters
+	}
+	for _, f := range lists {
+		if f.URL == listURL {
+			return newFilterList(f, whitelist), nil
+		}
+	}
+	return FilterList{}, fmt.Errorf("filter list '%s' not found", listURL)
+}
+
+// changeFilterList finds ref on each server and calls change with it, printing
+// what change returns
+func changeFilterList(cmd *cobra.Command, ref string, change func(ctx context.Context, c *client.Client, l FilterList) (FilterList, error)) error {
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (FilterList, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return FilterList{}, err
+		}
+		status, err := c.FilteringStatus(ctx)
+		if err != nil {
+			return FilterList{}, err
+		}
+		l, err := findFilterList(filterLists(cmd, status), ref)
+		if err != nil {
+			return FilterList{}, err
+		}
+		return change(ctx, c, l)
+	})
+}
+
+// setFilterURL applies data to l and fetches the result, found by its new URL
+func setFilterURL(ctx context.Context, c *client.Client, l FilterList, data client.FilterURLData) (FilterList, error) {
+	whitelist := l.Type == "allow"
+	if err := c.SetFilterURL(ctx, l.URL, whitelist, data); err != nil {
+		return FilterList{}, err
+	}
+	return lookupFilterList(ctx, c, data.URL, whitelist)
+}
+
+func filterRemoveCmdE(cmd *cobra.Command, args []string) error {
+	return changeFilterList(cmd, args[0], func(ctx context.Context, c *client.Client, l FilterList) (FilterList, error) {
+		// show what was removed
+		return l, c.RemoveFilterURL(ctx, l.URL, l.Type == "allow")
+	})
+}
+
+func filterSetURLCmdE(cmd *cobra.Command, args []string) error {
+	return changeFilterList(cmd, args[0], func(ctx context.Context, c *client.Client, l FilterList) (FilterList, error) {
+		return setFilterURL(ctx, c, l, client.FilterURLData{Name: l.Name, URL: args[1], Enabled: l.Enabled})
+	})
+}
+
+func filterRenameCmdE(cmd *cobra.Command, args []string) error {
+	return changeFilterList(cmd, args[0], func(ctx context.Context, c *client.Client, l FilterList) (FilterList, error) {
+		return setFilterURL(ctx, c, l, client.FilterURLData{Name: args[1], URL: l.URL, Enabled: l.Enabled})
+	})
+}
+
+func setFilterEnabled(cmd *cobra.Command, ref string, enabled bool) error {
+	return changeFilterList(cmd, ref, func(ctx context.Context, c *client.Client, l FilterList) (FilterList, error) {
+		return setFilterURL(ctx, c, l, client.FilterURLData{Name: l.Name, URL: l.URL, Enabled: enabled})
+	})
+}
+
+// FilterRefresh is what filter refresh prints: how many lists changed, and
+// each list before and after
+type FilterRefresh struct {
+	Updated int                 `json:"updated"`
+	Lists   []FilterRefreshList `json:"lists"`
+}
+
+// FilterRefreshList is one list's rule count before and after a refresh
+type FilterRefreshList struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Updated     bool   `json:"updated"`
+	RulesBefore uint32 `json:"rules_before"`
+	RulesAfter  uint32 `json:"rules_after"`
+	LastUpdated string `json:"last_updated"`
+	URL         string `json:"url"`
+}
+
+// Table is one row per list, leaving out the overall count, which is the number of updated rows
+func (r FilterRefresh) Table() ([]string, [][]string) {
+	header := []string{"id", "name", "updated", "rules_before", "rules_after", "last_updated", "url"}
+	rows := make([][]string, len(r.Lists))
+	for i, l := range r.Lists {
+		rows[i] = []string{fmt.Sprint(l.ID), l.Name, fmt.Sprint(l.Updated), fmt.Sprint(l.RulesBefore), fmt.Sprint(l.RulesAfter), l.LastUpdated, l.URL}
+	}
+	return header, rows
+}
+
+func filterRefreshCmdE(cmd *cobra.Command, args []string) error {
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (FilterRefresh, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return FilterRefresh{}, err
+		}
+
+		// AdGuard Home only says how many lists changed, so compare before and after to say which
+		before, err := c.FilteringStatus(ctx)
+		if err != nil {
+			return FilterRefresh{}, err
+		}
+		updated, err := c.RefreshFilters(ctx, filterWhitelist)
+		if err != nil {
+			return FilterRefresh{}, err
+		}
+		after, err := c.FilteringStatus(ctx)
+		if err != nil {
+			return FilterRefresh{}, err
+		}
+
+		return compareFilterLists(before, after, filterWhitelist, updated), nil
+	})
+}
+
+func compareFilterLists(before, after client.FilteringStatus, whitelist bool, updated int) FilterRefresh {
+	old, lists := before.Filters, after.Filters
+	if whitelist {
+		old, lists = before.WhitelistFilters, after.WhitelistFilters
+	}
+
+	ret := FilterRefresh{Updated: updated, Lists: []FilterRefreshList{}}
+	for _, f := range lists {
+		if !f.Enabled {
+			continue
+		}
+		l := FilterRefreshList{ID: f.ID, Name: f.Name, RulesBefore: f.RulesCount, RulesAfter: f.RulesCount, LastUpdated: f.LastUpdated, URL: f.URL}
+		for _, o := range old {
+			if o.ID == f.ID {
+				l.RulesBefore = o.RulesCount
+				l.Updated = o.LastUpdated != f.LastUpdated || o.RulesCount != f.RulesCount
+			}
+		}
+		ret.Lists = append(ret.Lists, l)
+	}
+	return ret
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ewosborne/adctl/client"
+)
+
+func Test_findFilterList(t *testing.T) {
+	lists := []FilterList{
+		{ID: 1, Type: "block", Name: "AdGuard DNS filter", URL: "https://example.com/1.txt"},
+		{ID: 2, Type: "block", Name: "Shared", URL: "https://example.com/2.txt"},
+		{ID: 2, Type: "allow", Name: "Shared", URL: "https://example.com/allow.txt"},
+	}
+
+	tests := []struct {
+		ref    string
+		wantID int64
+		err    bool
+	}{
+		{"https://example.com/allow.txt", 2, false},
+		{"1", 1, false},
+		{"adguard dns filter", 1, false},
+		{"2", 0, true},
+		{"shared", 0, true},
+		{"nope", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := findFilterList(lists, tt.ref)
+		if (err != nil) != tt.err || got.ID != tt.wantID {
+			t.Errorf("findFilterList(%q) = %d, %v", tt.ref, got.ID, err)
+		}
+	}
+}
+
+func Test_compareFilterLists(t *testing.T) {
+	before := client.FilteringStatus{Filters: []client.Filter{
+		{ID: 1, Enabled: true, RulesCount: 100, LastUpdated: "2025-01-01T00:00:00Z"},
+		{ID: 2, Enabled: true, RulesCount: 50, LastUpdated: "2025-01-01T00:00:00Z"},
+		{ID: 3, Enabled: false, RulesCount: 10},
+	}}
+	after := client.FilteringStatus{Filters: []client.Filter{
+		{ID: 1, Enabled: true, RulesCount: 120, LastUpdated: "2025-02-01T00:00:00Z"},
+		{ID: 2, Enabled: true, RulesCount: 50, LastUpdated: "2025-01-01T00:00:00Z"},
+		{ID: 3, Enabled: false, RulesCount: 10},
+	}}
+
+	got := compareFilterLists(before, after, false, 1)
+	want := FilterRefresh{Updated: 1, Lists: []FilterRefreshList{
+		{ID: 1, Updated: true, RulesBefore: 100, RulesAfter: 120, LastUpdated: "2025-02-01T00:00:00Z"},
+		{ID: 2, RulesBefore: 50, RulesAfter: 50, LastUpdated: "2025-01-01T00:00:00Z"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	if got := compareFilterLists(before, after, true, 0); len(got.Lists) != 0 {
+		t.Errorf("allow lists: got %+v", got.Lists)
+	}
+}
@@ -0,0 +1,389 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var statsGraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Chart queries and blocks over the stats window",
+	Long: `Chart the hourly or daily stats as sparklines, or as horizontal bars with one
+row per hour or day. With several servers each server gets its own chart,
+followed by the total, all drawn to the same scale.
+
+The chart is plain text. Give -o, --query or --template to get the numbers instead.`,
+	Args: cobra.NoArgs,
+	RunE: statsGraphCmdE,
+}
+
+var graphSeries []string
+var graphStyle string
+var graphWidth int
+
+func init() {
+	statsCmd.AddCommand(statsGraphCmd)
+
+	statsGraphCmd.Flags().StringSliceVar(&graphSeries, "series", []string{"queries", "blocked"}, "Series to chart: "+strings.Join(graphSeriesNames(), ", "))
+	statsGraphCmd.Flags().StringVar(&graphStyle, "style", "spark", "Chart style: spark or bars")
+	statsGraphCmd.Flags().IntVar(&graphWidth, "width", 0, "Chart width in columns (default is the terminal width)")
+
+	statsGraphCmd.RegisterFlagCompletionFunc("series", cobra.FixedCompletions(graphSeriesNames(), cobra.ShellCompDirectiveNoFileComp))
+	statsGraphCmd.RegisterFlagCompletionFunc("style", cobra.FixedCompletions([]string{"spark", "bars"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// graphSources are the time series /control/stats returns, by the names --series takes
+var graphSources = []struct {
+	name string
+	get  func(client.Stats) []uint64
+}{
+	{"queries", func(s client.Stats) []uint64 { return s.DNSQueries }},
+	{"blocked", func(s client.Stats) []uint64 { return s.BlockedFiltering }},
+	{"safebrowsing", func(s client.Stats) []uint64 { return s.ReplacedSafebrowsing }},
+	{"parental", func(s client.Stats) []uint64 { return s.ReplacedParental }},
+}
+
+func graphSeriesNames() []string {
+	names := make([]string, len(graphSources))
+	for i, s := range graphSources {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Series is one named time series, oldest first
+type Series struct {
+	Name   string   `json:"name"`
+	Values []uint64 `json:"values"`
+}
+
+// StatsGraph is the data behind one chart
+type StatsGraph struct {
+	TimeUnits string   `json:"time_units"`
+	Series    []Series `json:"series"`
+}
+
+// StatsGraphAll is the data behind the charts for several servers
+type StatsGraphAll struct {
+	Servers ServerResults[StatsGraph] `json:"servers"`
+	Total   StatsGraph                `json:"total"`
+}
+
+func newStatsGraph(s client.Stats, names []string) StatsGraph {
+	ret := StatsGraph{TimeUnits: s.TimeUnits}
+	for _, name := range names {
+		for _, src := range graphSources {
+			if src.name == name {
+				values := src.get(s)
+				if values == nil {
+					values = []uint64{}
+				}
+				ret.Series = append(ret.Series, Series{Name: name, Values: values})
+			}
+		}
+	}
+	return ret
+}
+
+func statsGraphCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	for _, name := range graphSeries {
+		if !slices.Contains(graphSeriesNames(), name) {
+			return fmt.Errorf("unknown series %q, must be one of %s", name, strings.Join(graphSeriesNames(), ", "))
+		}
+	}
+	if graphStyle != "spark" && graphStyle != "bars" {
+		return fmt.Errorf("unknown style %q, must be spark or bars", graphStyle)
+	}
+
+	// the numbers rather than a picture if any output option was asked for
+	wantData := cmd.Flags().Changed("output") || queryFlag != "" || templateFlag != ""
+
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+
+	width := graphWidth
+	if width <= 0 {
+		width = 80
+		if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+			width = w
+		}
+	}
+
+	if !isMultiServer(servers) {
+		var server *common.ServerConfig
+		if len(servers) > 0 {
+			server = &servers[0]
+		}
+		s, err := getStats(ctx, server)
+		if err != nil {
+			return err
+		}
+
+		g := newStatsGraph(s, graphSeries)
+		if wantData {
+			return render(g)
+		}
+		return drawGraphs(os.Stdout, graphStyle, width, []string{""}, []StatsGraph{g})
+	}
+
+	fanned := fanOut(ctx, servers, getStats)
+
+	var ok []client.Stats
+	graphs := make([]common.FanOutResult[StatsGraph], len(fanned))
+	var labels []string
+	var drawn []StatsGraph
+	for i, r := range fanned {
+		graphs[i] = common.FanOutResult[StatsGraph]{Server: r.Server, Err: r.Err, Duration: r.Duration}
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.Server, r.Err)
+			continue
+		}
+		graphs[i].Value = newStatsGraph(r.Value, graphSeries)
+		ok = append(ok, r.Value)
+		labels = append(labels, r.Server)
+		drawn = append(drawn, graphs[i].Value)
+	}
+
+	out := StatsGraphAll{
+		Servers: NewServerResults(graphs),
+		Total:   newStatsGraph(mergeStats(ok), graphSeries),
+	}
+
+	switch {
+	case wantData:
+		err = render(out)
+	case out.Total.TimeUnits == "mixed":
+		// hours and days can't share a chart, so each server gets its own
+		fmt.Fprintln(os.Stderr, "some servers keep stats by the hour and some by the day, so there's no total")
+		for i := range drawn {
+			if i > 0 {
+				fmt.Fprintln(os.Stdout)
+			}
+			if err = drawGraphs(os.Stdout, graphStyle, width, labels[i:i+1], drawn[i:i+1]); err != nil {
+				break
+			}
+		}
+	case len(ok) > 0:
+		err = drawGraphs(os.Stdout, graphStyle, width, append(labels, "total"), append(drawn, out.Total))
+	}
+	if err != nil {
+		return err
+	}
+	return common.FanOutErr(fanned)
+}
+
+// drawGraphs draws one chart per graph, each labelled (an empty label for a lone server)
+func drawGraphs(w io.Writer, style string, width int, labels []string, graphs []StatsGraph) error {
+	if len(graphs) == 0 {
+		return nil
+	}
+
+	units := graphs[0].TimeUnits
+	if units == "" {
+		units = "hours"
+	}
+
+	if style == "bars" {
+		// one scale for every chart, so servers can be compared by eye
+		var peak uint64
+		for _, g := range graphs {
+			for _, s := range g.Series {
+				for _, v := range s.Values {
+					peak = max(peak, v)
+				}
+			}
+		}
+
+		for i, g := range graphs {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			if labels[i] != "" {
+				fmt.Fprintln(w, labels[i])
+			}
+			if err := drawBars(w, width, peak, g); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return drawSparklines(w, width, units, labels, graphs)
+}
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// drawSparklines draws a sparkline per series. Every series shares a scale, so
+// blocked can be compared with queries, and one server with another, by eye.
+func drawSparklines(w io.Writer, width int, units string, labels []string, graphs []StatsGraph) error {
+	labelWidth, nameWidth, length := 0, 0, 0
+	for i, g := range graphs {
+		labelWidth = max(labelWidth, len(labels[i]))
+		for _, s := range g.Series {
+			nameWidth = max(nameWidth, len(s.Name))
+			length = max(length, len(s.Values))
+		}
+	}
+
+	// room for "label  name  " on the left and "  total N  peak N" on the right
+	avail := width - labelWidth - nameWidth - 4 - 30
+	if labelWidth > 0 {
+		avail -= 2
+	}
+	group := 1
+	if avail > 0 && length > avail {
+		group = (length + avail - 1) / avail
+	}
+
+	unit := strings.TrimSuffix(units, "s")
+	per := unit
+	if group > 1 {
+		per = fmt.Sprintf("%d %s", group, units)
+	}
+	fmt.Fprintf(w, "last %d %s, oldest on the left, one column per %s\n", length, units, per)
+
+	var peak uint64
+	for _, g := range graphs {
+		for _, s := range g.Series {
+			for _, v := range regroup(s.Values, group, length) {
+				peak = max(peak, v)
+			}
+		}
+	}
+
+	for i, g := range graphs {
+		for j, s := range g.Series {
+			label := ""
+			if j == 0 {
+				label = labels[i]
+			}
+
+			var line strings.Builder
+			if labelWidth > 0 {
+				fmt.Fprintf(&line, "%-*s  ", labelWidth, label)
+			}
+			fmt.Fprintf(&line, "%-*s  ", nameWidth, s.Name)
+
+			var total, seriesPeak uint64
+			for _, v := range s.Values {
+				total += v
+			}
+			for _, v := range regroup(s.Values, group, length) {
+				seriesPeak = max(seriesPeak, v)
+				line.WriteRune(sparkTick(v, peak))
+			}
+			fmt.Fprintf(&line, "  total %d  peak %d", total, seriesPeak)
+
+			if _, err := fmt.Fprintln(w, line.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sparkTick(v, peak uint64) rune {
+	if peak == 0 || v == 0 {
+		return sparkTicks[0]
+	}
+	i := int(v * uint64(len(sparkTicks)-1) / peak)
+	return sparkTicks[i]
+}
+
+// regroup pads values at the front to length, so series line up at the most
+// recent end, then sums each run of group values into one
+func regroup(values []uint64, group int, length int) []uint64 {
+	padded := make([]uint64, length-len(values), length)
+	padded = append(padded, values...)
+	if group <= 1 {
+		return padded
+	}
+
+	var ret []uint64
+	for i := 0; i < len(padded); i += group {
+		var sum uint64
+		for _, v := range padded[i:min(i+group, len(padded))] {
+			sum += v
+		}
+		ret = append(ret, sum)
+	}
+	return ret
+}
+
+var barFills = []rune("░█▓▒")
+
+// drawBars draws one row per hour or day with the series overlaid on one bar,
+// each drawn over the one before it, so blocked shows up as part of queries.
+// A bar of peak fills the width.
+func drawBars(w io.Writer, width int, peak uint64, g StatsGraph) error {
+	length := 0
+	for _, s := range g.Series {
+		length = max(length, len(s.Values))
+	}
+
+	unit := "h"
+	if g.TimeUnits == "days" {
+		unit = "d"
+	}
+
+	var legend []string
+	for j, s := range g.Series {
+		legend = append(legend, fmt.Sprintf("%c %s", barFills[j%len(barFills)], s.Name))
+	}
+	fmt.Fprintln(w, strings.Join(legend, "  "))
+
+	numWidth := len(fmt.Sprint(peak))
+	barWidth := max(10, width-6-len(g.Series)*(numWidth+1)-2)
+
+	padded := make([][]uint64, len(g.Series))
+	for j, s := range g.Series {
+		padded[j] = regroup(s.Values, 1, length)
+	}
+
+	for i := range length {
+		age := length - 1 - i
+		label := "now"
+		if age > 0 {
+			label = fmt.Sprintf("-%d%s", age, unit)
+		}
+
+		bar := []rune(strings.Repeat(" ", barWidth))
+		var nums []string
+		for j := range g.Series {
+			v := padded[j][i]
+			n := 0
+			if peak > 0 {
+				n = int(v * uint64(barWidth) / peak)
+			}
+			if v > 0 && n == 0 {
+				n = 1
+			}
+			for k := range n {
+				bar[k] = barFills[j%len(barFills)]
+			}
+			nums = append(nums, fmt.Sprintf("%*d", numWidth, v))
+		}
+
+		line := fmt.Sprintf("%5s %s  %s", label, string(bar), strings.Join(nums, " "))
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_regroup(t *testing.T) {
+	if got := regroup([]uint64{1, 2, 3}, 1, 5); !reflect.DeepEqual(got, []uint64{0, 0, 1, 2, 3}) {
+		t.Errorf("padding: got %v", got)
+	}
+	if got := regroup([]uint64{1, 2, 3, 4, 5}, 2, 5); !reflect.DeepEqual(got, []uint64{3, 7, 5}) {
+		t.Errorf("grouping: got %v", got)
+	}
+}
+
+func Test_drawGraphs(t *testing.T) {
+	graphs := []StatsGraph{
+		{TimeUnits: "hours", Series: []Series{{"queries", []uint64{0, 4, 8, 2}}, {"blocked", []uint64{0, 1, 4, 0}}}},
+		{TimeUnits: "hours", Series: []Series{{"queries", []uint64{7, 7}}, {"blocked", []uint64{0, 7}}}},
+	}
+
+	var buf bytes.Buffer
+	if err := drawGraphs(&buf, "spark", 80, []string{"pi", "total"}, graphs); err != nil {
+		t.Fatal(err)
+	}
+	want := `last 4 hours, oldest on the left, one column per hour
+pi     queries  ▁▄█▂  total 14  peak 8
+       blocked  ▁▁▄▁  total 5  peak 4
+total  queries  ▁▁▇▇  total 14  peak 7
+       blocked  ▁▁▁▇  total 7  peak 7
+`
+	if buf.String() != want {
+		t.Errorf("spark got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := drawGraphs(&buf, "bars", 30, []string{""}, graphs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	want = `░ queries  █ blocked
+  -3h                     0 0
+  -2h ██░░░░░░░           4 1
+  -1h █████████░░░░░░░░░  8 4
+  now ░░░░                2 0
+`
+	if buf.String() != want {
+		t.Errorf("bars got\n%q\nwant\n%q", buf.String(), want)
+	}
+
+	// several charts share a scale
+	buf.Reset()
+	if err := drawGraphs(&buf, "bars", 30, []string{"pi", "total"}, graphs); err != nil {
+		t.Fatal(err)
+	}
+	want = `pi
+░ queries  █ blocked
+  -3h                     0 0
+  -2h ██░░░░░░░           4 1
+  -1h █████████░░░░░░░░░  8 4
+  now ░░░░                2 0
+
+total
+░ queries  █ blocked
+  -1h ░░░░░░░░░░░░░░░     7 0
+  now ███████████████     7 7
+`
+	if buf.String() != want {
+		t.Errorf("shared bars got\n%q\nwant\n%q", buf.String(), want)
+	}
+}
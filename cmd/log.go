@@ -4,13 +4,14 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"math"
-	"net/url"
 	"slices"
+	"strconv"
+	"time"
 
+	"github.com/ewosborne/adctl/client"
 	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/cobra"
 )
@@ -25,7 +26,17 @@ var logCmd = &cobra.Command{
 var getLogCmd = &cobra.Command{
 	Use:   "get",
 	Short: "Get logs. Optional length parameter, 0 == MaxUint32 log length.",
-	RunE:  GetLogCmdE,
+	Long: `Get the newest queries from the query log, 500 unless a length is given.
+
+--filter and --search are passed to AdGuard Home. The other filters (--client,
+--qtype, --reason, --upstream, --cached, --since, --until and --min-latency) are
+applied by adctl, which pages back through the log until it has enough matching
+queries. All filters combine, and a list flag matches any of its values.
+
+More than 500 queries are fetched 500 at a time. To save the whole log to a file
+use log export, which can carry on where it left off.`,
+	RunE:        GetLogCmdE,
+	Annotations: map[string]string{timeoutAnnotation: "5m"},
 }
 
 var filter string
@@ -41,21 +52,33 @@ type LogArgs struct {
 	limit  string
 	filter string
 	search string
+	match  logMatcher
 }
 
+// logPageSize is how many queries to ask for at a time when filtering client-side
+const logPageSize = 500
+
 func init() {
 	rootCmd.AddCommand(logCmd)
 
 	logCmd.AddCommand(getLogCmd)
 	getLogCmd.Flags().StringVarP(&filter, "filter", "", "all", fmt.Sprintf("one of: %#v", allowedFilters))
 	getLogCmd.Flags().StringVarP(&searchQuery, "search", "", "", "string to search for in logs.")
+	addLogFilterFlags(getLogCmd)
 }
 
 func GetLogCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
 
 	//populateLogArgs(args)
 	LogArgsInstance := LogArgs{filter: filter, search: searchQuery}
 
+	match, err := newLogMatcher(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+	LogArgsInstance.match = match
+
 	// if there are no args then do nothing
 	// if there's one arg it's either zero or it's not
 	//   if it's zero then bump it up but in a platform-specific way
@@ -65,6 +88,7 @@ func GetLogCmdE(cmd *cobra.Command, args []string) error {
 		// do nothing
 	case 1:
 		if args[0] == "0" {
+			// all of it, fetched a page at a time
 			args[0] = fmt.Sprintf("%v", uint32(math.MaxUint32))
 		}
 		LogArgsInstance.limit = args[0]
@@ -72,101 +96,131 @@ func GetLogCmdE(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("too many args to GetLogCmdE: %v", len(args))
 	}
 
-	return printLog(LogArgsInstance)
+	return printLog(ctx, LogArgsInstance)
 }
 
-func printLog(queryLogs LogArgs) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
+func printLog(ctx context.Context, queryLogs LogArgs) error {
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (logOutput, error) {
+		queryLog, err := getLogCommand(ctx, server, queryLogs)
+		return logOutput(queryLog), err
+	})
+}
+
+func getLogCommand(ctx context.Context, server *common.ServerConfig, queryLogs LogArgs) (client.QueryLog, error) {
+	if !slices.Contains(allowedFilters, queryLogs.filter) {
+		return client.QueryLog{}, fmt.Errorf("filter value %s not allowed", queryLogs.filter)
 	}
 
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return getLogCommandAll(servers, queryLogs)
+	c, err := newClient(server)
+	if err != nil {
+		return client.QueryLog{}, err
 	}
 
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
+	params := client.QueryLogParams{
+		Limit:          queryLogs.limit,
+		ResponseStatus: queryLogs.filter,
+		Search:         queryLogs.search,
 	}
 
-	indentedJson, err := getLogCommand(server, queryLogs)
+	// one request is fine for a page's worth, anything more is paged so busy
+	// servers don't time out
+	want, err := logLimit(params.Limit)
 	if err != nil {
-		return err
+		return client.QueryLog{}, err
 	}
-
-	fmt.Println(indentedJson.String())
-	return nil
+	if !queryLogs.match.active() && want <= logPageSize {
+		return c.QueryLog(ctx, params)
+	}
+	return pageLog(ctx, c, params, queryLogs.match)
 }
 
-func getLogCommand(server *common.ServerConfig, queryLogs LogArgs) (bytes.Buffer, error) {
-	var indentedJson bytes.Buffer
-
-	baseURL, err := common.GetBaseURL(server)
+// pageLog pages back through the query log until it has params.Limit entries
+// that match, or runs out of log. Oldest in the result is where to carry on from.
+func pageLog(ctx context.Context, c *client.Client, params client.QueryLogParams, match logMatcher) (client.QueryLog, error) {
+	want, err := logLimit(params.Limit)
 	if err != nil {
-		return indentedJson, err
+		return client.QueryLog{}, err
 	}
 
-	baseURL.Path = "/control/querylog"
-
-	queryValues := url.Values{}
-
-	queryValues.Add("limit", queryLogs.limit)
-
-	idx := slices.Index(allowedFilters, queryLogs.filter)
-	if idx >= 0 {
-		queryValues.Add("response_status", queryLogs.filter)
-	} else {
-		return indentedJson, fmt.Errorf("filter value %s not allowed", queryLogs.filter)
+	ret := client.QueryLog{Data: []client.LogEntry{}}
+	if !match.until.IsZero() {
+		// no need to fetch anything newer
+		params.OlderThan = match.until.Format(time.RFC3339Nano)
 	}
 
-	if len(queryLogs.search) > 0 {
-		queryValues.Add("search", queryLogs.search)
+	full := false
+	err = walkLog(ctx, c, params, logPageSize, func(page client.QueryLog) (bool, error) {
+		for _, e := range page.Data {
+			if match.pastSince(e) {
+				return false, nil
+			}
+			if match.match(e) {
+				ret.Data = append(ret.Data, e)
+				if uint64(len(ret.Data)) == want {
+					ret.Oldest, full = e.Time, true
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return client.QueryLog{}, err
+	}
+	if !full {
+		// the whole log or the whole --since window was read
+		ret.Oldest = ""
 	}
+	return ret, nil
+}
 
-	baseURL.RawQuery = queryValues.Encode()
+// walkLog fetches the query log pageSize entries at a time, newest first,
+// starting before params.OlderThan if it's set. It calls fn with each page
+// until fn says to stop or the log runs out.
+func walkLog(ctx context.Context, c *client.Client, params client.QueryLogParams, pageSize int, fn func(client.QueryLog) (bool, error)) error {
+	params.Limit = strconv.Itoa(pageSize)
+
+	for {
+		page, err := c.QueryLog(ctx, params)
+		if err != nil {
+			return err
+		}
+		// a short page doesn't mean the end, AdGuard Home stops early when
+		// filtering, so only an empty one does
+		if len(page.Data) == 0 || page.Oldest == "" || page.Oldest == params.OlderThan {
+			return nil
+		}
 
-	statusQuery := common.CommandArgs{
-		Method: "GET",
-		URL:    baseURL,
-		Server: server,
+		more, err := fn(page)
+		if err != nil || !more {
+			return err
+		}
+		params.OlderThan = page.Oldest
 	}
+}
 
-	body, err := common.SendCommand(statusQuery)
+// logLimit is how many entries a log length argument asks for, 500 by default
+func logLimit(limit string) (uint64, error) {
+	if limit == "" {
+		return logPageSize, nil
+	}
+	want, err := strconv.ParseUint(limit, 10, 64)
 	if err != nil {
-		return indentedJson, err
+		return 0, fmt.Errorf("bad log length %q: %w", limit, err)
 	}
-
-	json.Indent(&indentedJson, body, "", "  ")
-
-	return indentedJson, nil
+	return want, nil
 }
 
-func getLogCommandAll(servers []common.ServerConfig, queryLogs LogArgs) error {
-	type ServerResult struct {
-		Server string `json:"server"`
-		Result string `json:"result,omitempty"`
-		Error  string `json:"error,omitempty"`
-	}
+// logOutput is a query log with sensible columns for table and csv output
+type logOutput client.QueryLog
 
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		logResult, err := getLogCommand(&server, queryLogs)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = logResult.String()
-		}
-		results = append(results, result)
-	}
+func (l logOutput) Table() ([]string, [][]string) {
+	header := []string{"time", "client", "name", "type", "reason", "status", "elapsed_ms", "upstream"}
 
-	output, err := json.MarshalIndent(results, "", "  ")
-	if err != nil {
-		return err
+	rows := make([][]string, len(l.Data))
+	for i, e := range l.Data {
+		rows[i] = []string{e.Time, e.Client, e.Question.Name, e.Question.Type, e.Reason, e.Status, e.ElapsedMs, e.Upstream}
 	}
-	fmt.Println(string(output))
-	return nil
+
+	return header, rows
 }
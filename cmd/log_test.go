@@ -1,11 +1,13 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
+
+	"github.com/ewosborne/adctl/client"
 )
 
 type ValidQueryResult struct {
@@ -22,13 +24,14 @@ func Test_LogGet(t *testing.T) {
 	}
 
 	// test only a small log thing
-	log, err := getLogCommand(nil, TestLogArgsInstance)
+	log, err := getLogCommand(context.Background(), nil, TestLogArgsInstance)
 
 	if err != nil {
 		t.Error("error getting getLogCommand", err)
 	}
 
-	if !json.Valid(log.Bytes()) {
+	body, err := json.Marshal(log)
+	if err != nil || !json.Valid(body) {
 		t.Error("invalid json log", err)
 	}
 }
@@ -41,13 +44,13 @@ func Test_LogGet_Filter(t *testing.T) {
 	// test with an allowed and disallowed filter.
 	// filter comes from the variable declared as a flag
 	var err error
-	body, err := getLogCommand(nil, TestLogArgsInstance)
+	log, err := getLogCommand(context.Background(), nil, TestLogArgsInstance)
 
 	if err != nil {
 		t.Error("error getting getLogCommand with valid filter", err)
 	}
 
-	present, err := checkBufferForJson(body)
+	present, err := checkLogForJson(log)
 	if err != nil {
 		t.Error("got non-fill error testing getLogCommand")
 		fmt.Println(err)
@@ -58,8 +61,8 @@ func Test_LogGet_Filter(t *testing.T) {
 
 	initialFilter := TestLogArgsInstance.filter
 	TestLogArgsInstance.filter = "bogon"
-	_, err = getLogCommand(nil, TestLogArgsInstance) // do not capture body here, it's empty, I just care about error
-	TestLogArgsInstance.filter = initialFilter  // reset because this would otherwise carry across tests
+	_, err = getLogCommand(context.Background(), nil, TestLogArgsInstance) // do not capture body here, it's empty, I just care about error
+	TestLogArgsInstance.filter = initialFilter                             // reset because this would otherwise carry across tests
 
 	if err == nil {
 		t.Error("tried getLogCommand with invalid filter and didn't get error")
@@ -74,14 +77,14 @@ func Test_LogGet_Search(t *testing.T) {
 	var err error
 
 	TestLogArgsInstance.search = "example.com"
-	body, err := getLogCommand(nil, TestLogArgsInstance)
+	log, err := getLogCommand(context.Background(), nil, TestLogArgsInstance)
 
 	if err != nil {
 		t.Error("got non-fill error testing getLogCommand")
 		fmt.Println(err)
 	}
 
-	present, err := checkBufferForJson(body)
+	present, err := checkLogForJson(log)
 	if err != nil {
 		t.Error("got non-fill error testing getLogCommand")
 		fmt.Println(err)
@@ -91,14 +94,18 @@ func Test_LogGet_Search(t *testing.T) {
 	}
 }
 
-func checkBufferForJson(body bytes.Buffer) (bool, error) {
-	// passed in []bytes
-	// marshal it and return whether 'oldest' is presetn
+func checkLogForJson(log client.QueryLog) (bool, error) {
+	// marshal it and return whether 'oldest' is present in the json
 	// fail otherwise
 
+	body, err := json.Marshal(log)
+	if err != nil {
+		return false, fmt.Errorf("can't marshal json")
+	}
+
 	tmpJson := ValidQueryResult{}
 
-	err := json.Unmarshal(body.Bytes(), &tmpJson)
+	err = json.Unmarshal(body, &tmpJson)
 	if err != nil {
 		return false, fmt.Errorf("can't unmarshal json")
 	}
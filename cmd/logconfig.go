@@ -0,0 +1,188 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+)
+
+var logConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Query log settings",
+}
+
+var logConfigGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Show the query log retention, anonymization and ignored domains",
+	Args:  cobra.NoArgs,
+	RunE:  logConfigGetCmdE,
+}
+
+var logConfigSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Change the query log retention, anonymization or ignored domains",
+	Long: `Change the query log settings. Only the flags you give change, so with
+several servers each keeps its other settings.
+--interval takes a Go duration or a number of days, e.g. 6h or 90d.`,
+	Example: `  adctl log config set -s all --interval 30d --anonymize-client-ip
+  adctl log config set --ignored example.com,example.org
+  adctl log config set --ignored ""`,
+	Args: cobra.NoArgs,
+	RunE: logConfigSetCmdE,
+}
+
+var logClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the whole query log (asks first unless --yes)",
+	Args:  cobra.NoArgs,
+	RunE:  logClearCmdE,
+}
+
+var logClearYes bool
+
+var logConfigEnabled bool
+var logConfigInterval string
+var logConfigAnonymize bool
+var logConfigIgnored []string
+
+func init() {
+	logCmd.AddCommand(logConfigCmd)
+	logCmd.AddCommand(logClearCmd)
+	logConfigCmd.AddCommand(logConfigGetCmd)
+	logConfigCmd.AddCommand(logConfigSetCmd)
+
+	addLogConfigSetFlags(logConfigSetCmd)
+
+	logClearCmd.Flags().BoolVarP(&logClearYes, "yes", "y", false, "Don't ask for confirmation")
+}
+
+// addLogConfigSetFlags adds the flags for log config set
+func addLogConfigSetFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&logConfigEnabled, "enabled", true, "Keep a query log")
+	cmd.Flags().StringVar(&logConfigInterval, "interval", "", "How long to keep queries, e.g. 6h, 7d, 90d")
+	cmd.Flags().BoolVar(&logConfigAnonymize, "anonymize-client-ip", false, "Log clients with the last part of their address zeroed")
+	cmd.Flags().StringSliceVar(&logConfigIgnored, "ignored", nil, "Domains to leave out of the log, replacing the current list (\"\" clears it)")
+}
+
+// QueryLogConfigOutput is what log config get prints
+type QueryLogConfigOutput struct {
+	Enabled           bool     `json:"enabled"`
+	Interval          string   `json:"interval"`
+	IntervalMs        uint64   `json:"interval_ms"`
+	AnonymizeClientIP bool     `json:"anonymize_client_ip"`
+	Ignored           []string `json:"ignored"`
+	IgnoredEnabled    bool     `json:"ignored_enabled"`
+}
+
+func newQueryLogConfigOutput(c client.QueryLogConfig) QueryLogConfigOutput {
+	ignored := c.Ignored
+	if ignored == nil {
+		ignored = []string{}
+	}
+	return QueryLogConfigOutput{
+		Enabled:           c.Enabled,
+		Interval:          formatInterval(time.Duration(c.Interval) * time.Millisecond),
+		IntervalMs:        c.Interval,
+		AnonymizeClientIP: c.AnonymizeClientIP,
+		Ignored:           ignored,
+		IgnoredEnabled:    c.IgnoredEnabled,
+	}
+}
+
+func logConfigGetCmdE(cmd *cobra.Command, args []string) error {
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (QueryLogConfigOutput, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return QueryLogConfigOutput{}, err
+		}
+		config, err := c.QueryLogConfig(ctx)
+		return newQueryLogConfigOutput(config), err
+	})
+}
+
+func logConfigSetCmdE(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if !flags.Changed("enabled") && !flags.Changed("interval") && !flags.Changed("anonymize-client-ip") && !flags.Changed("ignored") {
+		return fmt.Errorf("nothing to change: give --enabled, --interval, --anonymize-client-ip or --ignored")
+	}
+
+	var interval time.Duration
+	if flags.Changed("interval") {
+		var err error
+		if interval, err = parseInterval(logConfigInterval); err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive, use --enabled=false to stop logging")
+		}
+	}
+
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (QueryLogConfigOutput, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return QueryLogConfigOutput{}, err
+		}
+
+		// start from what's there so flags that weren't given stay as they are
+		config, err := c.QueryLogConfig(ctx)
+		if err != nil {
+			return QueryLogConfigOutput{}, err
+		}
+
+		if flags.Changed("enabled") {
+			config.Enabled = logConfigEnabled
+		}
+		if flags.Changed("interval") {
+			config.Interval = uint64(interval.Milliseconds())
+		}
+		if flags.Changed("anonymize-client-ip") {
+			config.AnonymizeClientIP = logConfigAnonymize
+		}
+		if flags.Changed("ignored") {
+			config.Ignored = slices.DeleteFunc(slices.Clone(logConfigIgnored), func(s string) bool { return s == "" })
+			config.IgnoredEnabled = len(config.Ignored) > 0
+		}
+
+		if err := c.SetQueryLogConfig(ctx, config); err != nil {
+			return QueryLogConfigOutput{}, err
+		}
+
+		config, err = c.QueryLogConfig(ctx)
+		return newQueryLogConfigOutput(config), err
+	})
+}
+
+// LogClearOutput is what log clear prints
+type LogClearOutput struct {
+	Cleared bool `json:"cleared"`
+}
+
+func logClearCmdE(cmd *cobra.Command, args []string) error {
+	names, err := serverNames()
+	if err != nil {
+		return err
+	}
+	if err := confirm(logClearYes, "Delete the whole query log on "+names+"?"); err != nil {
+		return err
+	}
+
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (LogClearOutput, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return LogClearOutput{}, err
+		}
+		if err := c.ClearQueryLog(ctx); err != nil {
+			return LogClearOutput{}, err
+		}
+		return LogClearOutput{Cleared: true}, nil
+	})
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func Test_logConfigSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		legacy bool
+		flags  []string
+		// want is the body of the update, empty if nothing should be sent
+		want string
+		err  bool
+	}{
+		{
+			name:  "interval and anonymize",
+			flags: []string{"--interval", "6h", "--anonymize-client-ip"},
+			want:  `{"enabled":true,"interval":21600000,"anonymize_client_ip":true,"ignored":["example.com"],"ignored_enabled":true}`,
+		},
+		{
+			name:  "days",
+			flags: []string{"--interval", "30d", "--enabled=false"},
+			want:  `{"enabled":false,"interval":2592000000,"anonymize_client_ip":false,"ignored":["example.com"],"ignored_enabled":true}`,
+		},
+		{
+			name:  "clear ignored",
+			flags: []string{"--ignored", ""},
+			want:  `{"enabled":true,"interval":7776000000,"anonymize_client_ip":false,"ignored":[]}`,
+		},
+		{
+			name:  "replace ignored",
+			flags: []string{"--ignored", "a.com,b.com", "--ignored", "c.com"},
+			want:  `{"enabled":true,"interval":7776000000,"anonymize_client_ip":false,"ignored":["a.com","b.com","c.com"],"ignored_enabled":true}`,
+		},
+		{
+			name:   "legacy quarter day",
+			legacy: true,
+			flags:  []string{"--interval", "6h"},
+			want:   `{"enabled":true,"interval":0.25,"anonymize_client_ip":false}`,
+		},
+		{name: "legacy half day", legacy: true, flags: []string{"--interval", "12h"}, err: true},
+		{name: "nothing to change", err: true},
+		{name: "zero interval", flags: []string{"--interval", "0d"}, err: true},
+		{name: "bad interval", flags: []string{"--interval", "a week"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case tt.legacy && strings.Contains(r.URL.Path, "/config"):
+					http.NotFound(w, r)
+				case r.URL.Path == "/control/querylog/config":
+					w.Write([]byte(`{"enabled": true, "interval": 7776000000, "anonymize_client_ip": false, "ignored": ["example.com"], "ignored_enabled": true}`))
+				case r.URL.Path == "/control/querylog_info":
+					w.Write([]byte(`{"enabled": true, "interval": 90, "anonymize_client_ip": false}`))
+				case r.Method != "GET":
+					body, _ := io.ReadAll(r.Body)
+					sent = strings.TrimSpace(string(body))
+				}
+			}))
+			defer srv.Close()
+			useTempConfig(t, "servers:\n  - name: t\n    host: "+srv.URL+"\n    username: admin\n    password: pw\n")
+
+			cmd := &cobra.Command{}
+			addLogConfigSetFlags(cmd)
+			if err := cmd.ParseFlags(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			cmd.SetContext(context.Background())
+
+			err := logConfigSetCmdE(cmd, nil)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v", err)
+			}
+			if sent != tt.want {
+				t.Errorf("sent %s\nwant %s", sent, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/spf13/cobra"
+)
+
+// logFilterFlags are the client-side query log filters shared by log get and log tail
+var logFilterFlags struct {
+	clients    []string
+	qtypes     []string
+	reasons    []string
+	upstreams  []string
+	cached     bool
+	since      string
+	until      string
+	minLatency time.Duration
+}
+
+func addLogFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&logFilterFlags.clients, "client", nil, "Only queries from these client IPs or CIDRs")
+	cmd.Flags().StringSliceVar(&logFilterFlags.qtypes, "qtype", nil, "Only these query types, e.g. A,AAAA,HTTPS")
+	cmd.Flags().StringSliceVar(&logFilterFlags.reasons, "reason", nil, "Only these reasons, e.g. FilteredBlackList")
+	cmd.Flags().StringSliceVar(&logFilterFlags.upstreams, "upstream", nil, "Only queries answered by an upstream containing one of these strings")
+	cmd.Flags().BoolVar(&logFilterFlags.cached, "cached", false, "Only cached answers, or with --cached=false only uncached ones")
+	cmd.Flags().StringVar(&logFilterFlags.since, "since", "", "Only queries newer than this, as a time ago (1h, 2d) or an RFC 3339 time")
+	cmd.Flags().StringVar(&logFilterFlags.until, "until", "", "Only queries older than this, as a time ago (1h, 2d) or an RFC 3339 time")
+	cmd.Flags().DurationVar(&logFilterFlags.minLatency, "min-latency", 0, "Only queries that took at least this long to answer, e.g. 50ms")
+}
+
+// logMatcher decides which query log entries to keep. The zero value keeps everything.
+type logMatcher struct {
+	clients    []netip.Prefix
+	qtypes     []string
+	reasons    []string
+	upstreams  []string
+	cached     *bool
+	since      time.Time
+	until      time.Time
+	minLatency time.Duration
+}
+
+// newLogMatcher builds a logMatcher from cmd's filter flags, relative to now
+func newLogMatcher(cmd *cobra.Command, now time.Time) (logMatcher, error) {
+	var m logMatcher
+	f := logFilterFlags
+
+	for _, c := range f.clients {
+		p, err := parseClientPrefix(c)
+		if err != nil {
+			return m, err
+		}
+		m.clients = append(m.clients, p)
+	}
+
+	for _, q := range f.qtypes {
+		m.qtypes = append(m.qtypes, strings.ToUpper(q))
+	}
+	for _, r := range f.reasons {
+		m.reasons = append(m.reasons, strings.ToLower(r))
+	}
+	m.upstreams = f.upstreams
+
+	if cmd.Flags().Changed("cached") {
+		cached := f.cached
+		m.cached = &cached
+	}
+
+	var err error
+	if m.since, err = parseLogTime(f.since, now); err != nil {
+		return m, fmt.Errorf("bad --since: %w", err)
+	}
+	if m.until, err = parseLogTime(f.until, now); err != nil {
+		return m, fmt.Errorf("bad --until: %w", err)
+	}
+	if !m.since.IsZero() && !m.until.IsZero() && !m.until.After(m.since) {
+		return m, fmt.Errorf("--until must be after --since")
+	}
+
+	if f.minLatency < 0 {
+		return m, fmt.Errorf("--min-latency can't be negative")
+	}
+	m.minLatency = f.minLatency
+
+	return m, nil
+}
+
+// parseClientPrefix takes an IP or a CIDR. An IP is a prefix of just that address.
+func parseClientPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("bad --client %q: %w", s, err)
+		}
+		return p.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("bad --client %q, want an IP or a CIDR", s)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// parseLogTime takes a time ago such as 1h or 2d, or an RFC 3339 time.
+// The empty string is the zero time, which means no limit.
+func parseLogTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	ago, err := parseInterval(s)
+	if err != nil || ago < 0 {
+		return time.Time{}, fmt.Errorf("%q isn't a time ago like 1h or 2d, or a time like 2025-01-02T15:04:05Z", s)
+	}
+	return now.Add(-ago), nil
+}
+
+// active reports whether m drops anything, so callers can skip the work if not
+func (m logMatcher) active() bool {
+	return len(m.clients) > 0 || len(m.qtypes) > 0 || len(m.reasons) > 0 || len(m.upstreams) > 0 ||
+		m.cached != nil || !m.since.IsZero() || !m.until.IsZero() || m.minLatency > 0
+}
+
+// match reports whether e passes every filter
+func (m logMatcher) match(e client.LogEntry) bool {
+	if len(m.clients) > 0 {
+		addr, ok := e.ClientAddr()
+		if !ok || !slices.ContainsFunc(m.clients, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+			return false
+		}
+	}
+
+	if len(m.qtypes) > 0 && !slices.Contains(m.qtypes, strings.ToUpper(e.Question.Type)) {
+		return false
+	}
+
+	if len(m.reasons) > 0 && !slices.Contains(m.reasons, strings.ToLower(e.Reason)) {
+		return false
+	}
+
+	if len(m.upstreams) > 0 && !slices.ContainsFunc(m.upstreams, func(u string) bool { return strings.Contains(e.Upstream, u) }) {
+		return false
+	}
+
+	if m.cached != nil && e.Cached != *m.cached {
+		return false
+	}
+
+	if !m.since.IsZero() || !m.until.IsZero() {
+		at, err := e.At()
+		if err != nil {
+			return false
+		}
+		if !m.since.IsZero() && at.Before(m.since) {
+			return false
+		}
+		if !m.until.IsZero() && !at.Before(m.until) {
+			return false
+		}
+	}
+
+	if m.minLatency > 0 && e.Elapsed() < m.minLatency {
+		return false
+	}
+
+	return true
+}
+
+// pastSince reports whether e is older than --since. The log is newest first,
+// so once one entry is past it, so is everything after it.
+func (m logMatcher) pastSince(e client.LogEntry) bool {
+	if m.since.IsZero() {
+		return false
+	}
+	at, err := e.At()
+	return err == nil && at.Before(m.since)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+)
+
+func Test_logMatcher(t *testing.T) {
+	e := client.LogEntry{
+		Time:      "2025-01-01T12:00:00.5Z",
+		Client:    "192.168.1.20",
+		Cached:    true,
+		ElapsedMs: "12.5",
+		Reason:    "FilteredBlackList",
+		Upstream:  "https://dns.cloudflare.com/dns-query",
+	}
+	e.Question.Type = "AAAA"
+
+	prefix := func(s string) netip.Prefix {
+		p, err := parseClientPrefix(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	noon := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	yes, no := true, false
+
+	tests := []struct {
+		name string
+		m    logMatcher
+		want bool
+	}{
+		{"no filters", logMatcher{}, true},
+		{"client cidr", logMatcher{clients: []netip.Prefix{prefix("192.168.1.0/24")}}, true},
+		{"client ip", logMatcher{clients: []netip.Prefix{prefix("192.168.1.21")}}, false},
+		{"any client", logMatcher{clients: []netip.Prefix{prefix("10.0.0.0/8"), prefix("192.168.1.20")}}, true},
+		{"qtype", logMatcher{qtypes: []string{"A", "AAAA"}}, true},
+		{"other qtype", logMatcher{qtypes: []string{"A"}}, false},
+		{"reason", logMatcher{reasons: []string{"filteredblacklist"}}, true},
+		{"upstream", logMatcher{upstreams: []string{"cloudflare"}}, true},
+		{"other upstream", logMatcher{upstreams: []string{"1.1.1.1"}}, false},
+		{"cached", logMatcher{cached: &yes}, true},
+		{"uncached", logMatcher{cached: &no}, false},
+		{"since", logMatcher{since: noon}, true},
+		{"until", logMatcher{until: noon}, false},
+		{"range", logMatcher{since: noon.Add(-time.Hour), until: noon.Add(time.Second)}, true},
+		{"slow enough", logMatcher{minLatency: 12 * time.Millisecond}, true},
+		{"too fast", logMatcher{minLatency: 20 * time.Millisecond}, false},
+		{"all of them", logMatcher{qtypes: []string{"AAAA"}, cached: &yes, minLatency: time.Millisecond}, true},
+		{"one fails", logMatcher{qtypes: []string{"AAAA"}, cached: &no}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.match(e); got != tt.want {
+			t.Errorf("%s: match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_parseLogTime(t *testing.T) {
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in   string
+		want time.Time
+		err  bool
+	}{
+		{"", time.Time{}, false},
+		{"1h", now.Add(-time.Hour), false},
+		{"2d", now.Add(-48 * time.Hour), false},
+		{"2025-01-02T03:04:05Z", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), false},
+		{"-1h", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLogTime(tt.in, now)
+		if (err != nil) != tt.err || !got.Equal(tt.want) {
+			t.Errorf("parseLogTime(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/ewosborne/adctl/query"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+var outputFlag string
+var queryFlag string
+var templateFlag string
+
+// compiled from --query and --template by checkOutputFormat
+var outputQuery query.Query
+var outputTemplate *template.Template
+
+// outputFormats are the values -o accepts
+var outputFormats = []string{"json", "yaml", "table", "csv", "ndjson", "gron", "text"}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", "json", "Output format: "+strings.Join(outputFormats, ", "))
+	rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.PersistentFlags().StringVarP(&queryFlag, "query", "q", "", "jq-style expression to apply to the result, e.g. '.[] | select(.ok) | .server'")
+	rootCmd.PersistentFlags().StringVar(&templateFlag, "template", "", "Go text/template to print the result with, e.g. '{{range .}}{{.server}}{{\"\\n\"}}{{end}}'")
+}
+
+// Tabular is implemented by results that know better than the generic
+// flattening which columns a table or csv should have
+type Tabular interface {
+	Table() (header []string, rows [][]string)
+}
+
+// checkOutputFormat makes sure -o is something render understands and
+// compiles --query and --template, so mistakes show up before any API calls
+func checkOutputFormat() error {
+	if !slices.Contains(outputFormats, outputFlag) {
+		return fmt.Errorf("unknown output format %q, must be one of %s", outputFlag, strings.Join(outputFormats, ", "))
+	}
+
+	outputQuery, outputTemplate = nil, nil
+
+	if queryFlag != "" {
+		q, err := query.Compile(queryFlag)
+		if err != nil {
+			return fmt.Errorf("bad --query: %w", err)
+		}
+		outputQuery = q
+	}
+
+	if templateFlag != "" {
+		t, err := template.New("output").Funcs(templateFuncs).Parse(templateFlag)
+		if err != nil {
+			return fmt.Errorf("bad --template: %w", err)
+		}
+		outputTemplate = t
+	}
+
+	return nil
+}
+
+// render prints a command's result on stdout: through --query if there is one,
+// then with --template if there is one, otherwise in the --output format
+func render(v any) error {
+	return renderWith(os.Stdout, outputFlag, outputQuery, outputTemplate, v)
+}
+
+func renderWith(w io.Writer, format string, q query.Query, t *template.Template, v any) error {
+	if q == nil && t == nil {
+		return renderTo(w, format, v)
+	}
+
+	tree, err := query.FromValue(v)
+	if err != nil {
+		return err
+	}
+
+	if q == nil {
+		return writeTemplate(w, t, tree)
+	}
+
+	results, err := q.Run(tree)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	// a table's rows are every result, with arrays giving a row per element
+	if t == nil && (format == "table" || format == "csv") {
+		rows := []any{}
+		for _, r := range results {
+			if arr, ok := r.([]any); ok {
+				rows = append(rows, arr...)
+			} else {
+				rows = append(rows, r)
+			}
+		}
+		return renderTo(w, format, rows)
+	}
+
+	// otherwise each result is printed in turn, as jq does
+	for _, r := range results {
+		if t != nil {
+			err = writeTemplate(w, t, r)
+		} else {
+			err = renderTo(w, format, r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateFuncs are available to --template on top of the text/template builtins
+var templateFuncs = template.FuncMap{
+	"json": func(v any) string { return jsonScalar(v) },
+	"join": func(sep string, v []any) string {
+		parts := make([]string, len(v))
+		for i, x := range v {
+			parts[i] = cellString(x)
+		}
+		return strings.Join(parts, sep)
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// writeTemplate executes t over the tree, with objects as plain maps so
+// {{.field}} and {{range}} work. A missing final newline is added.
+func writeTemplate(w io.Writer, t *template.Template, tree any) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, toPlain(tree)); err != nil {
+		return fmt.Errorf("template failed: %w", err)
+	}
+
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// toPlain turns *query.Object into map[string]any, all the way down
+func toPlain(v any) any {
+	switch t := v.(type) {
+	case *query.Object:
+		m := make(map[string]any, len(t.Keys))
+		for k, x := range t.Vals {
+			m[k] = toPlain(x)
+		}
+		return m
+	case []any:
+		arr := make([]any, len(t))
+		for i, x := range t {
+			arr[i] = toPlain(x)
+		}
+		return arr
+	}
+	return v
+}
+
+// renderTo writes v to w in the given format. Every format but json works
+// from the JSON encoding of v, so struct tags decide the field names everywhere.
+func renderTo(w io.Writer, format string, v any) error {
+	switch format {
+	case "json", "":
+		out, err := json.MarshalIndent(v, "", " ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+
+	case "ndjson":
+		return writeNDJSON(w, v)
+
+	case "table", "csv":
+		header, rows, err := tableOf(v)
+		if err != nil {
+			return err
+		}
+		if format == "csv" {
+			return writeCSV(w, header, rows)
+		}
+		return writeTable(w, header, rows)
+	}
+
+	tree, err := query.FromValue(v)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "yaml":
+		return writeYAML(w, tree)
+	case "gron":
+		return writeGron(w, "json", tree)
+	case "text":
+		return writeText(w, tree)
+	}
+
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// writeNDJSON writes each element of an array on its own line, or v on one line if it isn't an array
+func writeNDJSON(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		elems = []json.RawMessage{data}
+	}
+
+	for _, elem := range elems {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, elem); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeYAML writes the tree as a YAML document, keeping key order
+func writeYAML(w io.Writer, tree any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNode(tree)); err != nil {
+		return fmt.Errorf("failed to write yaml: %w", err)
+	}
+	return enc.Close()
+}
+
+func yamlNode(v any) *yaml.Node {
+	switch t := v.(type) {
+	case *query.Object:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range t.Keys {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, yamlNode(t.Vals[k]))
+		}
+		return n
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, elem := range t {
+			n.Content = append(n.Content, yamlNode(elem))
+		}
+		return n
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(t.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: t.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(t)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+}
+
+var gronIdent = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// writeGron writes one greppable assignment per value, the way gron does
+func writeGron(w io.Writer, path string, v any) error {
+	var err error
+	switch t := v.(type) {
+	case *query.Object:
+		if _, err = fmt.Fprintf(w, "%s = {};\n", path); err != nil {
+			return err
+		}
+		for _, k := range t.Keys {
+			child := path + "." + k
+			if !gronIdent.MatchString(k) {
+				child = path + "[" + jsonScalar(k) + "]"
+			}
+			if err = writeGron(w, child, t.Vals[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if _, err = fmt.Fprintf(w, "%s = [];\n", path); err != nil {
+			return err
+		}
+		for i, elem := range t {
+			if err = writeGron(w, fmt.Sprintf("%s[%d]", path, i), elem); err != nil {
+				return err
+			}
+		}
+	default:
+		_, err = fmt.Fprintf(w, "%s = %s;\n", path, jsonScalar(t))
+	}
+	return err
+}
+
+// writeText writes plain "key: value" lines, with a blank line between array elements
+func writeText(w io.Writer, tree any) error {
+	arr, ok := tree.([]any)
+	if !ok {
+		arr = []any{tree}
+	}
+
+	for i, elem := range arr {
+		if _, isObj := elem.(*query.Object); !isObj {
+			if _, err := fmt.Fprintln(w, cellString(elem)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		cols, row := flattenRow(elem)
+		for _, col := range cols {
+			line := strings.TrimRight(col+": "+row[col], " ")
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tableOf works out the header and rows for table and csv output. Tabular
+// results choose their own; anything else is flattened, one row per array
+// element, with nested fields as dotted column names.
+func tableOf(v any) ([]string, [][]string, error) {
+	if t, ok := v.(Tabular); ok {
+		header, rows := t.Table()
+		return header, rows, nil
+	}
+
+	tree, err := query.FromValue(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	arr, ok := tree.([]any)
+	if !ok {
+		arr = []any{tree}
+	}
+
+	var header []string
+	seen := make(map[string]bool)
+	flat := make([]map[string]string, len(arr))
+	for i, elem := range arr {
+		cols, row := flattenRow(elem)
+		flat[i] = row
+		for _, col := range cols {
+			if !seen[col] {
+				seen[col] = true
+				header = append(header, col)
+			}
+		}
+	}
+
+	rows := make([][]string, len(flat))
+	for i, row := range flat {
+		rows[i] = make([]string, len(header))
+		for j, col := range header {
+			rows[i][j] = row[col]
+		}
+	}
+
+	return header, rows, nil
+}
+
+// flattenRow flattens one value into dotted column names and cell strings.
+// A bare scalar becomes a single "value" column.
+func flattenRow(v any) ([]string, map[string]string) {
+	var cols []string
+	row := make(map[string]string)
+
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		o, ok := v.(*query.Object)
+		if !ok || len(o.Keys) == 0 {
+			if prefix == "" {
+				prefix = "value"
+			}
+			cols = append(cols, prefix)
+			row[prefix] = cellString(v)
+			return
+		}
+		for _, k := range o.Keys {
+			name := k
+			if prefix != "" {
+				name = prefix + "." + k
+			}
+			walk(name, o.Vals[k])
+		}
+	}
+	walk("", v)
+
+	return cols, row
+}
+
+// cellString formats a value for a table cell: scalars as themselves, lists of
+// scalars comma separated and anything more complicated as compact JSON
+func cellString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return fmt.Sprint(t)
+	case []any:
+		parts := make([]string, len(t))
+		for i, elem := range t {
+			switch elem.(type) {
+			case *query.Object, []any:
+				return jsonScalar(t)
+			}
+			parts[i] = cellString(elem)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return jsonScalar(t)
+	}
+}
+
+// writeTable writes an aligned table with an upper-case header
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+
+	upper := make([]string, len(header))
+	for i, h := range header {
+		upper[i] = strings.ToUpper(h)
+	}
+	fmt.Fprintln(tw, strings.Join(upper, "\t"))
+
+	// tabs and newlines inside a cell would break the columns
+	clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", "")
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = clean.Replace(cell)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// empty trailing cells leave padding behind
+	for line := range strings.Lines(buf.String()) {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " \n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+// jsonScalar encodes v as compact JSON without escaping <, > and &
+func jsonScalar(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
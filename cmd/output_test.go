@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/ewosborne/adctl/query"
+)
+
+type outputTestInner struct {
+	Enabled bool     `json:"enabled"`
+	Tags    []string `json:"tags"`
+}
+
+type outputTestRow struct {
+	Server string           `json:"server"`
+	Inner  outputTestInner  `json:"inner"`
+	Note   string           `json:"note,omitempty"`
+	Ptr    *outputTestInner `json:"ptr"`
+}
+
+func Test_renderTo(t *testing.T) {
+	rows := []outputTestRow{
+		{Server: "b", Inner: outputTestInner{Enabled: true, Tags: []string{"x", "y"}}},
+		{Server: "a", Note: "yes: \"quoted\"\tand tabbed"},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", `[
+ {
+  "server": "b",
+  "inner": {
+   "enabled": true,
+   "tags": [
+    "x",
+    "y"
+   ]
+  },
+  "ptr": null
+ },
+ {
+  "server": "a",
+  "inner": {
+   "enabled": false,
+   "tags": null
+  },
+  "note": "yes: \"quoted\"\tand tabbed",
+  "ptr": null
+ }
+]
+`},
+		{"ndjson", `{"server":"b","inner":{"enabled":true,"tags":["x","y"]},"ptr":null}
+{"server":"a","inner":{"enabled":false,"tags":null},"note":"yes: \"quoted\"\tand tabbed","ptr":null}
+`},
+		{"yaml", `- server: b
+  inner:
+    enabled: true
+    tags:
+      - x
+      - y
+  ptr: null
+- server: a
+  inner:
+    enabled: false
+    tags: null
+  note: "yes: \"quoted\"\tand tabbed"
+  ptr: null
+`},
+		{"gron", `json = [];
+json[0] = {};
+json[0].server = "b";
+json[0].inner = {};
+json[0].inner.enabled = true;
+json[0].inner.tags = [];
+json[0].inner.tags[0] = "x";
+json[0].inner.tags[1] = "y";
+json[0].ptr = null;
+json[1] = {};
+json[1].server = "a";
+json[1].inner = {};
+json[1].inner.enabled = false;
+json[1].inner.tags = null;
+json[1].note = "yes: \"quoted\"\tand tabbed";
+json[1].ptr = null;
+`},
+		{"csv", `server,inner.enabled,inner.tags,ptr,note
+b,true,"x,y",,
+a,false,,,"yes: ""quoted""	and tabbed"
+`},
+		{"table", `SERVER  INNER.ENABLED  INNER.TAGS  PTR  NOTE
+b       true           x,y
+a       false                           yes: "quoted" and tabbed
+`},
+		{"text", `server: b
+inner.enabled: true
+inner.tags: x,y
+ptr:
+
+server: a
+inner.enabled: false
+inner.tags:
+note: yes: "quoted"	and tabbed
+ptr:
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := renderTo(&buf, tt.format, rows); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("renderTo(%s) =\n%s\nwant\n%s", tt.format, buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderTo_Tabular(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderTo(&buf, "csv", serviceList{"YouTube": "youtube", "4chan": "4chan"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "name,id\n4chan,4chan\nYouTube,youtube\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+const renderWithTestInput = `[
+ {"server": "pi", "ok": true, "data": {"version": "v0.107.52", "num_dns_queries": 120, "tags": ["home", "dns"]}},
+ {"server": "nas", "ok": false, "error": "connection refused"},
+ {"server": "vm", "ok": true, "data": {"version": "v0.107.43", "num_dns_queries": 30, "tags": []}}
+]`
+
+func Test_renderWith(t *testing.T) {
+	tree, err := query.Parse([]byte(renderWithTestInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := query.Compile(`map(select(.ok))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("t").Funcs(templateFuncs).Parse(
+		`{{range .}}{{.server}} {{.data.version}} {{.data.tags | join "+"}}{{"\n"}}{{end}}`))
+
+	var buf bytes.Buffer
+	if err := renderWith(&buf, "json", q, tmpl, tree); err != nil {
+		t.Fatal(err)
+	}
+	want := "pi v0.107.52 home+dns\nvm v0.107.43 \n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	q, _ = query.Compile(`.[] | {server, ok}`)
+	if err := renderWith(&buf, "csv", q, nil, tree); err != nil {
+		t.Fatal(err)
+	}
+	want = "server,ok\npi,true\nnas,false\nvm,true\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	// several results are printed one after another, and none prints nothing
+	for src, want := range map[string]string{
+		`.[].server`:                        "\"pi\"\n\"nas\"\n\"vm\"\n",
+		`.[0].server, .[0].data.tags`:       "\"pi\"\n[\n \"home\",\n \"dns\"\n]\n",
+		`.[] | select(.server == "none")`:   "",
+		`.[] | select(.ok) | .data.version`: "\"v0.107.52\"\n\"v0.107.43\"\n",
+	} {
+		buf.Reset()
+		q, _ = query.Compile(src)
+		if err := renderWith(&buf, "json", q, nil, tree); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("%s: got %q, want %q", src, buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+// ServerResult is what every command prints for each server when it targets
+// more than one, so scripts only have one shape to parse. Data is null when
+// the server failed and Error is empty when it didn't.
+type ServerResult[T any] struct {
+	Server     string `json:"server"`
+	OK         bool   `json:"ok"`
+	Data       *T     `json:"data"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ServerResults is a multi-server command's output, one entry per server in config order
+type ServerResults[T any] []ServerResult[T]
+
+// NewServerResults wraps fan-out results in the ServerResult envelope
+func NewServerResults[T any](fanned []common.FanOutResult[T]) ServerResults[T] {
+	ret := make(ServerResults[T], len(fanned))
+	for i, r := range fanned {
+		ret[i] = ServerResult[T]{
+			Server:     r.Server,
+			OK:         r.Err == nil,
+			DurationMs: r.Duration.Milliseconds(),
+		}
+		if r.Err != nil {
+			ret[i].Error = r.Err.Error()
+		} else {
+			ret[i].Data = &r.Value
+		}
+	}
+	return ret
+}
+
+// Table puts each server's own rows under a leading server column, with
+// failed servers getting a single row that only has the error filled in
+func (s ServerResults[T]) Table() ([]string, [][]string) {
+	var header []string
+	col := make(map[string]int)
+
+	type serverRows struct {
+		header []string
+		rows   [][]string
+	}
+	inner := make([]serverRows, len(s))
+
+	for i, r := range s {
+		if r.Data == nil {
+			continue
+		}
+		h, rows, err := tableOf(*r.Data)
+		if err != nil {
+			h, rows = []string{"error"}, [][]string{{err.Error()}}
+		}
+		inner[i] = serverRows{h, rows}
+		for _, name := range h {
+			if _, ok := col[name]; !ok {
+				col[name] = len(header)
+				header = append(header, name)
+			}
+		}
+	}
+
+	for _, r := range s {
+		if _, ok := col["error"]; !ok && r.Error != "" {
+			col["error"] = len(header)
+			header = append(header, "error")
+		}
+	}
+
+	var rows [][]string
+	for i, r := range s {
+		if r.Data == nil {
+			row := make([]string, len(header)+1)
+			row[0] = r.Server
+			row[col["error"]+1] = r.Error
+			rows = append(rows, row)
+			continue
+		}
+		for _, in := range inner[i].rows {
+			row := make([]string, len(header)+1)
+			row[0] = r.Server
+			for j, name := range inner[i].header {
+				if j < len(in) {
+					row[col[name]+1] = in[j]
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return append([]string{"server"}, header...), rows
+}
+
+// forServers runs fn against the servers --server picks. One server's result
+// is printed as it is; several are printed as ServerResults.
+func forServers[T any](ctx context.Context, fn func(ctx context.Context, server *common.ServerConfig) (T, error)) error {
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+
+	if !isMultiServer(servers) {
+		// nil means the legacy single-server config
+		var server *common.ServerConfig
+		if len(servers) > 0 {
+			server = &servers[0]
+		}
+
+		v, err := fn(ctx, server)
+		if err != nil {
+			return err
+		}
+		return render(v)
+	}
+
+	return renderResults(fanOut(ctx, servers, fn))
+}
+
+// renderResults prints fan-out results in the ServerResult envelope and
+// returns a *common.FanOutError if any server failed
+func renderResults[T any](fanned []common.FanOutResult[T]) error {
+	if err := render(NewServerResults(fanned)); err != nil {
+		return err
+	}
+	return common.FanOutErr(fanned)
+}
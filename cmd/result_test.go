@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+func TestServerResults(t *testing.T) {
+	fanned := []common.FanOutResult[BlockedWithCount]{
+		{Server: "pi", Value: BlockedWithCount{Count: 2, IDs: []string{"4chan", "tiktok"}}, Duration: 12 * time.Millisecond},
+		{Server: "nas", Err: errors.New("connection refused"), Duration: 3 * time.Millisecond},
+	}
+	results := NewServerResults(fanned)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"ndjson", `{"server":"pi","ok":true,"data":{"count":2,"IDs":["4chan","tiktok"]},"duration_ms":12}
+{"server":"nas","ok":false,"data":null,"error":"connection refused","duration_ms":3}
+`},
+		{"csv", `server,id,error
+pi,4chan,
+pi,tiktok,
+nas,,connection refused
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := renderTo(&buf, tt.format, results); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -4,252 +4,309 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
 
+	"github.com/ewosborne/adctl/client"
 	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/cobra"
 )
 
+// rewriteEntryHelp explains DOMAIN and ANSWER, for the Long help
+const rewriteEntryHelp = `DOMAIN is a name or a wildcard such as *.example.com. ANSWER is an IP address,
+a domain to answer with as a CNAME, or A or AAAA to keep the upstream's records
+of that type. Both can be given as arguments or with --domain and --answer.`
+
 // rewriteCmd represents the rewrite command
 var rewriteCmd = &cobra.Command{
 	Use:   "rewrite",
 	Short: "Control DNS rewrites",
-	Long:  "Add, delete, or list DNS rewrites.",
+	Long:  "Add, delete, update or list DNS rewrites.",
 }
 
 var rewriteListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List DNS rewrites",
+	Args:  cobra.NoArgs,
 	RunE:  RewriteListCmdE,
 }
 
 var rewriteAddCmd = &cobra.Command{
-	Use:   "add",
+	Use:   "add [DOMAIN ANSWER]",
 	Short: "Add a rewrite",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return RewriteCommand(cmd, args, true)
-	},
+	Long: `Add a rewrite. If it's already there nothing is added, though --enabled
+still turns it on or off.
+
+` + rewriteEntryHelp,
+	Example: `  adctl rewrite add nas.home.arpa 192.168.1.10
+  adctl rewrite add -s all --domain '*.example.io' --answer 192.168.1.1 --enabled=false`,
+	Args: cobra.RangeArgs(0, 2),
+	RunE: rewriteAddCmdE,
 }
 
 var rewriteDeleteCmd = &cobra.Command{
-	Use:   "delete",
+	Use:   "delete [DOMAIN ANSWER]",
 	Short: "Delete a rewrite",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return RewriteCommand(cmd, args, false)
-	},
+	Long:  "Delete a rewrite.\n\n" + rewriteEntryHelp,
+	Args:  cobra.RangeArgs(0, 2),
+	RunE:  rewriteDeleteCmdE,
 }
 
-func init() {
-	//
-	// 11/30/2025: removing rewrite because the api looks like it changed and I never used this via CLI anyways
-	//  TODO: fix for real or remove for real
-	//
+var rewriteUpdateCmd = &cobra.Command{
+	Use:   "update [DOMAIN ANSWER]",
+	Short: "Change a rewrite's domain or answer, or turn it on or off",
+	Long: `Change the rewrite for DOMAIN and ANSWER. Only --new-domain, --new-answer and
+--enabled change it, whichever are given.
+
+` + rewriteEntryHelp,
+	Example: `  adctl rewrite update nas.home.arpa 192.168.1.10 --new-answer 192.168.1.11
+  adctl rewrite update -s all --domain '*.example.io' --answer 192.168.1.1 --enabled=false`,
+	Args: cobra.RangeArgs(0, 2),
+	RunE: rewriteUpdateCmdE,
+}
 
-	// rootCmd.AddCommand(rewriteCmd)
-	// rewriteCmd.AddCommand(rewriteListCmd)
+var rewriteEnabled bool
+var rewriteNewDomain string
+var rewriteNewAnswer string
 
-	// rewriteCmd.AddCommand(rewriteAddCmd)
-	// rewriteCmd.AddCommand(rewriteDeleteCmd)
+func init() {
+	rootCmd.AddCommand(rewriteCmd)
+	rewriteCmd.AddCommand(rewriteListCmd)
+	rewriteCmd.AddCommand(rewriteAddCmd)
+	rewriteCmd.AddCommand(rewriteDeleteCmd)
+	rewriteCmd.AddCommand(rewriteUpdateCmd)
+
+	for _, c := range []*cobra.Command{rewriteAddCmd, rewriteDeleteCmd, rewriteUpdateCmd} {
+		c.Flags().String("domain", "", "Name or wildcard to match on")
+		c.Flags().String("answer", "", "Answer to supply in response: an IP address, a domain, or A or AAAA to keep the upstream's")
+	}
 
-	// rewriteAddCmd.Flags().String("domain", "", "Name or wildcard to match on")
-	// rewriteAddCmd.Flags().String("answer", "", "Answer to supply in response. IP address, domain name, or some weird special stuff around A and AAAA.")
+	rewriteAddCmd.Flags().BoolVar(&rewriteEnabled, "enabled", true, "Whether the rewrite is used")
+	rewriteUpdateCmd.Flags().BoolVar(&rewriteEnabled, "enabled", true, "Turn the rewrite on or off")
+	rewriteUpdateCmd.Flags().StringVar(&rewriteNewDomain, "new-domain", "", "Domain to change it to")
+	rewriteUpdateCmd.Flags().StringVar(&rewriteNewAnswer, "new-answer", "", "Answer to change it to")
+}
 
-	// rewriteDeleteCmd.Flags().String("domain", "", "Name or wildcard to match on")
-	// rewriteDeleteCmd.Flags().String("answer", "", "Answer to supply in response. IP address, domain name, or some weird special stuff around A and AAAA.")
+type RewriteList []client.RewriteEntry
 
+func (r RewriteList) Table() ([]string, [][]string) {
+	rows := make([][]string, len(r))
+	for i, e := range r {
+		rows[i] = []string{e.Domain, e.Answer, rewriteEnabledCell(e)}
+	}
+	return []string{"domain", "answer", "enabled"}, rows
 }
 
-type RewriteList []map[string]string
+// rewriteEnabledCell is true or false, or empty from servers that don't say
+func rewriteEnabledCell(e client.RewriteEntry) string {
+	if e.Enabled == nil {
+		return ""
+	}
+	return strconv.FormatBool(*e.Enabled)
+}
 
 func RewriteListCmdE(cmd *cobra.Command, args []string) error {
-	return printRewriteList()
+	return printRewriteList(cmd.Context())
+}
+
+func printRewriteList(ctx context.Context) error {
+	return forServers(ctx, rewriteListCommand)
 }
 
-func printRewriteList() error {
-	servers, err := GetCurrentServers()
+func rewriteListCommand(ctx context.Context, server *common.ServerConfig) (RewriteList, error) {
+	c, err := newClient(server)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return rewriteListCommandAll(servers)
+	ret, err := c.RewriteList(ctx)
+	if ret == nil {
+		ret = []client.RewriteEntry{}
 	}
+	return ret, err
+}
 
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
+// rewriteEntryArgs gets the rewrite a command is about, from its arguments
+// or its --domain and --answer flags
+func rewriteEntryArgs(cmd *cobra.Command, args []string) (client.RewriteEntry, error) {
+	flags := cmd.Flags()
+	fromFlags := flags.Changed("domain") || flags.Changed("answer")
+
+	var domain, answer string
+	switch {
+	case len(args) == 2 && fromFlags:
+		return client.RewriteEntry{}, fmt.Errorf("give DOMAIN and ANSWER either as arguments or with --domain and --answer, not both")
+	case len(args) == 2:
+		domain, answer = args[0], args[1]
+	case len(args) == 0:
+		domain, _ = flags.GetString("domain")
+		answer, _ = flags.GetString("answer")
+	default:
+		return client.RewriteEntry{}, fmt.Errorf("need both DOMAIN and ANSWER")
 	}
 
-	status, err := rewriteListCommand(server)
-	if err != nil {
-		return err
+	if domain == "" || answer == "" {
+		return client.RewriteEntry{}, fmt.Errorf("need both a domain and an answer")
 	}
-
-	tmp, err := json.MarshalIndent(status, "", " ")
-	if err != nil {
-		return err
+	if err := checkRewriteDomain(domain); err != nil {
+		return client.RewriteEntry{}, err
+	}
+	if err := checkRewriteAnswer(answer); err != nil {
+		return client.RewriteEntry{}, err
 	}
-	fmt.Println(string(tmp))
-	return nil
-}
-
-func rewriteListCommand(server *common.ServerConfig) (RewriteList, error) {
 
-	//var ret = make(map[string]any)
-	var ret RewriteList
+	return client.RewriteEntry{Domain: domain, Answer: answer}, nil
+}
 
-	// list is a GET, takes no params
-	baseURL, err := common.GetBaseURL(server)
-	if err != nil {
-		return ret, err
+// checkRewriteDomain accepts a name or a *. wildcard
+func checkRewriteDomain(domain string) error {
+	if !isHostname(strings.TrimPrefix(domain, "*.")) {
+		return fmt.Errorf("domain '%s' isn't a name or a wildcard like *.example.com", domain)
 	}
-	baseURL.Path = "/control/rewrite/list"
+	return nil
+}
 
-	statusQuery := common.CommandArgs{
-		Method: "GET",
-		URL:    baseURL,
-		Server: server,
+// checkRewriteAnswer accepts an IP, a domain, or A or AAAA
+func checkRewriteAnswer(answer string) error {
+	if answer == "A" || answer == "AAAA" || isHostname(answer) {
+		return nil
 	}
-
-	body, err := common.SendCommand(statusQuery)
-	if err != nil {
-		return ret, err
+	if _, err := netip.ParseAddr(answer); err == nil {
+		return nil
 	}
+	return fmt.Errorf("answer '%s' isn't an IP address, a domain, A or AAAA", answer)
+}
 
-	err = json.Unmarshal(body, &ret)
-	if err != nil {
-		return ret, err
+// findRewrite finds entry in list. Domains are matched without regard to case.
+func findRewrite(list []client.RewriteEntry, entry client.RewriteEntry) (client.RewriteEntry, bool) {
+	for _, e := range list {
+		if strings.EqualFold(e.Domain, entry.Domain) && e.Answer == entry.Answer {
+			return e, true
+		}
 	}
-
-	return ret, nil
+	return client.RewriteEntry{}, false
 }
 
-func RewriteCommand(cmd *cobra.Command, args []string, add bool) error {
+func rewriteName(e client.RewriteEntry) string {
+	return e.Domain + " -> " + e.Answer
+}
 
-	// if add is true then add
-	// if add is false then delete
+// changeRewrites runs change against each server's rewrites, then prints
+// each server's rewrites as they are afterwards
+func changeRewrites(ctx context.Context, change func(ctx context.Context, c *client.Client, list []client.RewriteEntry) error) error {
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (RewriteList, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return nil, err
+		}
+		list, err := c.RewriteList(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := change(ctx, c, list); err != nil {
+			return nil, err
+		}
+		return rewriteListCommand(ctx, server)
+	})
+}
 
-	domain, err := cmd.Flags().GetString("domain")
+func rewriteAddCmdE(cmd *cobra.Command, args []string) error {
+	entry, err := rewriteEntryArgs(cmd, args)
 	if err != nil {
 		return err
 	}
-
-	answer, err := cmd.Flags().GetString("answer")
-	if err != nil {
-		return err
+	if cmd.Flags().Changed("enabled") {
+		entry.Enabled = &rewriteEnabled
 	}
 
-	servers, err := GetCurrentServers()
+	return changeRewrites(cmd.Context(), func(ctx context.Context, c *client.Client, list []client.RewriteEntry) error {
+		return doRewriteAction(ctx, c, list, entry, true)
+	})
+}
+
+func rewriteDeleteCmdE(cmd *cobra.Command, args []string) error {
+	entry, err := rewriteEntryArgs(cmd, args)
 	if err != nil {
 		return err
 	}
 
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		err = doRewriteActionAll(servers, domain, answer, add)
-		if err != nil {
-			return err
+	return changeRewrites(cmd.Context(), func(ctx context.Context, c *client.Client, list []client.RewriteEntry) error {
+		return doRewriteAction(ctx, c, list, entry, false)
+	})
+}
+
+// doRewriteAction adds entry, or deletes it if !add, given the server's
+// current list. Adding isn't idempotent on the server, so a rewrite that's
+// already there is only updated, and only if entry.Enabled says to.
+func doRewriteAction(ctx context.Context, c *client.Client, list []client.RewriteEntry, entry client.RewriteEntry, add bool) error {
+	existing, ok := findRewrite(list, entry)
+
+	if !add {
+		if !ok {
+			return fmt.Errorf("rewrite '%s' not found", rewriteName(entry))
 		}
-		return rewriteListCommandAll(servers)
+		return c.DeleteRewrite(ctx, existing)
 	}
 
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
+	if !ok {
+		return c.AddRewrite(ctx, entry)
 	}
-
-	err = doRewriteAction(server, domain, answer, add)
-	if err != nil {
-		return err
+	if entry.Enabled != nil && (existing.Enabled == nil || *existing.Enabled != *entry.Enabled) {
+		update := existing
+		update.Enabled = entry.Enabled
+		return c.UpdateRewrite(ctx, existing, update)
 	}
-	printRewriteList()
 	return nil
-
 }
 
-func doRewriteAction(server *common.ServerConfig, domain string, answer string, add bool) error {
-
-	var requestBody = make(map[string]any)
-	var err error
-	requestBody["domain"] = domain
-	requestBody["answer"] = answer
-
-	baseURL, err := common.GetBaseURL(server)
+func rewriteUpdateCmdE(cmd *cobra.Command, args []string) error {
+	entry, err := rewriteEntryArgs(cmd, args)
 	if err != nil {
 		return err
 	}
 
-	switch add {
-	case true:
-		baseURL.Path = "/control/rewrite/add"
-	case false:
-		baseURL.Path = "/control/rewrite/delete"
+	flags := cmd.Flags()
+	if !flags.Changed("new-domain") && !flags.Changed("new-answer") && !flags.Changed("enabled") {
+		return fmt.Errorf("nothing to change: give --new-domain, --new-answer or --enabled")
 	}
-
-	enableQuery := common.CommandArgs{
-		Method:      "POST",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
-
-	if add {
-		// delete before adding because adding isn't idempotent.
-		err = doRewriteAction(server, domain, answer, false)
-		if err != nil {
+	if flags.Changed("new-domain") {
+		if err := checkRewriteDomain(rewriteNewDomain); err != nil {
 			return err
 		}
 	}
-
-	_, err = common.SendCommand(enableQuery)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func rewriteListCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string      `json:"server"`
-		Result RewriteList `json:"result,omitempty"`
-		Error  string      `json:"error,omitempty"`
+	if flags.Changed("new-answer") {
+		if err := checkRewriteAnswer(rewriteNewAnswer); err != nil {
+			return err
+		}
 	}
 
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		rewriteList, err := rewriteListCommand(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = rewriteList
+	return changeRewrites(cmd.Context(), func(ctx context.Context, c *client.Client, list []client.RewriteEntry) error {
+		existing, ok := findRewrite(list, entry)
+		if !ok {
+			return fmt.Errorf("rewrite '%s' not found", rewriteName(entry))
 		}
-		results = append(results, result)
-	}
 
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(output))
-	return nil
-}
+		update := existing
+		if flags.Changed("new-domain") {
+			update.Domain = rewriteNewDomain
+		}
+		if flags.Changed("new-answer") {
+			update.Answer = rewriteNewAnswer
+		}
+		if flags.Changed("enabled") {
+			update.Enabled = &rewriteEnabled
+		}
 
-func doRewriteActionAll(servers []common.ServerConfig, domain string, answer string, add bool) error {
-	var errors []string
-	for _, server := range servers {
-		err := doRewriteAction(&server, domain, answer, add)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %v", server.Name, err))
+		if update.Domain != existing.Domain || update.Answer != existing.Answer {
+			if _, ok := findRewrite(list, update); ok {
+				return fmt.Errorf("rewrite '%s' already exists", rewriteName(update))
+			}
+		} else if existing.Enabled != nil && update.Enabled != nil && *existing.Enabled == *update.Enabled {
+			return nil
 		}
-	}
-	if len(errors) > 0 {
-		return fmt.Errorf("errors updating rewrites: %v", errors)
-	}
-	return nil
+		return c.UpdateRewrite(ctx, existing, update)
+	})
 }
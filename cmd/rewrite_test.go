@@ -1,3 +1,60 @@
 package cmd
 
-// TODO: deliberately empty because all rewrite tests moved to testscripts_infra_test.go
+import (
+	"testing"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/spf13/cobra"
+)
+
+// the end-to-end rewrite tests are the rewrite_*.txtar scripts in testscripts_test.go
+
+func Test_rewriteEntryArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		flags []string
+		want  client.RewriteEntry
+		err   bool
+	}{
+		{args: []string{"nas.home.arpa", "192.168.1.10"}, want: client.RewriteEntry{Domain: "nas.home.arpa", Answer: "192.168.1.10"}},
+		{flags: []string{"--domain", "*.example.io", "--answer", "AAAA"}, want: client.RewriteEntry{Domain: "*.example.io", Answer: "AAAA"}},
+		{args: []string{"example.com", "::1"}, want: client.RewriteEntry{Domain: "example.com", Answer: "::1"}},
+		{args: []string{"example.com", "example.org"}, want: client.RewriteEntry{Domain: "example.com", Answer: "example.org"}},
+		{args: []string{"example.com"}, err: true},
+		{flags: []string{"--domain", "example.com"}, err: true},
+		{args: []string{"a.com", "1.2.3.4"}, flags: []string{"--domain", "b.com"}, err: true},
+		{args: []string{"foo bar", "1.2.3.4"}, err: true},
+		{args: []string{"example.com", "bar baz"}, err: true},
+	}
+
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("domain", "", "")
+		cmd.Flags().String("answer", "", "")
+		if err := cmd.ParseFlags(tt.flags); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := rewriteEntryArgs(cmd, tt.args)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("rewriteEntryArgs(%q, %q) = %+v, %v", tt.args, tt.flags, got, err)
+		}
+	}
+}
+
+func Test_findRewrite(t *testing.T) {
+	enabled := true
+	list := []client.RewriteEntry{
+		{Domain: "NAS.home.arpa", Answer: "192.168.1.10", Enabled: &enabled},
+		{Domain: "nas.home.arpa", Answer: "192.168.1.11"},
+	}
+
+	got, ok := findRewrite(list, client.RewriteEntry{Domain: "nas.home.arpa", Answer: "192.168.1.10"})
+	if !ok || got.Domain != "NAS.home.arpa" || got.Enabled == nil {
+		t.Errorf("got %+v, %v", got, ok)
+	}
+
+	if _, ok := findRewrite(list, client.RewriteEntry{Domain: "nas.home.arpa", Answer: "192.168.1.12"}); ok {
+		t.Errorf("found a rewrite that isn't there")
+	}
+}
@@ -0,0 +1,422 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+)
+
+var rewriteImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Make the rewrites match a hosts, CSV or JSON file",
+	Long: `Make the rewrites match FILE, or stdin with -: rewrites in the file but not on
+the server are added, and rewrites on the server but not in the file are
+deleted unless --no-delete. --dry-run shows what would change without
+changing anything.
+
+The format comes from --format, or else from the file name: .csv is CSV, .json
+is JSON and anything else is a hosts file.
+
+  hosts  "IP name [name...]" lines, # comments. Every name gets the IP.
+         "# disabled: IP name" is a disabled rewrite.
+  csv    domain,answer[,enabled] with an optional header line.
+  json   what rewrite list and rewrite export print.
+
+The enabled column and field are optional. Without them a rewrite's enabled
+setting isn't changed, and new rewrites are enabled. A hosts file only holds IP
+answers, so importing one leaves rewrites to a domain, A or AAAA alone.
+
+Rewrites are added and updated before any are deleted, so a failure part way
+through doesn't leave names without an answer.`,
+	Example: `  adctl rewrite import --dry-run lab-hosts.txt
+  adctl rewrite import -s all rewrites.csv -o table`,
+	Args: cobra.ExactArgs(1),
+	RunE: rewriteImportCmdE,
+}
+
+var rewriteExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Save the rewrites as a hosts, CSV or JSON file",
+	Long: `Save the rewrites as a hosts, CSV or JSON file, sorted by domain so the file
+diffs well. rewrite import reads any of them back.
+
+The format comes from --format, or else from the file name: .csv is CSV, .json
+is JSON and anything else is a hosts file. A hosts file can only hold IP
+answers, so rewrites to a domain, A or AAAA are left out of it with a warning.
+Disabled rewrites are written as "# disabled: IP name" comments. Use CSV or
+JSON to keep everything.`,
+	Example: `  adctl rewrite export -s router -O rewrites.csv
+  adctl rewrite export --format hosts > lab-hosts.txt`,
+	Args: cobra.NoArgs,
+	RunE: rewriteExportCmdE,
+}
+
+var rewriteFileFormat string
+var rewriteExportFile string
+var rewriteImportDryRun bool
+var rewriteImportNoDelete bool
+
+var rewriteFileFormats = []string{"hosts", "csv", "json"}
+
+func init() {
+	rewriteCmd.AddCommand(rewriteImportCmd)
+	rewriteCmd.AddCommand(rewriteExportCmd)
+
+	for _, c := range []*cobra.Command{rewriteImportCmd, rewriteExportCmd} {
+		c.Flags().StringVar(&rewriteFileFormat, "format", "", "hosts, csv or json (default from the file name, else hosts)")
+		c.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(rewriteFileFormats, cobra.ShellCompDirectiveNoFileComp))
+	}
+	rewriteImportCmd.Flags().BoolVar(&rewriteImportDryRun, "dry-run", false, "Show what would change without changing it")
+	rewriteImportCmd.Flags().BoolVar(&rewriteImportNoDelete, "no-delete", false, "Only add and update, keep rewrites that aren't in the file")
+	rewriteExportCmd.Flags().StringVarP(&rewriteExportFile, "file", "O", "-", "File to write, - for stdout")
+}
+
+// rewriteFormat is --format, or else the format the file's name suggests
+func rewriteFormat(file string) (string, error) {
+	if rewriteFileFormat != "" {
+		if !slices.Contains(rewriteFileFormats, rewriteFileFormat) {
+			return "", fmt.Errorf("unknown format %q, must be one of %s", rewriteFileFormat, strings.Join(rewriteFileFormats, ", "))
+		}
+		return rewriteFileFormat, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return "csv", nil
+	case ".json":
+		return "json", nil
+	}
+	return "hosts", nil
+}
+
+// readRewrites reads rewrites in format, checking each one
+func readRewrites(r io.Reader, format string) ([]client.RewriteEntry, error) {
+	var ret []client.RewriteEntry
+	var err error
+	switch format {
+	case "hosts":
+		ret, err = readHostsRewrites(r)
+	case "csv":
+		ret, err = readCSVRewrites(r)
+	case "json":
+		err = json.NewDecoder(r).Decode(&ret)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range ret {
+		if err := checkRewriteDomain(e.Domain); err != nil {
+			return nil, err
+		}
+		if err := checkRewriteAnswer(e.Answer); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Domain, err)
+		}
+	}
+	return ret, nil
+}
+
+// hostsDisabled starts the line for a disabled rewrite in a hosts file
+const hostsDisabled = "# disabled: "
+
+func readHostsRewrites(r io.Reader) ([]client.RewriteEntry, error) {
+	var ret []client.RewriteEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		// rewrite export writes disabled rewrites as comments
+		line, disabled := strings.CutPrefix(strings.TrimSpace(scanner.Text()), hostsDisabled)
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, err := netip.ParseAddr(fields[0]); err != nil {
+			return nil, fmt.Errorf("line %d: '%s' isn't an IP address", n, fields[0])
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("line %d: no names for %s", n, fields[0])
+		}
+		for _, name := range fields[1:] {
+			e := client.RewriteEntry{Domain: name, Answer: fields[0]}
+			if disabled {
+				e.Enabled = new(bool)
+			}
+			ret = append(ret, e)
+		}
+	}
+	return ret, scanner.Err()
+}
+
+func readCSVRewrites(r io.Reader) ([]client.RewriteEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// columns are domain, answer, enabled unless a header says otherwise
+	cols := map[string]int{"domain": 0, "answer": 1, "enabled": 2}
+	if len(records) > 0 && slices.ContainsFunc(records[0], func(s string) bool { return strings.EqualFold(s, "domain") }) {
+		cols = map[string]int{}
+		for i, name := range records[0] {
+			cols[strings.ToLower(name)] = i
+		}
+		if _, ok := cols["answer"]; !ok {
+			return nil, fmt.Errorf("header has no answer column")
+		}
+		records = records[1:]
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := cols[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var ret []client.RewriteEntry
+	for i, record := range records {
+		e := client.RewriteEntry{Domain: field(record, "domain"), Answer: field(record, "answer")}
+		if e.Domain == "" && e.Answer == "" {
+			continue
+		}
+		if enabled := field(record, "enabled"); enabled != "" {
+			b, err := strconv.ParseBool(enabled)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: enabled '%s' isn't true or false", i+1, enabled)
+			}
+			e.Enabled = &b
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+// RewriteImport is what rewrite import did, or would do with --dry-run
+type RewriteImport struct {
+	DryRun    bool                  `json:"dry_run"`
+	Added     []client.RewriteEntry `json:"added"`
+	Deleted   []client.RewriteEntry `json:"deleted"`
+	Updated   []client.RewriteEntry `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+}
+
+func (r RewriteImport) Table() ([]string, [][]string) {
+	var rows [][]string
+	for _, change := range []struct {
+		action  string
+		entries []client.RewriteEntry
+	}{{"add", r.Added}, {"update", r.Updated}, {"delete", r.Deleted}} {
+		for _, e := range change.entries {
+			rows = append(rows, []string{change.action, e.Domain, e.Answer, rewriteEnabledCell(e)})
+		}
+	}
+	return []string{"action", "domain", "answer", "enabled"}, rows
+}
+
+// planRewriteImport works out how to get from current to want. Rewrites are
+// the same if their domains match without regard to case and their answers
+// match; one in want with Enabled set is updated if that's different. Nothing
+// is deleted if keep, and only rewrites to an IP are if onlyIPs, since that's
+// all a hosts file can hold.
+func planRewriteImport(current, want []client.RewriteEntry, keep, onlyIPs bool) RewriteImport {
+	ret := RewriteImport{Added: []client.RewriteEntry{}, Deleted: []client.RewriteEntry{}, Updated: []client.RewriteEntry{}}
+
+	var seen []client.RewriteEntry
+	for _, w := range want {
+		if _, dup := findRewrite(seen, w); dup {
+			continue
+		}
+		seen = append(seen, w)
+
+		existing, ok := findRewrite(current, w)
+		switch {
+		case !ok:
+			ret.Added = append(ret.Added, w)
+		case w.Enabled != nil && (existing.Enabled == nil || *existing.Enabled != *w.Enabled):
+			update := existing
+			update.Enabled = w.Enabled
+			ret.Updated = append(ret.Updated, update)
+		default:
+			ret.Unchanged++
+		}
+	}
+
+	if !keep {
+		for _, e := range current {
+			if onlyIPs {
+				if _, err := netip.ParseAddr(e.Answer); err != nil {
+					continue
+				}
+			}
+			if _, ok := findRewrite(want, e); !ok {
+				ret.Deleted = append(ret.Deleted, e)
+			}
+		}
+	}
+	return ret
+}
+
+func rewriteImportCmdE(cmd *cobra.Command, args []string) error {
+	file := args[0]
+	format, err := rewriteFormat(file)
+	if err != nil {
+		return err
+	}
+
+	var want []client.RewriteEntry
+	if file == "-" {
+		want, err = readRewrites(os.Stdin, format)
+	} else {
+		var f *os.File
+		if f, err = os.Open(file); err != nil {
+			return err
+		}
+		want, err = readRewrites(f, format)
+		f.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("can't read %s as %s: %w", file, format, err)
+	}
+
+	// an empty file would delete every rewrite, which is more likely a mistake
+	if len(want) == 0 && !rewriteImportNoDelete {
+		return fmt.Errorf("no rewrites in %s; to delete them all, use rewrite delete", file)
+	}
+
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (RewriteImport, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return RewriteImport{}, err
+		}
+		current, err := c.RewriteList(ctx)
+		if err != nil {
+			return RewriteImport{}, err
+		}
+
+		plan := planRewriteImport(current, want, rewriteImportNoDelete, format == "hosts")
+		plan.DryRun = rewriteImportDryRun
+		if rewriteImportDryRun {
+			return plan, nil
+		}
+
+		for _, e := range slices.Concat(plan.Added, plan.Updated) {
+			if err := doRewriteAction(ctx, c, current, e, true); err != nil {
+				return plan, err
+			}
+		}
+		for _, e := range plan.Deleted {
+			if err := doRewriteAction(ctx, c, current, e, false); err != nil {
+				return plan, err
+			}
+		}
+		return plan, nil
+	})
+}
+
+// writeRewrites writes rewrites in format, returning how many a hosts file
+// had to leave out
+func writeRewrites(w io.Writer, format string, rewrites []client.RewriteEntry) (int, error) {
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(rewrites, "", " ")
+		if err != nil {
+			return 0, err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return 0, err
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"domain", "answer", "enabled"})
+		for _, e := range rewrites {
+			cw.Write([]string{e.Domain, e.Answer, rewriteEnabledCell(e)})
+		}
+		cw.Flush()
+		return 0, cw.Error()
+	}
+
+	skipped := 0
+	bw := bufio.NewWriter(w)
+	for _, e := range rewrites {
+		if _, err := netip.ParseAddr(e.Answer); err != nil {
+			skipped++
+			continue
+		}
+		prefix := ""
+		if e.Enabled != nil && !*e.Enabled {
+			prefix = hostsDisabled
+		}
+		fmt.Fprintf(bw, "%s%s\t%s\n", prefix, e.Answer, e.Domain)
+	}
+	return skipped, bw.Flush()
+}
+
+func rewriteExportCmdE(cmd *cobra.Command, args []string) error {
+	format, err := rewriteFormat(rewriteExportFile)
+	if err != nil {
+		return err
+	}
+
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+	if isMultiServer(servers) {
+		return fmt.Errorf("rewrite export works on one server at a time, pick one with -s")
+	}
+	var server *common.ServerConfig
+	if len(servers) > 0 {
+		server = &servers[0]
+	}
+
+	rewrites, err := rewriteListCommand(cmd.Context(), server)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(rewrites, func(a, b client.RewriteEntry) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Domain), strings.ToLower(b.Domain)), cmp.Compare(a.Answer, b.Answer))
+	})
+
+	var skipped int
+	if rewriteExportFile == "-" {
+		skipped, err = writeRewrites(os.Stdout, format, rewrites)
+	} else {
+		var f *os.File
+		if f, err = os.Create(rewriteExportFile); err != nil {
+			return err
+		}
+		skipped, err = writeRewrites(f, format, rewrites)
+		err = errors.Join(err, f.Close())
+	}
+	if err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "left out %d rewrites whose answer isn't an IP, use --format csv or json to keep them\n", skipped)
+	}
+	return nil
+}
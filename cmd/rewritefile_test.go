@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ewosborne/adctl/client"
+)
+
+func enabledPtr(b bool) *bool { return &b }
+
+func Test_readRewrites(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		in     string
+		want   []client.RewriteEntry
+		err    bool
+	}{
+		{
+			name:   "hosts",
+			format: "hosts",
+			in:     "# lab\n192.168.1.10 nas.lab nas2.lab # comment\n\n# disabled: 192.168.1.20\tprinter.lab\n::1 *.dev.lab\n",
+			want: []client.RewriteEntry{
+				{Domain: "nas.lab", Answer: "192.168.1.10"},
+				{Domain: "nas2.lab", Answer: "192.168.1.10"},
+				{Domain: "printer.lab", Answer: "192.168.1.20", Enabled: enabledPtr(false)},
+				{Domain: "*.dev.lab", Answer: "::1"},
+			},
+		},
+		{name: "hosts without a name", format: "hosts", in: "192.168.1.10\n", err: true},
+		{name: "hosts without an IP", format: "hosts", in: "nas.lab 192.168.1.10\n", err: true},
+		{
+			name:   "csv with header",
+			format: "csv",
+			in:     "enabled,answer,domain\nfalse,192.168.1.10,nas.lab\n,example.org,www.lab\n",
+			want: []client.RewriteEntry{
+				{Domain: "nas.lab", Answer: "192.168.1.10", Enabled: enabledPtr(false)},
+				{Domain: "www.lab", Answer: "example.org"},
+			},
+		},
+		{
+			name:   "csv without header",
+			format: "csv",
+			in:     "nas.lab, 192.168.1.10\nwww.lab,AAAA,true\n",
+			want: []client.RewriteEntry{
+				{Domain: "nas.lab", Answer: "192.168.1.10"},
+				{Domain: "www.lab", Answer: "AAAA", Enabled: enabledPtr(true)},
+			},
+		},
+		{name: "csv bad enabled", format: "csv", in: "nas.lab,192.168.1.10,maybe\n", err: true},
+		{name: "csv bad answer", format: "csv", in: "nas.lab,not an answer\n", err: true},
+		{
+			name:   "json",
+			format: "json",
+			in:     `[{"domain": "nas.lab", "answer": "192.168.1.10", "enabled": true}]`,
+			want:   []client.RewriteEntry{{Domain: "nas.lab", Answer: "192.168.1.10", Enabled: enabledPtr(true)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRewrites(strings.NewReader(tt.in), tt.format)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v", err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_planRewriteImport(t *testing.T) {
+	current := []client.RewriteEntry{
+		{Domain: "NAS.lab", Answer: "192.168.1.10", Enabled: enabledPtr(true)},
+		{Domain: "printer.lab", Answer: "192.168.1.20", Enabled: enabledPtr(true)},
+		{Domain: "old.lab", Answer: "192.168.1.30", Enabled: enabledPtr(true)},
+		{Domain: "www.lab", Answer: "example.org", Enabled: enabledPtr(true)},
+	}
+	want := []client.RewriteEntry{
+		{Domain: "nas.lab", Answer: "192.168.1.10"},
+		{Domain: "printer.lab", Answer: "192.168.1.20", Enabled: enabledPtr(false)},
+		{Domain: "new.lab", Answer: "192.168.1.40"},
+		{Domain: "new.lab", Answer: "192.168.1.40"},
+	}
+
+	got := planRewriteImport(current, want, false, false)
+	expect := RewriteImport{
+		Added: []client.RewriteEntry{{Domain: "new.lab", Answer: "192.168.1.40"}},
+		Deleted: []client.RewriteEntry{
+			{Domain: "old.lab", Answer: "192.168.1.30", Enabled: enabledPtr(true)},
+			{Domain: "www.lab", Answer: "example.org", Enabled: enabledPtr(true)},
+		},
+		Updated:   []client.RewriteEntry{{Domain: "printer.lab", Answer: "192.168.1.20", Enabled: enabledPtr(false)}},
+		Unchanged: 1,
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("got  %+v\nwant %+v", got, expect)
+	}
+
+	if got := planRewriteImport(current, want, true, false); len(got.Deleted) != 0 {
+		t.Errorf("--no-delete deleted %+v", got.Deleted)
+	}
+
+	// a hosts file can't hold www.lab, so importing one leaves it be
+	got = planRewriteImport(current, want, false, true)
+	if !reflect.DeepEqual(got.Deleted, expect.Deleted[:1]) {
+		t.Errorf("hosts import deleted %+v", got.Deleted)
+	}
+}
+
+func Test_writeRewrites(t *testing.T) {
+	rewrites := []client.RewriteEntry{
+		{Domain: "nas.lab", Answer: "192.168.1.10", Enabled: enabledPtr(true)},
+		{Domain: "printer.lab", Answer: "192.168.1.20", Enabled: enabledPtr(false)},
+		{Domain: "www.lab", Answer: "example.org", Enabled: enabledPtr(true)},
+	}
+
+	var buf bytes.Buffer
+	skipped, err := writeRewrites(&buf, "hosts", rewrites)
+	if err != nil || skipped != 1 {
+		t.Fatalf("hosts: skipped %d, %v", skipped, err)
+	}
+	want := "192.168.1.10\tnas.lab\n# disabled: 192.168.1.20\tprinter.lab\n"
+	if buf.String() != want {
+		t.Errorf("hosts: got %q, want %q", buf.String(), want)
+	}
+
+	// csv and json keep everything, so they read back the same
+	for _, format := range []string{"csv", "json"} {
+		buf.Reset()
+		if _, err := writeRewrites(&buf, format, rewrites); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, err := readRewrites(&buf, format)
+		if err != nil || !reflect.DeepEqual(got, rewrites) {
+			t.Errorf("%s: read back %+v, %v", format, got, err)
+		}
+	}
+}
@@ -5,21 +5,26 @@ No header.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var debugLogger *log.Logger
 
-// var outputFormat string
 var enableDebug bool
 var serverFlag string
+var timeoutFlag time.Duration
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -30,9 +35,13 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Ctrl-C cancels the context, which aborts in-flight requests on every server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(ctx, err))
 	}
 }
 
@@ -49,23 +58,42 @@ func init() {
 	initConfig()
 
 	rootCmd.PersistentFlags().BoolVarP(&enableDebug, "debug", "d", os.Getenv("DEBUG") == "true", "Enable debug mode")
-	rootCmd.PersistentFlags().StringVarP(&serverFlag, "server", "s", "all", "Server name to target (use 'all' for all servers)")
-	//rootCmd.PersistentFlags().StringVarP(&outputFormat, "output format", "o", "json", "Enable debug mode")
+	// initConfig has run, so the default can come from the config file
+	rootCmd.PersistentFlags().StringVarP(&serverFlag, "server", "s", GetDefaultServer(), "Servers to target: a name, 'all', a group, key=value tag, glob, or a comma list of those (see 'adctl server default')")
+	rootCmd.RegisterFlagCompletionFunc("server", completeServerSelector)
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 0, "Per-request timeout, e.g. 10s or 2m (default depends on the command)")
 
 	debugLogger = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime)
 
 	// need PreRun because flags aren't parsed until a command is run.
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		// args are valid by now, so a failure from here on isn't a usage problem
+		cmd.SilenceUsage = true
+
 		if enableDebug {
 			debugLogger.SetOutput(os.Stderr)
 		} else {
 			debugLogger.SetOutput(io.Discard)
 		}
 
-		// Validate server flag (skip for server command itself)
-		if cmd.Name() != "server" && serverFlag != "all" {
-			if !ServerExists(serverFlag) {
-				fmt.Fprintf(os.Stderr, "Error: server '%s' not found\n", serverFlag)
+		var err error
+		requestTimeout, err = commandTimeout(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad timeout for %s: %v\n", cmd.CommandPath(), err)
+			os.Exit(1)
+		}
+		debugLogger.Println("request timeout", requestTimeout)
+
+		if err := checkOutputFormat(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Validate server flag (skip for the server commands, which need to work
+		//   even when the default server has gone missing)
+		if cmd != serverCmd && cmd.Parent() != serverCmd && serverFlag != "all" {
+			if _, err := GetCurrentServers(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
 		}
@@ -84,6 +112,9 @@ func initConfig() {
 	configDir, err := GetConfigDir()
 	if err == nil {
 		viper.AddConfigPath(configDir)
+		// cached session cookies and the secrets file live next to the config
+		common.SessionDir = filepath.Join(configDir, "sessions")
+		common.SecretsFile = filepath.Join(configDir, "secrets.enc")
 	}
 
 	// Also check legacy locations for backward compatibility
@@ -103,6 +134,15 @@ func initConfig() {
 	viper.BindEnv("host", "ADCTL_HOST")
 	viper.BindEnv("username", "ADCTL_USERNAME")
 	viper.BindEnv("password", "ADCTL_PASSWORD")
+	viper.BindEnv("password_env", "ADCTL_PASSWORD_ENV")
+	viper.BindEnv("password_command", "ADCTL_PASSWORD_COMMAND")
+	viper.BindEnv("password_secret", "ADCTL_PASSWORD_SECRET")
+	viper.BindEnv("scheme", "ADCTL_SCHEME")
+	viper.BindEnv("ca_cert", "ADCTL_CA_CERT")
+	viper.BindEnv("client_cert", "ADCTL_CLIENT_CERT")
+	viper.BindEnv("client_key", "ADCTL_CLIENT_KEY")
+	viper.BindEnv("insecure_skip_verify", "ADCTL_INSECURE_SKIP_VERIFY")
+	viper.BindEnv("auth", "ADCTL_AUTH")
 
 	// If a config file is found, read it in.
 	// Note: debugLogger not initialized yet, so we can't log here
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// checkRule checks one line of user rules the way AdGuard Home would read it:
+// a comment, a hosts file line, a bare domain, or an adblock-style rule such
+// as @@||example.com^$client=192.168.1.2|'laptop',important. It only returns
+// an error for a line AdGuard Home would reject or misread.
+func checkRule(line string) error {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "":
+		return nil
+	case strings.HasPrefix(line, "!") || strings.HasPrefix(line, "#"):
+		return nil
+	}
+
+	if fields := strings.Fields(line); len(fields) > 1 {
+		if _, err := netip.ParseAddr(fields[0]); err == nil {
+			return checkHostsLine(fields)
+		}
+	}
+
+	return checkAdblockRule(line)
+}
+
+// checkRules checks every line, reporting the bad ones by line number
+func checkRules(rules []string) error {
+	var problems []string
+	for i, r := range rules {
+		if err := checkRule(r); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %s: %v", i+1, strings.TrimSpace(r), err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("bad rules:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// checkHostsLine checks "IP name [name...] [# comment]"
+func checkHostsLine(fields []string) error {
+	names := 0
+	for _, f := range fields[1:] {
+		if strings.HasPrefix(f, "#") {
+			break
+		}
+		if !isHostname(f) {
+			return fmt.Errorf("%q isn't a hostname", f)
+		}
+		names++
+	}
+	if names == 0 {
+		return fmt.Errorf("hosts line has no hostname")
+	}
+	return nil
+}
+
+var hostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?$`)
+
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if !hostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// patternChars are what a non-regex pattern may hold besides letters and digits
+const patternChars = ".-_*^|:/[]"
+
+func checkAdblockRule(rule string) error {
+	rule = strings.TrimPrefix(rule, "@@")
+
+	pattern, modifiers, hasModifiers := splitModifiers(rule)
+
+	if strings.HasPrefix(pattern, "/") && len(pattern) > 1 {
+		if !strings.HasSuffix(pattern, "/") || len(pattern) < 3 {
+			return fmt.Errorf("regex rule must end with /")
+		}
+		if _, err := regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+			return fmt.Errorf("bad regex: %w", err)
+		}
+	} else {
+		for _, r := range pattern {
+			if r == ' ' || r == '\t' {
+				return fmt.Errorf("rules can't contain spaces, unless it's a hosts line starting with an IP")
+			}
+			if !isAlnum(r) && r < 0x80 && !strings.ContainsRune(patternChars, r) {
+				return fmt.Errorf("%q isn't allowed in a rule", r)
+			}
+		}
+	}
+
+	if !hasModifiers {
+		if pattern == "" {
+			return fmt.Errorf("empty rule")
+		}
+		return nil
+	}
+	if modifiers == "" {
+		return fmt.Errorf("nothing after $")
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range splitUnescaped(modifiers, ',') {
+		name, value, hasValue := strings.Cut(m, "=")
+		if seen[name] {
+			return fmt.Errorf("$%s given twice", name)
+		}
+		seen[name] = true
+
+		check, ok := ruleModifiers[name]
+		if !ok {
+			return fmt.Errorf("unknown modifier $%s", name)
+		}
+		if err := check(value, hasValue); err != nil {
+			return fmt.Errorf("$%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// splitModifiers splits a rule at the $ that starts its modifiers, skipping
+// over a /regex/ pattern, which may contain a $ of its own
+func splitModifiers(rule string) (string, string, bool) {
+	start := 0
+	if strings.HasPrefix(rule, "/") {
+		start = regexEnd(rule)
+	}
+
+	i := strings.Index(rule[start:], "$")
+	if i < 0 {
+		return rule, "", false
+	}
+	return rule[:start+i], rule[start+i+1:], true
+}
+
+// regexEnd finds the / that closes the regex at the start of rule: the first
+// unescaped one that's followed by $ or the end of the rule, since the
+// modifiers may hold a / too, as in $client=10.0.0.0/8. It's 0 if there isn't one.
+func regexEnd(rule string) int {
+	escaped := false
+	for i := 1; i < len(rule); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case rule[i] == '\\':
+			escaped = true
+		case rule[i] == '/' && (i == len(rule)-1 || rule[i+1] == '$'):
+			return i
+		}
+	}
+	return 0
+}
+
+// splitUnescaped splits s at sep, except where sep is escaped with a backslash
+// or inside single quotes
+func splitUnescaped(s string, sep rune) []string {
+	var ret []string
+	var cur strings.Builder
+	escaped, quoted := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '\'':
+			quoted = !quoted
+		case r == sep && !quoted:
+			ret = append(ret, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	return append(ret, cur.String())
+}
+
+func isAlnum(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// ruleModifiers checks the value of each modifier AdGuard Home's DNS filtering knows
+var ruleModifiers = map[string]func(value string, hasValue bool) error{
+	"important": noValue,
+	"badfilter": noValue,
+	"client":    listValue(func(s string) error { return nil }),
+	"denyallow": listValue(func(s string) error {
+		if !isHostname(s) {
+			return fmt.Errorf("%q isn't a domain", s)
+		}
+		return nil
+	}),
+	"ctag": listValue(func(s string) error {
+		for _, prefix := range []string{"device_", "os_", "user_"} {
+			if strings.HasPrefix(s, prefix) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q isn't a client tag such as device_pc or user_child", s)
+	}),
+	"dnstype": listValue(func(s string) error {
+		if !isDNSType(s) {
+			return fmt.Errorf("%q isn't a DNS record type", s)
+		}
+		return nil
+	}),
+	"dnsrewrite": checkDNSRewrite,
+}
+
+func noValue(value string, hasValue bool) error {
+	if hasValue {
+		return fmt.Errorf("takes no value")
+	}
+	return nil
+}
+
+// listValue checks a |-separated value, each entry maybe negated with ~
+func listValue(check func(string) error) func(string, bool) error {
+	return func(value string, hasValue bool) error {
+		if !hasValue || value == "" {
+			return fmt.Errorf("needs a value")
+		}
+		for _, v := range splitUnescaped(value, '|') {
+			v = strings.TrimPrefix(v, "~")
+			if v == "" {
+				return fmt.Errorf("empty entry in %q", value)
+			}
+			if strings.HasPrefix(v, "'") {
+				if !strings.HasSuffix(v, "'") || len(v) < 3 {
+					return fmt.Errorf("unterminated quote in %q", v)
+				}
+				continue
+			}
+			if strings.ContainsAny(v, " \t") {
+				return fmt.Errorf("%q has a space, quote it as '%s'", v, v)
+			}
+			if err := check(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+var dnsTypes = []string{
+	"A", "AAAA", "ANY", "CAA", "CNAME", "DNAME", "DNSKEY", "DS", "HINFO", "HTTPS", "MX",
+	"NAPTR", "NS", "NSEC", "NSEC3", "PTR", "RRSIG", "SOA", "SRV", "SSHFP", "SVCB", "TLSA", "TXT",
+}
+
+func isDNSType(s string) bool {
+	return slices.Contains(dnsTypes, strings.ToUpper(s))
+}
+
+var dnsRcodes = []string{"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED"}
+
+// checkDNSRewrite checks $dnsrewrite's value: an rcode, an IP, a domain for a
+// CNAME, or the full RCODE;TYPE;VALUE form
+func checkDNSRewrite(value string, hasValue bool) error {
+	if !hasValue || value == "" {
+		return fmt.Errorf("needs a value")
+	}
+
+	parts := strings.Split(value, ";")
+	switch len(parts) {
+	case 1:
+		if slices.Contains(dnsRcodes, strings.ToUpper(value)) || isHostname(value) {
+			return nil
+		}
+		if _, err := netip.ParseAddr(value); err == nil {
+			return nil
+		}
+		return fmt.Errorf("%q isn't an IP, a domain or a response code", value)
+
+	case 3:
+		rcode, rrtype, data := strings.ToUpper(parts[0]), strings.ToUpper(parts[1]), parts[2]
+		if !slices.Contains(dnsRcodes, rcode) {
+			return fmt.Errorf("%q isn't a response code", parts[0])
+		}
+		if rrtype == "" && data == "" {
+			return nil
+		}
+		if !isDNSType(rrtype) {
+			return fmt.Errorf("%q isn't a DNS record type", parts[1])
+		}
+		switch rrtype {
+		case "A", "AAAA":
+			addr, err := netip.ParseAddr(data)
+			if rrtype == "A" && (err != nil || !addr.Is4()) {
+				return fmt.Errorf("%q isn't an IPv4 address", data)
+			}
+			if rrtype == "AAAA" && (err != nil || !addr.Is6()) {
+				return fmt.Errorf("%q isn't an IPv6 address", data)
+			}
+		case "CNAME", "PTR", "NS":
+			if !isHostname(data) {
+				return fmt.Errorf("%q isn't a domain", data)
+			}
+		default:
+			if data == "" {
+				return fmt.Errorf("%s record needs a value", rrtype)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("want RCODE;TYPE;VALUE, got %q", value)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_checkRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		ok   bool
+	}{
+		{"", true},
+		{"! a comment", true},
+		{"# a comment", true},
+		{"||example.com^", true},
+		{"@@||example.com^$important", true},
+		{"example.org", true},
+		{"0.0.0.0 ads.example.com tracker.example.com # hosts", true},
+		{"::1 localhost", true},
+		{"0.0.0.0", true},
+		{"/ads[0-9]+\\./", true},
+		{"/ads$/$important", true},
+		{"/ads\\d+/$client=10.0.0.0/8", true},
+		{"/a\\/b[/]c/$important", true},
+		{"||example.com^$client=192.168.1.0/24|'my laptop'", true},
+		{"||example.com^$client=~192.168.1.2", true},
+		{"||example.com^$dnstype=AAAA|~A", true},
+		{"*$denyallow=example.com|example.org", true},
+		{"||example.com^$ctag=device_pc|~user_child", true},
+		{"||example.com^$dnsrewrite=NXDOMAIN", true},
+		{"||example.com^$dnsrewrite=1.2.3.4", true},
+		{"||example.com^$dnsrewrite=NOERROR;A;1.2.3.4", true},
+		{"||example.com^$dnsrewrite=NOERROR;AAAA;::1", true},
+		{"||example.com^$dnsrewrite=NOERROR;CNAME;example.org", true},
+		{"||example.com^$dnsrewrite=NOERROR;TXT;hello world", true},
+
+		{"bad rule here", false},
+		{"0.0.0.0 bad_host!", false},
+		{"/ads[/", false},
+		{"/ads", false},
+		{"||example.com^$", false},
+		{"||example.com^$nope", false},
+		{"||example.com^$important=1", false},
+		{"||example.com^$important,important", false},
+		{"||example.com^$client=my laptop", false},
+		{"||example.com^$client='laptop", false},
+		{"||example.com^$client=", false},
+		{"||example.com^$dnstype=BOGUS", false},
+		{"||example.com^$ctag=pc", false},
+		{"||example.com^$dnsrewrite=NOERROR;A;::1", false},
+		{"||example.com^$dnsrewrite=NOERROR;AAAA;1.2.3.4", false},
+		{"||example.com^$dnsrewrite=NOERROR;A", false},
+		{"||example.com^$dnsrewrite=OOPS;A;1.2.3.4", false},
+		{"exa\"mple.com", false},
+	}
+
+	for _, tt := range tests {
+		if err := checkRule(tt.rule); (err == nil) != tt.ok {
+			t.Errorf("checkRule(%q) = %v, want ok %v", tt.rule, err, tt.ok)
+		}
+	}
+}
+
+func Test_diffRules(t *testing.T) {
+	got := diffRules([]string{"a", "b", "c"}, []string{"b", "c", "d", "d"})
+	want := RulesChange{Added: []string{"d"}, Removed: []string{"a"}, Total: 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func Test_splitRules(t *testing.T) {
+	got := splitRules("a\r\n\nb\n\n  \n")
+	want := []string{"a", "", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func Test_splitModifiers(t *testing.T) {
+	tests := []struct {
+		rule, pattern, modifiers string
+	}{
+		{"||example.com^$important", "||example.com^", "important"},
+		{"/ads$/$important", "/ads$/", "important"},
+		{"/ads\\d+/$client=10.0.0.0/8", "/ads\\d+/", "client=10.0.0.0/8"},
+		{"/ads\\d+/$domain=a.com|b.com/x", "/ads\\d+/", "domain=a.com|b.com/x"},
+		{"/a\\/$b/", "/a\\/$b/", ""},
+	}
+
+	for _, tt := range tests {
+		pattern, modifiers, _ := splitModifiers(tt.rule)
+		if pattern != tt.pattern || modifiers != tt.modifiers {
+			t.Errorf("splitModifiers(%q) = %q, %q", tt.rule, pattern, modifiers)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Custom filtering rules",
+	Long: `Custom filtering rules, the "user rules" in AdGuard Home.
+
+Rules are checked locally before anything is uploaded, so a typo is caught
+before it reaches any server. adctl knows adblock-style rules such as
+||example.com^, @@ exceptions, /regex/ rules and the $important, $badfilter,
+$client, $ctag, $denyallow, $dnstype and $dnsrewrite modifiers, as well as
+hosts file lines, bare domains and ! or # comments.`,
+}
+
+var rulesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the custom filtering rules",
+	Args:  cobra.NoArgs,
+	RunE:  rulesListCmdE,
+}
+
+var rulesAddCmd = &cobra.Command{
+	Use:   "add RULE...",
+	Short: "Add rules, skipping any that are already there",
+	Example: `  adctl rules add '||ads.example.com^'
+  adctl rules add -s all '@@||cdn.example.com^$important' '||example.net^$client=192.168.1.0/24'`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: rulesAddCmdE,
+}
+
+var rulesRemoveCmd = &cobra.Command{
+	Use:   "remove RULE...",
+	Short: "Remove rules, failing if none of them are there",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  rulesRemoveCmdE,
+}
+
+var rulesEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the rules in $EDITOR",
+	Long: `Open the rules in $VISUAL or $EDITOR (vi if neither is set), then upload them
+once the editor exits. Nothing is uploaded if they didn't change or if any rule
+is bad, and the edited file is kept so the work isn't lost.
+
+With several servers their rules have to match, and the edit goes to all of them.`,
+	Args: cobra.NoArgs,
+	RunE: rulesEditCmdE,
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+	rulesCmd.AddCommand(rulesListCmd)
+	rulesCmd.AddCommand(rulesAddCmd)
+	rulesCmd.AddCommand(rulesRemoveCmd)
+	rulesCmd.AddCommand(rulesEditCmd)
+}
+
+// UserRules is the custom filtering rules, one per line
+type UserRules []string
+
+func (r UserRules) Table() ([]string, [][]string) {
+	rows := make([][]string, len(r))
+	for i, rule := range r {
+		rows[i] = []string{rule}
+	}
+	return []string{"rule"}, rows
+}
+
+// RulesChange is what changing the rules did
+type RulesChange struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Total   int      `json:"total"`
+}
+
+func getRules(ctx context.Context, server *common.ServerConfig) (UserRules, error) {
+	c, err := newClient(server)
+	if err != nil {
+		return nil, err
+	}
+	status, err := c.FilteringStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status.UserRules == nil {
+		return UserRules{}, nil
+	}
+	return UserRules(status.UserRules), nil
+}
+
+func rulesListCmdE(cmd *cobra.Command, args []string) error {
+	return forServers(cmd.Context(), getRules)
+}
+
+// changeRules fetches each server's rules, passes them through change and
+// uploads the result if it's different
+func changeRules(ctx context.Context, change func([]string) ([]string, error)) error {
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (RulesChange, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return RulesChange{}, err
+		}
+		status, err := c.FilteringStatus(ctx)
+		if err != nil {
+			return RulesChange{}, err
+		}
+
+		rules, err := change(slices.Clone(status.UserRules))
+		if err != nil {
+			return RulesChange{}, err
+		}
+		ret := diffRules(status.UserRules, rules)
+		if len(ret.Added) == 0 && len(ret.Removed) == 0 {
+			return ret, nil
+		}
+		return ret, c.SetRules(ctx, rules)
+	})
+}
+
+// diffRules says which rules are in new but not old and the other way round
+func diffRules(old, new []string) RulesChange {
+	ret := RulesChange{Added: []string{}, Removed: []string{}, Total: len(new)}
+	for _, r := range new {
+		if !slices.Contains(old, r) && !slices.Contains(ret.Added, r) {
+			ret.Added = append(ret.Added, r)
+		}
+	}
+	for _, r := range old {
+		if !slices.Contains(new, r) && !slices.Contains(ret.Removed, r) {
+			ret.Removed = append(ret.Removed, r)
+		}
+	}
+	return ret
+}
+
+func rulesAddCmdE(cmd *cobra.Command, args []string) error {
+	if err := checkRules(args); err != nil {
+		return err
+	}
+
+	return changeRules(cmd.Context(), func(rules []string) ([]string, error) {
+		for _, r := range args {
+			r = strings.TrimSpace(r)
+			if !slices.Contains(rules, r) {
+				rules = append(rules, r)
+			}
+		}
+		return rules, nil
+	})
+}
+
+func rulesRemoveCmdE(cmd *cobra.Command, args []string) error {
+	remove := make([]string, len(args))
+	for i, r := range args {
+		remove[i] = strings.TrimSpace(r)
+	}
+
+	return changeRules(cmd.Context(), func(rules []string) ([]string, error) {
+		before := len(rules)
+		rules = slices.DeleteFunc(rules, func(r string) bool {
+			return slices.Contains(remove, strings.TrimSpace(r))
+		})
+		if len(rules) == before {
+			return nil, fmt.Errorf("no such rule: %s", strings.Join(remove, ", "))
+		}
+		return rules, nil
+	})
+}
+
+func rulesEditCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+
+	// editing one list for several servers only makes sense if they share it
+	var current UserRules
+	if isMultiServer(servers) {
+		fanned := fanOut(ctx, servers, getRules)
+		if err := common.FanOutErr(fanned); err != nil {
+			return err
+		}
+		current = fanned[0].Value
+		for _, r := range fanned[1:] {
+			if !slices.Equal(r.Value, current) {
+				return fmt.Errorf("rules on %s and %s differ, edit them one server at a time", fanned[0].Server, r.Server)
+			}
+		}
+	} else {
+		var server *common.ServerConfig
+		if len(servers) > 0 {
+			server = &servers[0]
+		}
+		if current, err = getRules(ctx, server); err != nil {
+			return err
+		}
+	}
+
+	edited, file, err := editRules(current)
+	if err != nil {
+		if file != "" {
+			return fmt.Errorf("%w\nnothing uploaded, your edit is in %s", err, file)
+		}
+		return err
+	}
+	if err := checkRules(edited); err != nil {
+		return fmt.Errorf("%w\nnothing uploaded, your edit is in %s", err, file)
+	}
+
+	if slices.Equal(edited, current) {
+		os.Remove(file)
+		fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	}
+
+	err = forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (RulesChange, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return RulesChange{}, err
+		}
+		return diffRules(current, edited), c.SetRules(ctx, edited)
+	})
+	if err != nil {
+		// keep the edit around to try again with
+		return fmt.Errorf("%w\nyour edit is in %s", err, file)
+	}
+	os.Remove(file)
+	return nil
+}
+
+// editRules opens rules in the user's editor and returns what they saved,
+// along with the file it's in. Once the editor has run the file is kept,
+// even on error, so an edit is never lost.
+func editRules(rules []string) ([]string, string, error) {
+	f, err := os.CreateTemp("", "adctl-rules-*.txt")
+	if err != nil {
+		return nil, "", err
+	}
+	file := f.Name()
+
+	text := strings.Join(rules, "\n")
+	if text != "" {
+		text += "\n"
+	}
+	_, err = f.WriteString(text)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+		return nil, "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// the editor may come with arguments, as in "code --wait"
+	argv := append(strings.Fields(editor), file)
+	ed := exec.Command(argv[0], argv[1:]...)
+	ed.Stdin, ed.Stdout, ed.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := ed.Run(); err != nil {
+		return nil, file, fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, file, err
+	}
+	return splitRules(string(b)), file, nil
+}
+
+// splitRules splits text into rules, dropping trailing blank lines
+func splitRules(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
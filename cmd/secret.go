@@ -0,0 +1,168 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// secretCmd represents the secret command
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage the encrypted secrets file",
+	Long: `Store server passwords in a passphrase-encrypted file instead of adctl.yaml.
+Point a server at a secret with password_secret: <name>. The passphrase comes
+from ADCTL_SECRETS_PASSPHRASE, or adctl asks for it once per run.`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Add or replace a secret",
+	Long:  `Add or replace a secret. The value is read from the terminal without echo, or from stdin if it isn't a terminal.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  secretSetCmdE,
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  secretDeleteCmdE,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secret names (never values)",
+	Args:  cobra.NoArgs,
+	RunE:  secretListCmdE,
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretDeleteCmd)
+	secretCmd.AddCommand(secretListCmd)
+
+	common.SecretsPassphrase = promptSecretsPassphrase
+}
+
+// promptSecretsPassphrase uses ADCTL_SECRETS_PASSPHRASE if it's set, otherwise
+// asks on the terminal, twice if the secrets file is being created
+func promptSecretsPassphrase(create bool) (string, error) {
+	if p := os.Getenv("ADCTL_SECRETS_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no passphrase for %s (set ADCTL_SECRETS_PASSPHRASE)", common.SecretsFile)
+	}
+
+	passphrase, err := readHidden("Secrets passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if create {
+		again, err := readHidden("Again: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases don't match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+// readHidden prompts on stderr and reads a line from the terminal without echo
+func readHidden(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return string(b), nil
+}
+
+func secretSetCmdE(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	secrets, err := common.OpenSecrets()
+	if err != nil {
+		return err
+	}
+
+	var value string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		value, err = readHidden(fmt.Sprintf("Value for %s: ", name))
+		if err != nil {
+			return err
+		}
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read value: %w", err)
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+
+	if value == "" {
+		return fmt.Errorf("secret value cannot be empty")
+	}
+
+	secrets[name] = value
+	if err := common.SaveSecrets(secrets); err != nil {
+		return err
+	}
+
+	fmt.Printf("Secret '%s' saved.\n", name)
+	return nil
+}
+
+func secretDeleteCmdE(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	secrets, err := common.OpenSecrets()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("secret '%s' not found", name)
+	}
+
+	delete(secrets, name)
+	if err := common.SaveSecrets(secrets); err != nil {
+		return err
+	}
+
+	fmt.Printf("Secret '%s' deleted.\n", name)
+	return nil
+}
+
+func secretListCmdE(cmd *cobra.Command, args []string) error {
+	secrets, err := common.OpenSecrets()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return render(names)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+)
+
+// selectorChars can't appear in server names because --server gives them meaning
+const selectorChars = "=,*?[]"
+
+// selectServers picks the servers a --server selector refers to, in config order.
+//
+// A selector is a comma-separated list of terms, and a server is picked if any
+// term matches it. A term is one of:
+//
+//	all            every server
+//	key=value      servers tagged key=value (value may be a glob)
+//	name           a server name or group name (either may be a glob)
+//
+// A term that matches nothing is an error, since it's almost certainly a typo.
+func selectServers(servers []common.ServerConfig, selector string) ([]common.ServerConfig, error) {
+	var terms []string
+	for _, term := range strings.Split(selector, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("empty server selector")
+	}
+
+	picked := make([]bool, len(servers))
+	for _, term := range terms {
+		matched := false
+		for i := range servers {
+			ok, err := termMatches(term, &servers[i])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				picked[i] = true
+				matched = true
+			}
+		}
+
+		if !matched {
+			if term == ReservedServerName {
+				continue // no servers configured is fine for "all"
+			}
+			return nil, fmt.Errorf("server '%s' not found", term)
+		}
+	}
+
+	var ret []common.ServerConfig
+	for i, server := range servers {
+		if picked[i] {
+			ret = append(ret, server)
+		}
+	}
+
+	return ret, nil
+}
+
+// termMatches says whether one selector term matches server
+func termMatches(term string, server *common.ServerConfig) (bool, error) {
+	if term == ReservedServerName {
+		return true, nil
+	}
+
+	if key, value, ok := strings.Cut(term, "="); ok {
+		key = strings.ToLower(strings.TrimSpace(key))
+		for k, v := range server.Tags {
+			if strings.ToLower(k) != key {
+				continue
+			}
+			return globMatch(strings.TrimSpace(value), v)
+		}
+		return false, nil
+	}
+
+	if ok, err := globMatch(term, server.Name); ok || err != nil {
+		return ok, err
+	}
+
+	for _, group := range server.Groups {
+		if ok, err := globMatch(term, group); ok || err != nil {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+func globMatch(pattern, s string) (bool, error) {
+	ok, err := path.Match(pattern, s)
+	if err != nil {
+		return false, fmt.Errorf("bad pattern '%s' in server selector: %w", pattern, err)
+	}
+	return ok, nil
+}
+
+// validateServerName rejects names --server couldn't select on their own
+func validateServerName(name string) error {
+	if name == "" {
+		return fmt.Errorf("server name cannot be empty")
+	}
+	if name == ReservedServerName {
+		return fmt.Errorf("'%s' is a reserved server name", ReservedServerName)
+	}
+	if i := strings.IndexAny(name, selectorChars); i >= 0 {
+		return fmt.Errorf("server name can't contain '%c'", name[i])
+	}
+	return nil
+}
+
+// isMultiServer says whether a command should use its multi-server output,
+// i.e. whether the --server selector picked more than one server
+func isMultiServer(servers []common.ServerConfig) bool {
+	return len(servers) > 1
+}
+
+// completeServerSelector offers server names, groups and "all" for --server
+func completeServerSelector(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	servers, err := GetServers()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	ret := []string{ReservedServerName}
+	for _, server := range servers {
+		ret = append(ret, server.Name)
+	}
+	ret = append(ret, serverGroups(servers)...)
+
+	return ret, cobra.ShellCompDirectiveNoFileComp
+}
+
+// serverGroups returns the sorted, de-duplicated groups across servers
+func serverGroups(servers []common.ServerConfig) []string {
+	var ret []string
+	for _, server := range servers {
+		ret = append(ret, server.Groups...)
+	}
+	slices.Sort(ret)
+	return slices.Compact(ret)
+}
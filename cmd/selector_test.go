@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ewosborne/adctl/common"
+)
+
+func Test_selectServers(t *testing.T) {
+	servers := []common.ServerConfig{
+		{Name: "home-primary", Tags: map[string]string{"site": "home", "role": "primary"}, Groups: []string{"dns"}},
+		{Name: "home-secondary", Tags: map[string]string{"site": "home", "role": "secondary"}, Groups: []string{"dns"}},
+		{Name: "cabin", Tags: map[string]string{"Site": "cabin", "role": "primary"}},
+		{Name: "lab", Groups: []string{"testing"}},
+	}
+
+	tests := []struct {
+		selector string
+		want     []string
+		wantErr  bool
+	}{
+		{"all", []string{"home-primary", "home-secondary", "cabin", "lab"}, false},
+		{"cabin", []string{"cabin"}, false},
+		{"site=home", []string{"home-primary", "home-secondary"}, false},
+		{"site=cabin", []string{"cabin"}, false}, // tag keys ignore case
+		{"role=primary", []string{"home-primary", "cabin"}, false},
+		{"site=*", []string{"home-primary", "home-secondary", "cabin"}, false},
+		{"home-*", []string{"home-primary", "home-secondary"}, false},
+		{"dns", []string{"home-primary", "home-secondary"}, false},
+		{"lab, cabin", []string{"cabin", "lab"}, false}, // config order, not selector order
+		{"dns,home-primary", []string{"home-primary", "home-secondary"}, false},
+		{"nope", nil, true},
+		{"cabin,nope", nil, true},
+		{"site=moon", nil, true},
+		{"[", nil, true},
+		{",", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, err := selectServers(servers, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectServers(%q) error = %v, wantErr %v", tt.selector, err, tt.wantErr)
+			}
+
+			var names []string
+			for _, s := range got {
+				names = append(names, s.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("selectServers(%q) = %v, want %v", tt.selector, names, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectServers_noServers(t *testing.T) {
+	got, err := selectServers(nil, ReservedServerName)
+	if err != nil || len(got) != 0 {
+		t.Errorf("selectServers(nil, all) = %v, %v; want nothing and no error", got, err)
+	}
+}
+
+func Test_validateServerName(t *testing.T) {
+	for _, name := range []string{"", "all", "site=home", "a,b", "home-*", "x?", "[a]"} {
+		if err := validateServerName(name); err == nil {
+			t.Errorf("validateServerName(%q) accepted a name --server can't select", name)
+		}
+	}
+	for _, name := range []string{"router", "home-primary", "router.example.com"} {
+		if err := validateServerName(name); err != nil {
+			t.Errorf("validateServerName(%q) = %v", name, err)
+		}
+	}
+}
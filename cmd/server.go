@@ -6,11 +6,12 @@ package cmd
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/cobra"
@@ -28,8 +29,12 @@ var serverCmd = &cobra.Command{
 var serverAddCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a new AdGuard server configuration",
-	Long:  `Interactively prompt for server details and add a new server to the configuration.`,
-	RunE:  serverAddCmdE,
+	Long: `Add a new server to the configuration. With no flags, prompts for the details.
+With --name, nothing is prompted for, which suits provisioning scripts:
+
+  echo "$PW" | adctl server add --name router --host router:8080 --username admin --password-stdin`,
+	Args: cobra.NoArgs,
+	RunE: serverAddCmdE,
 }
 
 // serverListCmd represents the server list command
@@ -40,13 +45,212 @@ var serverListCmd = &cobra.Command{
 	RunE:  serverListCmdE,
 }
 
+// serverLogoutCmd represents the server logout command
+var serverLogoutCmd = &cobra.Command{
+	Use:   "logout [name]",
+	Short: "End the login session for a server",
+	Long: `Log out of servers using auth: session and delete their cached session cookies.
+With no name, logs out of every configured server.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: serverLogoutCmdE,
+}
+
+// serverRemoveCmd represents the server remove command
+var serverRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a server configuration",
+	Args:    cobra.ExactArgs(1),
+	RunE:    serverRemoveCmdE,
+}
+
+// serverEditCmd represents the server edit command
+var serverEditCmd = &cobra.Command{
+	Use:   "edit <name>",
+	Short: "Change a server's host, credentials or TLS settings",
+	Long: `Change a server's settings without re-adding it. Only the flags you give are changed.
+With no flags, prompts for host, username and password, keeping the current value on an empty answer.`,
+	Args: cobra.ExactArgs(1),
+	RunE: serverEditCmdE,
+}
+
+// serverRenameCmd represents the server rename command
+var serverRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a server",
+	Args:  cobra.ExactArgs(2),
+	RunE:  serverRenameCmdE,
+}
+
+// serverTestCmd represents the server test command
+var serverTestCmd = &cobra.Command{
+	Use:   "test [name...]",
+	Short: "Check that servers are reachable and the credentials work",
+	Long: `Log into each server, fetch /control/status and report its version, latency and DNS addresses.
+The latency is the whole status call, so it includes running password_command
+and logging in when those are needed. With no names, tests whatever --server selects.`,
+	RunE: serverTestCmdE,
+}
+
+// serverDefaultCmd represents the server default command
+var serverDefaultCmd = &cobra.Command{
+	Use:   "default [name]",
+	Short: "Show or set the server --server defaults to",
+	Long: `With a name, makes that server the default for --server. With no name, shows the current default.
+--clear goes back to targeting all servers.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: serverDefaultCmdE,
+}
+
 func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.AddCommand(serverAddCmd)
 	serverCmd.AddCommand(serverListCmd)
+	serverCmd.AddCommand(serverLogoutCmd)
+	serverCmd.AddCommand(serverRemoveCmd)
+	serverCmd.AddCommand(serverEditCmd)
+	serverCmd.AddCommand(serverRenameCmd)
+	serverCmd.AddCommand(serverTestCmd)
+	serverCmd.AddCommand(serverDefaultCmd)
+
+	serverAddCmd.Flags().String("name", "", "Server name (skips the prompts)")
+	addServerFlags(serverAddCmd)
+	addServerFlags(serverEditCmd)
+
+	serverDefaultCmd.Flags().Bool("clear", false, "Clear the default so --server targets all servers")
+}
+
+// addServerFlags adds the flags shared by server add and server edit
+func addServerFlags(cmd *cobra.Command) {
+	cmd.Flags().String("host", "", "host:port or URL, e.g. router.example.com:8080 or https://router.example.com")
+	cmd.Flags().String("username", "", "Username")
+	cmd.Flags().Bool("password-stdin", false, "Read the password from the first line of stdin")
+	cmd.Flags().String("password-env", "", "Read the password from this environment variable at run time")
+	cmd.Flags().String("password-command", "", "Run this command at run time and use the first line it prints as the password")
+	cmd.Flags().String("password-secret", "", "Use this entry in the encrypted secrets file as the password")
+	cmd.Flags().String("auth", "", "basic or session")
+	cmd.Flags().String("scheme", "", "http or https (ignored if host is a URL)")
+	cmd.Flags().String("ca-cert", "", "PEM bundle of extra CAs to trust")
+	cmd.Flags().String("client-cert", "", "PEM client certificate for mTLS")
+	cmd.Flags().String("client-key", "", "PEM client key for mTLS")
+	cmd.Flags().Bool("insecure-skip-verify", false, "Don't verify the server's TLS certificate")
+	cmd.Flags().StringToString("tag", nil, "Tag as key=value for --server selectors, repeatable (an empty value removes the tag)")
+	cmd.Flags().StringSlice("group", nil, "Groups for --server selectors, comma separated or repeated (replaces existing groups)")
+}
+
+// serverFlagNames are the flags addServerFlags adds
+var serverFlagNames = []string{
+	"host", "username", "password-stdin", "password-env", "password-command", "password-secret",
+	"auth", "scheme", "ca-cert", "client-cert", "client-key", "insecure-skip-verify", "tag", "group",
+}
+
+// serverFlagsChanged says whether any of the server flags were given. Global
+// flags such as -o don't count.
+func serverFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range serverFlagNames {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyServerFlags copies whichever server flags were given onto server.
+// Setting any password source replaces whichever one the server had before.
+func applyServerFlags(cmd *cobra.Command, server *common.ServerConfig) error {
+	flags := cmd.Flags()
+
+	strFlags := map[string]*string{
+		"host":        &server.Host,
+		"username":    &server.Username,
+		"auth":        &server.Auth,
+		"scheme":      &server.Scheme,
+		"ca-cert":     &server.CACert,
+		"client-cert": &server.ClientCert,
+		"client-key":  &server.ClientKey,
+	}
+	for name, field := range strFlags {
+		if flags.Changed(name) {
+			*field, _ = flags.GetString(name)
+		}
+	}
+
+	if flags.Changed("auth") {
+		switch strings.ToLower(server.Auth) {
+		case "", common.AuthBasic, common.AuthSession:
+		default:
+			return fmt.Errorf("--auth must be %s or %s, not %q", common.AuthBasic, common.AuthSession, server.Auth)
+		}
+	}
+
+	if flags.Changed("insecure-skip-verify") {
+		server.InsecureSkipVerify, _ = flags.GetBool("insecure-skip-verify")
+	}
+
+	if flags.Changed("tag") {
+		tags, _ := flags.GetStringToString("tag")
+		if server.Tags == nil {
+			server.Tags = make(map[string]string)
+		}
+		for k, v := range tags {
+			if v == "" {
+				delete(server.Tags, k)
+			} else {
+				server.Tags[k] = v
+			}
+		}
+	}
+
+	if flags.Changed("group") {
+		groups, _ := flags.GetStringSlice("group")
+		server.Groups = nil
+		for _, group := range groups {
+			if strings.ContainsAny(group, "=,") {
+				return fmt.Errorf("group name can't contain '=' or ','")
+			}
+			if group != "" {
+				server.Groups = append(server.Groups, group)
+			}
+		}
+	}
+
+	var sources []string
+	for _, name := range []string{"password-stdin", "password-env", "password-command", "password-secret"} {
+		if flags.Changed(name) {
+			sources = append(sources, "--"+name)
+		}
+	}
+	if len(sources) > 1 {
+		return fmt.Errorf("only one of %s can be given", strings.Join(sources, ", "))
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	server.Password = ""
+	server.PasswordEnv, _ = flags.GetString("password-env")
+	server.PasswordCommand, _ = flags.GetString("password-command")
+	server.PasswordSecret, _ = flags.GetString("password-secret")
+
+	if sources[0] == "--password-stdin" {
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			return fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		server.Password = strings.TrimRight(password, "\r\n")
+		if server.Password == "" {
+			return fmt.Errorf("password cannot be empty")
+		}
+	}
+
+	return nil
 }
 
 func serverAddCmdE(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("name") {
+		return serverAddNonInteractive(cmd)
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 
 	// Prompt for server name
@@ -57,11 +261,8 @@ func serverAddCmdE(cmd *cobra.Command, args []string) error {
 	}
 	name = strings.TrimSpace(name)
 
-	if name == "" {
-		return fmt.Errorf("server name cannot be empty")
-	}
-	if name == ReservedServerName {
-		return fmt.Errorf("'%s' is a reserved server name", ReservedServerName)
+	if err := validateServerName(name); err != nil {
+		return err
 	}
 
 	// Check if server already exists
@@ -70,7 +271,7 @@ func serverAddCmdE(cmd *cobra.Command, args []string) error {
 	}
 
 	// Prompt for host
-	fmt.Print("Host (host:port, e.g., router.example.com:8080): ")
+	fmt.Print("Host (host:port or URL, e.g., router.example.com:8080 or https://router.example.com): ")
 	host, err := reader.ReadString('\n')
 	if err != nil {
 		return fmt.Errorf("failed to read host: %w", err)
@@ -123,6 +324,23 @@ func serverAddCmdE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// serverAddNonInteractive adds a server entirely from flags
+func serverAddNonInteractive(cmd *cobra.Command) error {
+	var server common.ServerConfig
+	server.Name, _ = cmd.Flags().GetString("name")
+
+	if err := applyServerFlags(cmd, &server); err != nil {
+		return err
+	}
+
+	if err := AddServer(server); err != nil {
+		return fmt.Errorf("failed to add server: %w", err)
+	}
+
+	fmt.Printf("Server '%s' added successfully.\n", server.Name)
+	return nil
+}
+
 func serverListCmdE(cmd *cobra.Command, args []string) error {
 	servers, err := GetServers()
 	if err != nil {
@@ -136,28 +354,261 @@ func serverListCmdE(cmd *cobra.Command, args []string) error {
 
 	// Create a list with masked passwords for display
 	type ServerDisplay struct {
-		Name     string `json:"name"`
-		Host     string `json:"host"`
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Name               string            `json:"name"`
+		Host               string            `json:"host"`
+		Username           string            `json:"username"`
+		Password           string            `json:"password,omitempty"`
+		PasswordEnv        string            `json:"password_env,omitempty"`
+		PasswordCommand    string            `json:"password_command,omitempty"`
+		PasswordSecret     string            `json:"password_secret,omitempty"`
+		Scheme             string            `json:"scheme,omitempty"`
+		CACert             string            `json:"ca_cert,omitempty"`
+		ClientCert         string            `json:"client_cert,omitempty"`
+		ClientKey          string            `json:"client_key,omitempty"`
+		InsecureSkipVerify bool              `json:"insecure_skip_verify,omitempty"`
+		Auth               string            `json:"auth,omitempty"`
+		Tags               map[string]string `json:"tags,omitempty"`
+		Groups             []string          `json:"groups,omitempty"`
 	}
 
 	displayServers := make([]ServerDisplay, len(servers))
 	for i, s := range servers {
+		// password_command and friends only say where the password lives, so they're safe to show
+		var password string
+		if s.Password != "" {
+			password = "***"
+		}
+
 		displayServers[i] = ServerDisplay{
-			Name:     s.Name,
-			Host:     s.Host,
-			Username: s.Username,
-			Password: "***",
+			Name:               s.Name,
+			Host:               s.Host,
+			Username:           s.Username,
+			Password:           password,
+			PasswordEnv:        s.PasswordEnv,
+			PasswordCommand:    s.PasswordCommand,
+			PasswordSecret:     s.PasswordSecret,
+			Scheme:             s.Scheme,
+			CACert:             s.CACert,
+			ClientCert:         s.ClientCert,
+			ClientKey:          s.ClientKey,
+			InsecureSkipVerify: s.InsecureSkipVerify,
+			Auth:               s.Auth,
+			Tags:               s.Tags,
+			Groups:             s.Groups,
 		}
 	}
 
 	// Output as JSON
-	output, err := json.MarshalIndent(displayServers, "", " ")
+	return render(displayServers)
+}
+
+func serverLogoutCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	var servers []common.ServerConfig
+	if len(args) == 1 {
+		server, err := GetServer(args[0])
+		if err != nil {
+			return err
+		}
+		servers = []common.ServerConfig{*server}
+	} else {
+		var err error
+		servers, err = GetServers()
+		if err != nil {
+			return fmt.Errorf("failed to get servers: %w", err)
+		}
+	}
+
+	fanned := fanOut(ctx, servers, func(ctx context.Context, server *common.ServerConfig) (struct{}, error) {
+		return struct{}{}, common.Logout(ctx, server)
+	})
+
+	for _, r := range fanned {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.Server, r.Err)
+			continue
+		}
+		fmt.Printf("Logged out of '%s'.\n", r.Server)
+	}
+
+	return common.FanOutErr(fanned)
+}
+
+func serverRemoveCmdE(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if err := RemoveServer(name); err != nil {
+		return fmt.Errorf("failed to remove server: %w", err)
+	}
+	if err := common.ForgetSession(name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Server '%s' removed.\n", name)
+	return nil
+}
+
+func serverEditCmdE(cmd *cobra.Command, args []string) error {
+	server, err := GetServer(args[0])
+	if err != nil {
+		return err
+	}
+
+	if serverFlagsChanged(cmd) {
+		if err := applyServerFlags(cmd, server); err != nil {
+			return err
+		}
+	} else if err := promptServerEdits(server); err != nil {
+		return err
+	}
+
+	if err := UpdateServer(*server); err != nil {
+		return fmt.Errorf("failed to update server: %w", err)
+	}
+
+	// the old session may belong to the old host or user
+	if err := common.ForgetSession(server.Name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Server '%s' updated.\n", server.Name)
+	return nil
+}
+
+// promptServerEdits asks for a new host, username and password, keeping the
+// current value when the answer is empty
+func promptServerEdits(server *common.ServerConfig) error {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Printf("Host [%s]: ", server.Host)
+	host, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read host: %w", err)
+	}
+	if host = strings.TrimSpace(host); host != "" {
+		server.Host = host
+	}
+
+	fmt.Printf("Username [%s]: ", server.Username)
+	username, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read username: %w", err)
+	}
+	if username = strings.TrimSpace(username); username != "" {
+		server.Username = username
+	}
+
+	// Prompt for password (masked)
+	fmt.Print("Password (leave empty to keep the current one): ")
+	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
-		return fmt.Errorf("failed to marshal servers: %w", err)
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	fmt.Println() // New line after password input
+	if len(passwordBytes) > 0 {
+		server.Password = string(passwordBytes)
+		server.PasswordEnv = ""
+		server.PasswordCommand = ""
+		server.PasswordSecret = ""
+	}
+
+	return nil
+}
+
+func serverRenameCmdE(cmd *cobra.Command, args []string) error {
+	oldName, newName := args[0], args[1]
+
+	if err := RenameServer(oldName, newName); err != nil {
+		return fmt.Errorf("failed to rename server: %w", err)
+	}
+	if err := common.ForgetSession(oldName); err != nil {
+		return err
+	}
+
+	fmt.Printf("Server '%s' renamed to '%s'.\n", oldName, newName)
+	return nil
+}
+
+// ServerTestResult is what server test reports for each server that answered
+type ServerTestResult struct {
+	Version string `json:"version"`
+	// LatencyMs covers the status call, including any password lookup and login
+	LatencyMs    int64    `json:"latency_ms"`
+	DNSAddresses []string `json:"dns_addresses"`
+}
+
+func serverTestCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	var servers []common.ServerConfig
+	if len(args) > 0 {
+		for _, name := range args {
+			server, err := GetServer(name)
+			if err != nil {
+				return err
+			}
+			servers = append(servers, *server)
+		}
+	} else {
+		var err error
+		servers, err = GetCurrentServers()
+		if err != nil {
+			return fmt.Errorf("failed to get servers: %w", err)
+		}
+	}
+
+	if len(servers) == 0 {
+		return fmt.Errorf("no servers configured")
+	}
+
+	fanned := fanOut(ctx, servers, func(ctx context.Context, server *common.ServerConfig) (ServerTestResult, error) {
+		var ret ServerTestResult
+
+		c, err := newClient(server)
+		if err != nil {
+			return ret, err
+		}
+
+		// the status call looks up the password and logs in as needed, so
+		// those count towards the latency too
+		start := time.Now()
+		s, err := c.Status(ctx)
+		if err != nil {
+			return ret, err
+		}
+
+		ret.LatencyMs = time.Since(start).Milliseconds()
+		ret.Version = s.Version
+		ret.DNSAddresses = s.DNSAddresses
+		return ret, nil
+	})
+
+	return renderResults(fanned)
+}
+
+func serverDefaultCmdE(cmd *cobra.Command, args []string) error {
+	clearDefault, _ := cmd.Flags().GetBool("clear")
+
+	switch {
+	case clearDefault && len(args) > 0:
+		return fmt.Errorf("give a server name or --clear, not both")
+
+	case clearDefault:
+		if err := SetDefaultServer(""); err != nil {
+			return fmt.Errorf("failed to clear default server: %w", err)
+		}
+		fmt.Printf("Default server cleared, --server now defaults to '%s'.\n", ReservedServerName)
+
+	case len(args) == 1:
+		if err := SetDefaultServer(args[0]); err != nil {
+			return fmt.Errorf("failed to set default server: %w", err)
+		}
+		fmt.Printf("Default server set to '%s'.\n", args[0])
+
+	default:
+		fmt.Println(GetDefaultServer())
 	}
 
-	fmt.Println(string(output))
 	return nil
 }
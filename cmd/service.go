@@ -4,10 +4,12 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"maps"
 	"slices"
 
+	"github.com/ewosborne/adctl/client"
 	"github.com/ewosborne/adctl/common"
 	"github.com/spf13/cobra"
 )
@@ -74,6 +76,7 @@ func unique(list []string) []string {
 }
 
 func UpdateServiceCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
 
 	// TODO hack
 	if len(toBlock) == 0 && len(toUnblock) == 0 {
@@ -83,33 +86,16 @@ func UpdateServiceCmdE(cmd *cobra.Command, args []string) error {
 	toBlock = unique(toBlock)
 	toUnblock = unique(toUnblock)
 
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		svcs := ServiceLists{block: toBlock, permit: toUnblock}
-		return updateServicesAll(servers, svcs)
-	}
-
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
 	svcs := ServiceLists{block: toBlock, permit: toUnblock}
-	err = updateServices(server, svcs)
-	if err != nil {
-		return fmt.Errorf("error updating services %w", err)
-	}
-
-	return nil
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (BlockedWithCount, error) {
+		if err := updateServices(ctx, server, svcs); err != nil {
+			return BlockedWithCount{}, fmt.Errorf("error updating services %w", err)
+		}
+		return blockedWithCount(ctx, server)
+	})
 }
 
-func computeNewBlocks(currentlyBlocked AllBlockedServices, changes ServiceLists) ([]string, error) {
+func computeNewBlocks(currentlyBlocked client.BlockedServices, changes ServiceLists) ([]string, error) {
 	ret := []string{}
 	svcmap := make(map[string]bool)
 
@@ -158,10 +144,10 @@ func computeNewBlocks(currentlyBlocked AllBlockedServices, changes ServiceLists)
 	return ret, nil
 }
 
-func updateServices(server *common.ServerConfig, svcs ServiceLists) error {
+func updateServices(ctx context.Context, server *common.ServerConfig, svcs ServiceLists) error {
 
 	// Get current blocked services to compute the new list
-	blocked, err := GetBlockedServices(server)
+	blocked, err := GetBlockedServices(ctx, server)
 	if err != nil {
 		return fmt.Errorf("error calling GetBlockedServices %w", err)
 	}
@@ -171,38 +157,26 @@ func updateServices(server *common.ServerConfig, svcs ServiceLists) error {
 		return fmt.Errorf("error computing new blocks: %w", err)
 	}
 
-	var requestBody = make(map[string]any)
-	requestBody["ids"] = newList
 	// Send nil for schedule - AdGuard will set it to EmptyWeekly() which means
 	// no time restrictions (services always blocked). This allows schedules to be
 	// cleared/overwritten when updating services.
-	requestBody["schedule"] = nil
+	update := client.BlockedServices{IDs: newList, Schedule: nil}
 
-	baseURL, err := common.GetBaseURL(server)
+	c, err := newClient(server)
 	if err != nil {
 		return err
 	}
 
-	baseURL.Path = "/control/blocked_services/update"
-
-	debugLogger.Println("going to update with", requestBody)
-
-	// put it all together
-	enableQuery := common.CommandArgs{
-		Method:      "PUT",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
+	debugLogger.Println("going to update with", update)
 
 	// Send the update
-	_, err = common.SendCommand(enableQuery)
+	err = c.UpdateBlockedServices(ctx, update)
 	if err != nil {
 		return err
 	}
 
 	// Verify the update was successful
-	s, err := GetBlockedServices(server)
+	s, err := GetBlockedServices(ctx, server)
 	if err != nil {
 		return fmt.Errorf("error getting blocked services %w", err)
 	}
@@ -213,23 +187,10 @@ func updateServices(server *common.ServerConfig, svcs ServiceLists) error {
 		return fmt.Errorf("service lists unequal: expected %v, got %v", newList, s.IDs)
 	}
 
-	err = PrintBlockedServices()
-	if err != nil {
-		return err
-	}
-
 	return nil
 
 }
 
-type Service struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
-type AllServices struct {
-	AllServices []Service `json:"blocked_services"`
-}
-
 type ServiceMap struct {
 	ID2Name map[string]string
 	Name2ID map[string]string
@@ -243,8 +204,9 @@ func NewServiceMap() ServiceMap {
 }
 
 func ListAllCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
 
-	err := PrintAllServices()
+	err := PrintAllServices(ctx)
 	if err != nil {
 		return fmt.Errorf("error somewhere %w", err)
 	}
@@ -252,56 +214,28 @@ func ListAllCmdE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// TODO: make this json or text
-func PrintAllServices() error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return printAllServicesAll(servers)
-	}
+func PrintAllServices(ctx context.Context) error {
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (serviceList, error) {
+		smap, err := GetAllServices(ctx, server)
+		return serviceList(smap.Name2ID), err
+	})
+}
 
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
+// serviceList maps service names to IDs, one row per service in table output
+type serviceList map[string]string
 
-	smap, err := GetAllServices(server)
-	name2id := smap.Name2ID
+func (s serviceList) Table() ([]string, [][]string) {
+	names := slices.Sorted(maps.Keys(s))
 
-	if err != nil {
-		return err
+	rows := make([][]string, len(names))
+	for i, name := range names {
+		rows[i] = []string{name, s[name]}
 	}
 
-	// print name2id
-	// if json
-	b, err := json.MarshalIndent(name2id, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(b))
-
-	// if text
-
-	// s := slices.Collect(maps.Keys(name2id))
-	// sort.Strings(s)
-
-	// w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-	// defer w.Flush() // TODO I can't decide if this is dumb or not
-	// fmt.Fprintf(w, "Name\tID\n")
-	// fmt.Fprintf(w, "====\t==\n")
-
-	// for _, k := range s {
-	// 	fmt.Fprintf(w, "%s\t%s\n", k, name2id[k])
-	// }
-	return nil
+	return []string{"name", "id"}, rows
 }
 
-func GetAllServices(server *common.ServerConfig) (ServiceMap, error) {
+func GetAllServices(ctx context.Context, server *common.ServerConfig) (ServiceMap, error) {
 
 	ret := NewServiceMap()
 
@@ -310,31 +244,17 @@ func GetAllServices(server *common.ServerConfig) (ServiceMap, error) {
 
 	// get the data
 
-	baseURL, err := common.GetBaseURL(server)
+	c, err := newClient(server)
 	if err != nil {
 		return ret, err
 	}
-	baseURL.Path = "/control/blocked_services/all"
-
-	statusQuery := common.CommandArgs{
-		Method: "GET",
-		URL:    baseURL,
-		Server: server,
-	}
 
-	body, err := common.SendCommand(statusQuery)
+	s, err := c.BlockableServices(ctx)
 	if err != nil {
 		return ret, err
 	}
 
-	// TODO: marshal body into something that pulls out name and ID.  AllServices{ Service } however I do that.
-
-	// this is a very confusing mess of nested structs
-
-	var s AllServices
-	json.Unmarshal(body, &s)
-
-	for _, x := range s.AllServices {
+	for _, x := range s.BlockedServices {
 		id2name[x.ID] = x.Name
 		name2id[x.Name] = x.ID
 
@@ -343,14 +263,10 @@ func GetAllServices(server *common.ServerConfig) (ServiceMap, error) {
 	return ret, nil
 }
 
-type AllBlockedServices struct {
-	Schedule map[string]any `json:"schedule"`
-	IDs      []string       `json:"ids"`
-}
-
 func serviceListBlockedCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
 
-	err := PrintBlockedServices()
+	err := PrintBlockedServices(ctx)
 	if err != nil {
 		return fmt.Errorf("error somewhere %w", err)
 	}
@@ -363,173 +279,34 @@ type BlockedWithCount struct {
 	IDs   []string `json:"IDs"`
 }
 
-func PrintBlockedServices() error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return printBlockedServicesAll(servers)
-	}
-
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	s, err := GetBlockedServices(server)
-
-	if err != nil {
-		return err
-	}
-
-	// json
-	var x BlockedWithCount
-	x.Count = len(s.IDs)
-	x.IDs = s.IDs
-	//x := BlockedWithCount{Count: len(s.IDs), AllBlockedServices.AllBlockedServices: s.IDs}
-	b, err := json.MarshalIndent(x, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(b))
-
-	// text
-	// if len(s.IDs) == 0 {
-	// 	fmt.Println("no services blocked")
-	// } else {
-	// 	allServices, err := GetAllServices()
-	// 	if err != nil {
-	// 		return fmt.Errorf("error getting all services: %w", err)
-	// 	}
-	// 	for _, x := range s.IDs {
-	// 		fmt.Println("svc blocked", allServices.ID2Name[x])
-	// 	}
-	// }
-
-	return nil
+func PrintBlockedServices(ctx context.Context) error {
+	return forServers(ctx, blockedWithCount)
 }
 
-func GetBlockedServices(server *common.ServerConfig) (AllBlockedServices, error) {
-
-	// get the data
-
-	ret := AllBlockedServices{}
-
-	baseURL, err := common.GetBaseURL(server)
+func blockedWithCount(ctx context.Context, server *common.ServerConfig) (BlockedWithCount, error) {
+	s, err := GetBlockedServices(ctx, server)
 	if err != nil {
-		return ret, err
+		return BlockedWithCount{}, err
 	}
-	baseURL.Path = "/control/blocked_services/get"
-
-	statusQuery := common.CommandArgs{
-		Method: "GET",
-		URL:    baseURL,
-		Server: server,
-	}
-
-	body, err := common.SendCommand(statusQuery)
-	if err != nil {
-		return ret, err
-	}
-
-	var s AllBlockedServices
-	json.Unmarshal(body, &s)
-
-	return s, nil
+	return BlockedWithCount{Count: len(s.IDs), IDs: s.IDs}, nil
 }
 
-func printAllServicesAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string            `json:"server"`
-		Result map[string]string `json:"result,omitempty"`
-		Error  string            `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		smap, err := GetAllServices(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = smap.Name2ID
-		}
-		results = append(results, result)
+func (b BlockedWithCount) Table() ([]string, [][]string) {
+	rows := make([][]string, len(b.IDs))
+	for i, id := range b.IDs {
+		rows[i] = []string{id}
 	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(output))
-	return nil
+	return []string{"id"}, rows
 }
 
-func printBlockedServicesAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string            `json:"server"`
-		Result BlockedWithCount `json:"result,omitempty"`
-		Error  string            `json:"error,omitempty"`
-	}
+func GetBlockedServices(ctx context.Context, server *common.ServerConfig) (client.BlockedServices, error) {
 
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		s, err := GetBlockedServices(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Result = BlockedWithCount{
-				Count: len(s.IDs),
-				IDs:   s.IDs,
-			}
-		}
-		results = append(results, result)
-	}
+	// get the data
 
-	output, err := json.MarshalIndent(results, "", " ")
+	c, err := newClient(server)
 	if err != nil {
-		return err
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func updateServicesAll(servers []common.ServerConfig, svcs ServiceLists) error {
-	type ServerResult struct {
-		Server string            `json:"server"`
-		Result BlockedWithCount `json:"result,omitempty"`
-		Error  string            `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := updateServices(&server, svcs)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			s, err := GetBlockedServices(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Result = BlockedWithCount{
-					Count: len(s.IDs),
-					IDs:   s.IDs,
-				}
-			}
-		}
-		results = append(results, result)
+		return client.BlockedServices{}, err
 	}
 
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(output))
-	return nil
+	return c.BlockedServices(ctx)
 }
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"slices"
 	"testing"
+
+	"github.com/ewosborne/adctl/client"
 )
 
 // TODO: testscript
@@ -13,7 +16,7 @@ func Test_ListAll(t *testing.T) {
 		t.Skip("integration test requires ADCTL_HOST, ADCTL_USERNAME, and ADCTL_PASSWORD")
 	}
 
-	_, err := GetAllServices(nil)
+	_, err := GetAllServices(context.Background(), nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -25,7 +28,7 @@ func Test_ListBlocked(t *testing.T) {
 		t.Skip("integration test requires ADCTL_HOST, ADCTL_USERNAME, and ADCTL_PASSWORD")
 	}
 
-	_, err := GetBlockedServices(nil)
+	_, err := GetBlockedServices(context.Background(), nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -42,7 +45,7 @@ func Test_Update(t *testing.T) {
 func Test_computeNewBlock(t *testing.T) {
 	// try an internal test thing
 
-	// computeNewBlocks(currentlyBlocked AllBlockedServices, changes ServiceLists) ([]string, error)
+	// computeNewBlocks(currentlyBlocked client.BlockedServices, changes ServiceLists) ([]string, error)
 
 	var tt = []struct {
 		currentlyBlocked []string
@@ -84,7 +87,7 @@ func Test_computeNewBlock(t *testing.T) {
 
 	for _, entry := range tt {
 		t.Log(entry)
-		cb := AllBlockedServices{IDs: entry.currentlyBlocked}
+		cb := client.BlockedServices{IDs: entry.currentlyBlocked}
 		changes := ServiceLists{block: entry.block, permit: entry.permit}
 
 		res, err := computeNewBlocks(cb, changes)
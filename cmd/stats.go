@@ -0,0 +1,498 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Query statistics",
+}
+
+var statsGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Show query totals and the top domains, clients and upstreams",
+	Long: `Show query totals and the top domains, clients and upstreams.
+With several servers each server's numbers are followed by a merged total.`,
+	Args: cobra.NoArgs,
+	RunE: statsGetCmdE,
+}
+
+var statsConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Statistics settings",
+}
+
+var statsConfigGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Show the statistics interval and ignored domains",
+	Args:  cobra.NoArgs,
+	RunE:  statsConfigGetCmdE,
+}
+
+var statsConfigSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Change the statistics interval or ignored domains",
+	Long: `Change the statistics settings. Only the flags you give change.
+--interval takes a Go duration or a number of days, e.g. 24h or 7d.`,
+	Args: cobra.NoArgs,
+	RunE: statsConfigSetCmdE,
+}
+
+var statsResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Clear all statistics (asks first unless --yes)",
+	Args:  cobra.NoArgs,
+	RunE:  statsResetCmdE,
+}
+
+var statsTop int
+var statsYes bool
+
+var statsConfigEnabled bool
+var statsConfigInterval string
+var statsConfigIgnored []string
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.AddCommand(statsGetCmd)
+	statsCmd.AddCommand(statsConfigCmd)
+	statsCmd.AddCommand(statsResetCmd)
+	statsConfigCmd.AddCommand(statsConfigGetCmd)
+	statsConfigCmd.AddCommand(statsConfigSetCmd)
+
+	statsGetCmd.Flags().IntVar(&statsTop, "top", 10, "How many top domains, clients and upstreams to show (0 for all)")
+
+	statsConfigSetCmd.Flags().BoolVar(&statsConfigEnabled, "enabled", true, "Collect statistics")
+	statsConfigSetCmd.Flags().StringVar(&statsConfigInterval, "interval", "", "How long to keep statistics, e.g. 24h, 7d, 90d")
+	statsConfigSetCmd.Flags().StringSliceVar(&statsConfigIgnored, "ignored", nil, "Domains to leave out of statistics, replacing the current list (\"\" clears it)")
+
+	statsResetCmd.Flags().BoolVarP(&statsYes, "yes", "y", false, "Don't ask for confirmation")
+}
+
+// StatsSummary is what stats get prints for a server
+type StatsSummary struct {
+	TimeUnits            string        `json:"time_units"`
+	DNSQueries           uint64        `json:"dns_queries"`
+	Blocked              uint64        `json:"blocked"`
+	BlockedPercent       float64       `json:"blocked_percent"`
+	ReplacedSafebrowsing uint64        `json:"replaced_safebrowsing"`
+	ReplacedSafesearch   uint64        `json:"replaced_safesearch"`
+	ReplacedParental     uint64        `json:"replaced_parental"`
+	AvgProcessingMs      float64       `json:"avg_processing_ms"`
+	TopQueriedDomains    []TopCount    `json:"top_queried_domains"`
+	TopBlockedDomains    []TopCount    `json:"top_blocked_domains"`
+	TopClients           []TopCount    `json:"top_clients"`
+	TopUpstreams         []TopUpstream `json:"top_upstreams"`
+}
+
+type TopCount struct {
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+}
+
+type TopUpstream struct {
+	Name      string  `json:"name"`
+	Responses uint64  `json:"responses"`
+	AvgMs     float64 `json:"avg_ms"`
+}
+
+// Table lists the totals and then each top list, one row per entry
+func (s StatsSummary) Table() ([]string, [][]string) {
+	rows := [][]string{
+		{"totals", "dns_queries", fmt.Sprint(s.DNSQueries), ""},
+		{"totals", "blocked", fmt.Sprint(s.Blocked), ""},
+		{"totals", "blocked_percent", fmt.Sprint(s.BlockedPercent), ""},
+		{"totals", "replaced_safebrowsing", fmt.Sprint(s.ReplacedSafebrowsing), ""},
+		{"totals", "replaced_safesearch", fmt.Sprint(s.ReplacedSafesearch), ""},
+		{"totals", "replaced_parental", fmt.Sprint(s.ReplacedParental), ""},
+		{"totals", "avg_processing_ms", "", fmt.Sprint(s.AvgProcessingMs)},
+	}
+
+	for _, top := range []struct {
+		section string
+		list    []TopCount
+	}{
+		{"top_queried_domains", s.TopQueriedDomains},
+		{"top_blocked_domains", s.TopBlockedDomains},
+		{"top_clients", s.TopClients},
+	} {
+		for _, e := range top.list {
+			rows = append(rows, []string{top.section, e.Name, fmt.Sprint(e.Count), ""})
+		}
+	}
+
+	for _, u := range s.TopUpstreams {
+		rows = append(rows, []string{"top_upstreams", u.Name, fmt.Sprint(u.Responses), fmt.Sprint(u.AvgMs)})
+	}
+
+	return []string{"section", "name", "count", "avg_ms"}, rows
+}
+
+// StatsAll is what stats get prints for several servers
+type StatsAll struct {
+	Servers ServerResults[StatsSummary] `json:"servers"`
+	Total   StatsSummary                `json:"total"`
+}
+
+// Table is the per-server table followed by the total's rows under server "total"
+func (s StatsAll) Table() ([]string, [][]string) {
+	header, rows := s.Servers.Table()
+
+	_, total := s.Total.Table()
+	for _, t := range total {
+		row := make([]string, len(header))
+		row[0] = "total"
+		copy(row[1:], t)
+		rows = append(rows, row)
+	}
+
+	return header, rows
+}
+
+func statsGetCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+
+	if !isMultiServer(servers) {
+		return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (StatsSummary, error) {
+			s, err := getStats(ctx, server)
+			return summarizeStats(s, statsTop), err
+		})
+	}
+
+	fanned := fanOut(ctx, servers, getStats)
+
+	var ok []client.Stats
+	summaries := make([]common.FanOutResult[StatsSummary], len(fanned))
+	for i, r := range fanned {
+		summaries[i] = common.FanOutResult[StatsSummary]{Server: r.Server, Err: r.Err, Duration: r.Duration}
+		if r.Err == nil {
+			summaries[i].Value = summarizeStats(r.Value, statsTop)
+			ok = append(ok, r.Value)
+		}
+	}
+
+	out := StatsAll{
+		Servers: NewServerResults(summaries),
+		Total:   summarizeStats(mergeStats(ok), statsTop),
+	}
+	if err := render(out); err != nil {
+		return err
+	}
+	return common.FanOutErr(fanned)
+}
+
+func getStats(ctx context.Context, server *common.ServerConfig) (client.Stats, error) {
+	c, err := newClient(server)
+	if err != nil {
+		return client.Stats{}, err
+	}
+	return c.Stats(ctx)
+}
+
+// summarizeStats turns the raw stats into totals and top lists of at most top entries
+func summarizeStats(s client.Stats, top int) StatsSummary {
+	ret := StatsSummary{
+		TimeUnits:            s.TimeUnits,
+		DNSQueries:           s.NumDNSQueries,
+		Blocked:              s.NumBlockedFiltering,
+		ReplacedSafebrowsing: s.NumReplacedSafebrowsing,
+		ReplacedSafesearch:   s.NumReplacedSafesearch,
+		ReplacedParental:     s.NumReplacedParental,
+		AvgProcessingMs:      round2(s.AvgProcessingTime * 1000),
+		TopQueriedDomains:    topCounts(s.TopQueriedDomains, top),
+		TopBlockedDomains:    topCounts(s.TopBlockedDomains, top),
+		TopClients:           topCounts(s.TopClients, top),
+		TopUpstreams:         []TopUpstream{},
+	}
+
+	if s.NumDNSQueries > 0 {
+		ret.BlockedPercent = round2(float64(s.NumBlockedFiltering) * 100 / float64(s.NumDNSQueries))
+	}
+
+	avg := flattenTop(s.TopUpstreamsAvgTime)
+	for _, u := range topCounts(s.TopUpstreamsResponses, top) {
+		ret.TopUpstreams = append(ret.TopUpstreams, TopUpstream{Name: u.Name, Responses: u.Count, AvgMs: round2(avg[u.Name] * 1000)})
+	}
+
+	return ret
+}
+
+// topCounts flattens a top list, most first, keeping at most top entries (0 keeps all)
+func topCounts(list []map[string]uint64, top int) []TopCount {
+	ret := []TopCount{}
+	for name, count := range flattenTop(list) {
+		ret = append(ret, TopCount{Name: name, Count: count})
+	}
+
+	slices.SortFunc(ret, func(a, b TopCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if top > 0 && len(ret) > top {
+		ret = ret[:top]
+	}
+	return ret
+}
+
+func flattenTop[T uint64 | float64](list []map[string]T) map[string]T {
+	ret := make(map[string]T)
+	for _, entry := range list {
+		for name, v := range entry {
+			ret[name] += v
+		}
+	}
+	return ret
+}
+
+// mergeStats adds several servers' stats together. Averages are weighted by
+// query counts, and the per-hour or per-day series are lined up at the most recent end.
+// Hours can't be added to days, so if the servers differ the time units are
+// "mixed" and there are no series.
+func mergeStats(all []client.Stats) client.Stats {
+	var ret client.Stats
+
+	queried := make(map[string]uint64)
+	clients := make(map[string]uint64)
+	blocked := make(map[string]uint64)
+	responses := make(map[string]uint64)
+	upstreamTime := make(map[string]float64)
+	var processingTime float64
+
+	for _, s := range all {
+		if ret.TimeUnits == "" {
+			ret.TimeUnits = s.TimeUnits
+		} else if s.TimeUnits != ret.TimeUnits {
+			ret.TimeUnits = "mixed"
+		}
+
+		ret.NumDNSQueries += s.NumDNSQueries
+		ret.NumBlockedFiltering += s.NumBlockedFiltering
+		ret.NumReplacedSafebrowsing += s.NumReplacedSafebrowsing
+		ret.NumReplacedSafesearch += s.NumReplacedSafesearch
+		ret.NumReplacedParental += s.NumReplacedParental
+		processingTime += s.AvgProcessingTime * float64(s.NumDNSQueries)
+
+		addTop(queried, s.TopQueriedDomains)
+		addTop(clients, s.TopClients)
+		addTop(blocked, s.TopBlockedDomains)
+
+		counts := flattenTop(s.TopUpstreamsResponses)
+		addTop(responses, s.TopUpstreamsResponses)
+		for name, avg := range flattenTop(s.TopUpstreamsAvgTime) {
+			upstreamTime[name] += avg * float64(counts[name])
+		}
+
+		ret.DNSQueries = addSeries(ret.DNSQueries, s.DNSQueries)
+		ret.BlockedFiltering = addSeries(ret.BlockedFiltering, s.BlockedFiltering)
+		ret.ReplacedSafebrowsing = addSeries(ret.ReplacedSafebrowsing, s.ReplacedSafebrowsing)
+		ret.ReplacedParental = addSeries(ret.ReplacedParental, s.ReplacedParental)
+	}
+
+	if ret.TimeUnits == "mixed" {
+		ret.DNSQueries, ret.BlockedFiltering, ret.ReplacedSafebrowsing, ret.ReplacedParental = nil, nil, nil, nil
+	}
+
+	if ret.NumDNSQueries > 0 {
+		ret.AvgProcessingTime = processingTime / float64(ret.NumDNSQueries)
+	}
+
+	ret.TopQueriedDomains = unflattenTop(queried)
+	ret.TopClients = unflattenTop(clients)
+	ret.TopBlockedDomains = unflattenTop(blocked)
+	ret.TopUpstreamsResponses = unflattenTop(responses)
+	for _, name := range slices.Sorted(maps.Keys(upstreamTime)) {
+		if responses[name] > 0 {
+			ret.TopUpstreamsAvgTime = append(ret.TopUpstreamsAvgTime, map[string]float64{name: upstreamTime[name] / float64(responses[name])})
+		}
+	}
+
+	return ret
+}
+
+func addTop(into map[string]uint64, list []map[string]uint64) {
+	for name, count := range flattenTop(list) {
+		into[name] += count
+	}
+}
+
+func unflattenTop(m map[string]uint64) []map[string]uint64 {
+	ret := make([]map[string]uint64, 0, len(m))
+	for _, e := range topCounts([]map[string]uint64{m}, 0) {
+		ret = append(ret, map[string]uint64{e.Name: e.Count})
+	}
+	return ret
+}
+
+// addSeries adds b to a element by element, lined up at the end
+func addSeries(a, b []uint64) []uint64 {
+	if len(b) > len(a) {
+		a, b = b, a
+	}
+	ret := slices.Clone(a)
+	offset := len(a) - len(b)
+	for i, v := range b {
+		ret[offset+i] += v
+	}
+	return ret
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// StatsConfigOutput is what stats config get prints
+type StatsConfigOutput struct {
+	Enabled        bool     `json:"enabled"`
+	Interval       string   `json:"interval"`
+	IntervalMs     uint64   `json:"interval_ms"`
+	Ignored        []string `json:"ignored"`
+	IgnoredEnabled bool     `json:"ignored_enabled"`
+}
+
+func newStatsConfigOutput(c client.StatsConfig) StatsConfigOutput {
+	ignored := c.Ignored
+	if ignored == nil {
+		ignored = []string{}
+	}
+	return StatsConfigOutput{
+		Enabled:        c.Enabled,
+		Interval:       formatInterval(time.Duration(c.Interval) * time.Millisecond),
+		IntervalMs:     c.Interval,
+		Ignored:        ignored,
+		IgnoredEnabled: c.IgnoredEnabled,
+	}
+}
+
+func statsConfigGetCmdE(cmd *cobra.Command, args []string) error {
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (StatsConfigOutput, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return StatsConfigOutput{}, err
+		}
+		config, err := c.StatsConfig(ctx)
+		return newStatsConfigOutput(config), err
+	})
+}
+
+func statsConfigSetCmdE(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if !flags.Changed("enabled") && !flags.Changed("interval") && !flags.Changed("ignored") {
+		return fmt.Errorf("nothing to change: give --enabled, --interval or --ignored")
+	}
+
+	var interval time.Duration
+	if flags.Changed("interval") {
+		var err error
+		if interval, err = parseInterval(statsConfigInterval); err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive, use --enabled=false to stop collecting statistics")
+		}
+	}
+
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (StatsConfigOutput, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return StatsConfigOutput{}, err
+		}
+
+		// start from what's there so flags that weren't given stay as they are
+		config, err := c.StatsConfig(ctx)
+		if err != nil {
+			return StatsConfigOutput{}, err
+		}
+
+		if flags.Changed("enabled") {
+			config.Enabled = statsConfigEnabled
+		}
+		if flags.Changed("interval") {
+			config.Interval = uint64(interval.Milliseconds())
+		}
+		if flags.Changed("ignored") {
+			config.Ignored = slices.DeleteFunc(slices.Clone(statsConfigIgnored), func(s string) bool { return s == "" })
+			config.IgnoredEnabled = len(config.Ignored) > 0
+		}
+
+		if err := c.SetStatsConfig(ctx, config); err != nil {
+			return StatsConfigOutput{}, err
+		}
+
+		config, err = c.StatsConfig(ctx)
+		return newStatsConfigOutput(config), err
+	})
+}
+
+func statsResetCmdE(cmd *cobra.Command, args []string) error {
+	names, err := serverNames()
+	if err != nil {
+		return err
+	}
+	if err := confirm(statsYes, "Clear all statistics on "+names+"?"); err != nil {
+		return err
+	}
+
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (StatsSummary, error) {
+		c, err := newClient(server)
+		if err != nil {
+			return StatsSummary{}, err
+		}
+		if err := c.ResetStats(ctx); err != nil {
+			return StatsSummary{}, err
+		}
+
+		s, err := c.Stats(ctx)
+		return summarizeStats(s, statsTop), err
+	})
+}
+
+// parseInterval parses a Go duration, or whole days as in 7d
+func parseInterval(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("bad interval %q: %w", s, err)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad interval %q, want something like 24h or 7d", s)
+	}
+	return d, nil
+}
+
+// formatInterval shows whole days as 7d and anything else as a Go duration
+func formatInterval(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
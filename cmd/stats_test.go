@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+)
+
+func Test_mergeStats(t *testing.T) {
+	a := client.Stats{
+		TimeUnits:             "hours",
+		NumDNSQueries:         300,
+		NumBlockedFiltering:   30,
+		AvgProcessingTime:     0.010,
+		TopQueriedDomains:     []map[string]uint64{{"example.com": 50}, {"example.org": 20}},
+		TopUpstreamsResponses: []map[string]uint64{{"1.1.1.1:53": 100}},
+		TopUpstreamsAvgTime:   []map[string]float64{{"1.1.1.1:53": 0.020}},
+		DNSQueries:            []uint64{1, 2, 3},
+	}
+	b := client.Stats{
+		TimeUnits:             "hours",
+		NumDNSQueries:         100,
+		NumBlockedFiltering:   70,
+		AvgProcessingTime:     0.030,
+		TopQueriedDomains:     []map[string]uint64{{"example.org": 40}, {"example.net": 5}},
+		TopUpstreamsResponses: []map[string]uint64{{"1.1.1.1:53": 300}},
+		TopUpstreamsAvgTime:   []map[string]float64{{"1.1.1.1:53": 0.040}},
+		DNSQueries:            []uint64{10, 20},
+	}
+
+	got := summarizeStats(mergeStats([]client.Stats{a, b}), 2)
+
+	want := StatsSummary{
+		TimeUnits:         "hours",
+		DNSQueries:        400,
+		Blocked:           100,
+		BlockedPercent:    25,
+		AvgProcessingMs:   15,
+		TopQueriedDomains: []TopCount{{"example.org", 60}, {"example.com", 50}},
+		TopBlockedDomains: []TopCount{},
+		TopClients:        []TopCount{},
+		TopUpstreams:      []TopUpstream{{"1.1.1.1:53", 400, 35}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if series := mergeStats([]client.Stats{a, b}).DNSQueries; !reflect.DeepEqual(series, []uint64{1, 12, 23}) {
+		t.Errorf("series = %v, want [1 12 23]", series)
+	}
+
+	// hours and days don't add up
+	b.TimeUnits = "days"
+	if merged := mergeStats([]client.Stats{a, b}); merged.TimeUnits != "mixed" || merged.DNSQueries != nil || merged.NumDNSQueries != 400 {
+		t.Errorf("mixed units: got %q, %v, %d", merged.TimeUnits, merged.DNSQueries, merged.NumDNSQueries)
+	}
+}
+
+func Test_parseInterval(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"24h", 24 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"1.5d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseInterval(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseInterval(%q) = %v, %v", tt.in, got, err)
+		}
+		if !tt.err && strings.HasSuffix(tt.in, "d") && formatInterval(got) != tt.in {
+			t.Errorf("formatInterval(%v) = %q, want %q", got, formatInterval(got), tt.in)
+		}
+	}
+}
@@ -4,8 +4,7 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"time"
 
 	"github.com/ewosborne/adctl/common"
@@ -14,9 +13,10 @@ import (
 
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "Check and change adblocking status",
-	RunE:  StatusGetCmdE,
+	Use:         "status",
+	Short:       "Check and change adblocking status",
+	RunE:        StatusGetCmdE,
+	Annotations: map[string]string{timeoutAnnotation: "5s"},
 }
 
 // statusCmd represents the status command
@@ -44,64 +44,28 @@ type ReadableStatus struct {
 }
 
 func StatusGetCmdE(cmd *cobra.Command, args []string) error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return GetStatusAll(servers)
-	}
-
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-	s, err := GetStatus(server)
-	if err != nil {
-		return err
-	}
-	return PrintStatus(s)
+	return forServers(cmd.Context(), func(ctx context.Context, server *common.ServerConfig) (ReadableStatus, error) {
+		s, err := GetStatus(ctx, server)
+		return readable(s), err
+	})
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
 }
 
-func printToggle() error {
-	servers, err := GetCurrentServers()
-	if err != nil {
-		return err
-	}
-
-	if serverFlag == ReservedServerName && len(servers) > 1 {
-		// Multi-server mode
-		return toggleCommandAll(servers)
-	}
-
-	// Single server mode
-	var server *common.ServerConfig
-	if len(servers) > 0 {
-		server = &servers[0]
-	}
-
-	err = toggleCommand(server)
-	if err != nil {
-		return err
-	}
-
-	status, err := GetStatus(server)
-	if err != nil {
-		return err
-	}
-	PrintStatus(status)
-	return nil
+func printToggle(ctx context.Context) error {
+	return forServers(ctx, func(ctx context.Context, server *common.ServerConfig) (ReadableStatus, error) {
+		if err := toggleCommand(ctx, server); err != nil {
+			return ReadableStatus{}, err
+		}
+		s, err := GetStatus(ctx, server)
+		return readable(s), err
+	})
 }
 
-func toggleCommand(server *common.ServerConfig) error {
-	status, err := GetStatus(server)
+func toggleCommand(ctx context.Context, server *common.ServerConfig) error {
+	status, err := GetStatus(ctx, server)
 	if err != nil {
 		return err
 	}
@@ -109,52 +73,16 @@ func toggleCommand(server *common.ServerConfig) error {
 	dTime := DisableTime{HasTimeout: false}
 	switch status.Protection_enabled {
 	case true:
-		_, err = disableCommand(server, dTime)
+		_, err = disableCommand(ctx, server, dTime)
 	case false:
-		_, err = enableCommand(server)
+		_, err = enableCommand(ctx, server)
 	}
 
 	return err
 }
 
-func toggleCommandAll(servers []common.ServerConfig) error {
-	type ServerResult struct {
-		Server string `json:"server"`
-		Status Status `json:"status,omitempty"`
-		Error  string `json:"error,omitempty"`
-	}
-
-	var results []ServerResult
-	for _, server := range servers {
-		result := ServerResult{Server: server.Name}
-		err := toggleCommand(&server)
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			status, err := GetStatus(&server)
-			if err != nil {
-				result.Error = err.Error()
-			} else {
-				result.Status = status
-			}
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(output))
-	return nil
-}
-
-func PrintStatus(status Status) error {
-	// status, err := GetStatus()
-	// if err != nil {
-	// 	return fmt.Errorf("error getting status: %w", err)
-	// }
-
+// readable turns the disabled duration into something like "4m30s"
+func readable(status Status) ReadableStatus {
 	var readableStatus ReadableStatus
 	readableStatus.Protection_enabled = status.Protection_enabled
 
@@ -163,70 +91,25 @@ func PrintStatus(status Status) error {
 			status.Protection_disabled_duration * uint64(time.Millisecond)).Truncate(time.Second).String()
 	}
 
-	tmp, err := json.MarshalIndent(readableStatus, "", " ")
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(string(tmp))
-	return nil
+	return readableStatus
 }
 
 // GetStatus gets status for a specific server (nil means legacy/viper config)
-func GetStatus(server *common.ServerConfig) (Status, error) {
+func GetStatus(ctx context.Context, server *common.ServerConfig) (Status, error) {
 	var ret Status
 
-	// build the command, it's specific to status
-	baseURL, err := common.GetBaseURL(server)
+	c, err := newClient(server)
 	if err != nil {
 		return ret, err
 	}
-	baseURL.Path = "/control/status"
 
-	statusQuery := common.CommandArgs{
-		Method: "GET",
-		URL:    baseURL,
-		Server: server,
-	}
-
-	body, err := common.SendCommand(statusQuery)
+	s, err := c.Status(ctx)
 	if err != nil {
 		return ret, err
 	}
 
-	// serialize body into Status and return appropriately
-	var s Status
-	json.Unmarshal(body, &s)
-
-	return s, nil
-}
+	ret.Protection_enabled = s.ProtectionEnabled
+	ret.Protection_disabled_duration = s.ProtectionDisabledDuration
 
-// GetStatusAll gets status for all servers
-func GetStatusAll(servers []common.ServerConfig) error {
-	type ServerStatus struct {
-		Server string `json:"server"`
-		Status Status `json:"status"`
-		Error  string `json:"error,omitempty"`
-	}
-
-	var results []ServerStatus
-	for _, server := range servers {
-		status, err := GetStatus(&server)
-		result := ServerStatus{
-			Server: server.Name,
-		}
-		if err != nil {
-			result.Error = err.Error()
-		} else {
-			result.Status = status
-		}
-		results = append(results, result)
-	}
-
-	output, err := json.MarshalIndent(results, "", " ")
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(output))
-	return nil
+	return ret, nil
 }
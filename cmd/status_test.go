@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"testing"
 )
@@ -15,17 +16,17 @@ func TestToggle(t *testing.T) {
 	// toggle to other, make sure it sticks
 	// toggle back
 
-	initialState, err := GetStatus(nil)
+	initialState, err := GetStatus(context.Background(), nil)
 	if err != nil {
 		t.Errorf("error getting initial status: %v", err)
 	}
 
-	err = toggleCommand(nil)
+	err = toggleCommand(context.Background(), nil)
 	if err != nil {
 		t.Errorf("error toggling command: %v", err)
 	}
 
-	secondState, err := GetStatus(nil)
+	secondState, err := GetStatus(context.Background(), nil)
 	if err != nil {
 		t.Errorf("error getting second status: %v", err)
 	}
@@ -35,11 +36,11 @@ func TestToggle(t *testing.T) {
 		t.Errorf("first toggle: protection states do not match")
 	}
 
-	err = toggleCommand(nil)
+	err = toggleCommand(context.Background(), nil)
 	if err != nil {
 		t.Errorf("error toggling command: %v", err)
 	}
-	thirdState, err := GetStatus(nil)
+	thirdState, err := GetStatus(context.Background(), nil)
 	if err != nil {
 		t.Errorf("error getting third status: %v", err)
 	}
@@ -58,7 +59,7 @@ func TestEnable(t *testing.T) {
 
 	// test cmd.enableCommand()
 
-	Status, err := enableCommand(nil)
+	Status, err := enableCommand(context.Background(), nil)
 	want := true
 
 	if err != nil {
@@ -80,7 +81,7 @@ func TestDisable_Permanent(t *testing.T) {
 
 	dTime := DisableTime{HasTimeout: false}
 
-	Status, err := disableCommand(nil, dTime)
+	Status, err := disableCommand(context.Background(), nil, dTime)
 	want := false
 
 	if err != nil {
@@ -102,7 +103,7 @@ func TestDisable_Temporary(t *testing.T) {
 
 	dTime := DisableTime{HasTimeout: true, Duration: "30s"}
 
-	Status, err := disableCommand(nil, dTime)
+	Status, err := disableCommand(context.Background(), nil, dTime)
 	want := false
 
 	if err != nil {
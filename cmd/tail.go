@@ -0,0 +1,298 @@
+/*
+Copyright © 2025 Eric Osborne
+No header.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+	"github.com/spf13/cobra"
+)
+
+var logTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Show the latest queries, and with -f keep showing new ones",
+	Long: `Show the latest queries, oldest first. With -f adctl keeps polling and prints
+queries as they arrive. With several servers their queries are merged in time
+order and each line says which server answered.
+
+The filters are the same as for log get, and apply to each new query.
+
+Lines are plain text. -o json or -o ndjson gives one JSON object per query
+instead, with a "server" field, and --query and --template apply to each query.`,
+	Args: cobra.NoArgs,
+	RunE: logTailCmdE,
+}
+
+var tailFollow bool
+var tailLines int
+var tailInterval time.Duration
+
+// tailPageSize and tailMaxPages bound how far back one poll looks for new queries
+const tailPageSize = 500
+const tailMaxPages = 20
+
+func init() {
+	logCmd.AddCommand(logTailCmd)
+
+	logTailCmd.Flags().BoolVarP(&tailFollow, "follow", "f", false, "Keep polling for new queries")
+	logTailCmd.Flags().IntVarP(&tailLines, "lines", "n", 10, "How many recent queries to show first, per server")
+	logTailCmd.Flags().DurationVar(&tailInterval, "interval", 2*time.Second, "How often to poll with -f")
+	logTailCmd.Flags().StringVar(&filter, "filter", "all", fmt.Sprintf("one of: %#v", allowedFilters))
+	logTailCmd.Flags().StringVar(&searchQuery, "search", "", "string to search for in logs.")
+	addLogFilterFlags(logTailCmd)
+}
+
+// TailEntry is a query log entry tagged with the server it came from
+type TailEntry struct {
+	Server string `json:"server"`
+	client.LogEntry
+	at time.Time
+}
+
+// tailer remembers what has been printed for one server, so each poll only
+// returns queries that haven't been seen yet
+type tailer struct {
+	server *common.ServerConfig
+	// newest is the time of the newest query seen so far
+	newest time.Time
+	// seen holds the queries at or after newest's time, which the next poll may return again
+	seen map[string]bool
+	// started is set once the first poll has set newest
+	started bool
+}
+
+func tailKey(e client.LogEntry) string {
+	return strings.Join([]string{e.Time, e.Client, e.ClientProto, e.Question.Name, e.Question.Type}, "|")
+}
+
+// poll fetches queries newer than the last poll that pass args.match, newest
+// first. The first poll returns the latest lines of them, looking back at most
+// one page. Later ones page back with older_than until they reach queries
+// already seen.
+func (t *tailer) poll(ctx context.Context, args LogArgs, lines int) ([]TailEntry, error) {
+	c, err := newClient(t.server)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := tailPageSize
+	if !t.started && !args.match.active() {
+		limit = max(lines, 1)
+	}
+
+	var fresh []TailEntry
+	olderThan := ""
+	for range tailMaxPages {
+		ql, err := c.QueryLog(ctx, client.QueryLogParams{
+			Limit:          strconv.Itoa(limit),
+			OlderThan:      olderThan,
+			ResponseStatus: args.filter,
+			Search:         args.search,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		caughtUp := false
+		for _, e := range ql.Data {
+			at, err := e.At()
+			if err != nil {
+				continue
+			}
+			if t.started && at.Before(t.newest) {
+				caughtUp = true
+				break
+			}
+			if t.seen[tailKey(e)] {
+				continue
+			}
+			fresh = append(fresh, TailEntry{LogEntry: e, at: at})
+		}
+
+		if !t.started || caughtUp || len(ql.Data) < limit || ql.Oldest == "" {
+			break
+		}
+		olderThan = ql.Oldest
+	}
+
+	for _, e := range fresh {
+		t.remember(e)
+	}
+
+	fresh = slices.DeleteFunc(fresh, func(e TailEntry) bool { return !args.match.match(e.LogEntry) })
+	if !t.started {
+		fresh = fresh[:min(lines, len(fresh))]
+	}
+	t.started = true
+
+	return fresh, nil
+}
+
+// remember marks e as printed, moving newest forward and forgetting anything older
+func (t *tailer) remember(e TailEntry) {
+	if e.at.After(t.newest) {
+		t.newest = e.at
+		t.seen = make(map[string]bool)
+	}
+	if !e.at.Before(t.newest) {
+		t.seen[tailKey(e.LogEntry)] = true
+	}
+}
+
+func logTailCmdE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	if !slices.Contains(allowedFilters, filter) {
+		return fmt.Errorf("filter value %s not allowed", filter)
+	}
+	if tailLines < 0 {
+		return fmt.Errorf("--lines can't be negative")
+	}
+	if tailInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	servers, err := GetCurrentServers()
+	if err != nil {
+		return err
+	}
+
+	var tailers []*tailer
+	if len(servers) == 0 {
+		// legacy single-server config
+		tailers = append(tailers, &tailer{seen: make(map[string]bool)})
+	}
+	for i := range servers {
+		tailers = append(tailers, &tailer{server: &servers[i], seen: make(map[string]bool)})
+	}
+
+	// only tag lines with the server when there's more than one
+	multi := isMultiServer(servers)
+	serverWidth := 0
+	if multi {
+		for _, s := range servers {
+			serverWidth = max(serverWidth, len(s.Name))
+		}
+	}
+	jsonOut := cmd.Flags().Changed("output") && (outputFlag == "json" || outputFlag == "ndjson")
+	match, err := newLogMatcher(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+	queryLogs := LogArgs{filter: filter, search: searchQuery, match: match}
+
+	for {
+		fanned := pollTailers(ctx, tailers, queryLogs)
+
+		var batch []TailEntry
+		for _, r := range fanned {
+			if r.Err != nil {
+				if ctx.Err() != nil {
+					return stopTail(ctx)
+				}
+				// one server's error as it is, so the exit code matches log get
+				if !multi && !tailFollow {
+					return r.Err
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.Server, r.Err)
+				continue
+			}
+			batch = append(batch, r.Value...)
+		}
+
+		// merge the servers in time order, oldest first
+		slices.SortStableFunc(batch, func(a, b TailEntry) int { return a.at.Compare(b.at) })
+
+		for _, e := range batch {
+			if err := printTailEntry(e, serverWidth, jsonOut); err != nil {
+				return err
+			}
+		}
+
+		if !tailFollow {
+			return common.FanOutErr(fanned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return stopTail(ctx)
+		case <-time.After(tailInterval):
+		}
+	}
+}
+
+// stopTail is how -f ends: Ctrl-C is the normal way out, not an error
+func stopTail(ctx context.Context) error {
+	if tailFollow {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// pollTailers polls every server at once, tagging each query with its server
+func pollTailers(ctx context.Context, tailers []*tailer, args LogArgs) []common.FanOutResult[[]TailEntry] {
+	poll := func(ctx context.Context, t *tailer) ([]TailEntry, error) {
+		entries, err := t.poll(ctx, args, tailLines)
+		if t.server != nil {
+			for i := range entries {
+				entries[i].Server = t.server.Name
+			}
+		}
+		return entries, err
+	}
+
+	if len(tailers) == 1 {
+		ret := common.FanOutResult[[]TailEntry]{}
+		if tailers[0].server != nil {
+			ret.Server = tailers[0].server.Name
+		}
+		start := time.Now()
+		ret.Value, ret.Err = poll(ctx, tailers[0])
+		ret.Duration = time.Since(start)
+		return []common.FanOutResult[[]TailEntry]{ret}
+	}
+
+	servers := make([]common.ServerConfig, len(tailers))
+	byName := make(map[string]*tailer, len(tailers))
+	for i, t := range tailers {
+		servers[i] = *t.server
+		byName[t.server.Name] = t
+	}
+
+	return fanOut(ctx, servers, func(ctx context.Context, server *common.ServerConfig) ([]TailEntry, error) {
+		return poll(ctx, byName[server.Name])
+	})
+}
+
+// printTailEntry prints one query as a line of text, or as JSON
+func printTailEntry(e TailEntry, serverWidth int, jsonOut bool) error {
+	if jsonOut || outputQuery != nil || outputTemplate != nil {
+		return renderWith(os.Stdout, "ndjson", outputQuery, outputTemplate, e)
+	}
+
+	var line strings.Builder
+	line.WriteString(e.at.Local().Format("15:04:05.000"))
+	if serverWidth > 0 {
+		fmt.Fprintf(&line, "  %-*s", serverWidth, e.Server)
+	}
+	fmt.Fprintf(&line, "  %-15s  %-5s  %s  %s", e.Client, e.Question.Type, e.Question.Name, e.Reason)
+	if e.ElapsedMs != "" {
+		fmt.Fprintf(&line, "  %sms", e.ElapsedMs)
+	}
+	if e.Cached {
+		line.WriteString("  cached")
+	}
+
+	_, err := fmt.Fprintln(os.Stdout, line.String())
+	return err
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ewosborne/adctl/client"
+	"github.com/ewosborne/adctl/common"
+)
+
+func Test_tailerPoll(t *testing.T) {
+	var mu sync.Mutex
+	var log []client.LogEntry // newest first, as AdGuard Home keeps it
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	add := func(name string, second int) {
+		mu.Lock()
+		defer mu.Unlock()
+		e := client.LogEntry{Time: base.Add(time.Duration(second) * time.Second).Format(time.RFC3339Nano), Client: "10.0.0.1"}
+		e.Question.Name = name
+		log = append([]client.LogEntry{e}, log...)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		older := r.URL.Query().Get("older_than")
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var data []client.LogEntry
+		for _, e := range log {
+			if older == "" || e.Time < older {
+				data = append(data, e)
+			}
+		}
+		data = data[:min(limit, len(data))]
+		ql := client.QueryLog{Data: data}
+		if len(data) > 0 {
+			ql.Oldest = data[len(data)-1].Time
+		}
+		json.NewEncoder(w).Encode(ql)
+	}))
+	defer srv.Close()
+
+	names := func(entries []TailEntry) []string {
+		var ret []string
+		for _, e := range entries {
+			ret = append(ret, e.Question.Name)
+		}
+		return ret
+	}
+
+	tl := &tailer{server: &common.ServerConfig{Name: "test", Host: srv.URL, Username: "admin", Password: "pw"}, seen: make(map[string]bool)}
+	ctx := context.Background()
+	args := LogArgs{filter: "all"}
+
+	add("a", 1)
+	add("b", 2)
+	add("c", 3)
+	got, err := tl.poll(ctx, args, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c", "b"}; !reflect.DeepEqual(names(got), want) {
+		t.Errorf("first poll = %v, want %v", names(got), want)
+	}
+
+	// nothing new
+	if got, _ = tl.poll(ctx, args, 2); len(got) != 0 {
+		t.Errorf("idle poll = %v, want nothing", names(got))
+	}
+
+	// a query in the same instant as the newest seen one is still new
+	add("d", 3)
+	add("e", 4)
+	if got, _ = tl.poll(ctx, args, 2); !reflect.DeepEqual(names(got), []string{"e", "d"}) {
+		t.Errorf("third poll = %v, want [e d]", names(got))
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// timeoutAnnotation is the cobra annotation a command uses to set its default
+// per-request timeout, in time.ParseDuration format. Subcommands inherit it.
+const timeoutAnnotation = "adctl/timeout"
+
+// defaultTimeout applies when neither --timeout nor an annotation says otherwise
+const defaultTimeout = 30 * time.Second
+
+// requestTimeout is the per-request timeout for this run, set in PersistentPreRun
+var requestTimeout = defaultTimeout
+
+// commandTimeout works out the per-request timeout for cmd.
+// --timeout wins, then the nearest timeoutAnnotation, then defaultTimeout.
+func commandTimeout(cmd *cobra.Command) (time.Duration, error) {
+	if cmd.Flags().Changed("timeout") {
+		return timeoutFlag, nil
+	}
+
+	for c := cmd; c != nil; c = c.Parent() {
+		if v, ok := c.Annotations[timeoutAnnotation]; ok {
+			return time.ParseDuration(v)
+		}
+	}
+
+	return defaultTimeout, nil
+}
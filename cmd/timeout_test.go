@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func Test_commandTimeout(t *testing.T) {
+	parent := &cobra.Command{Use: "parent", Annotations: map[string]string{timeoutAnnotation: "5m"}}
+	child := &cobra.Command{Use: "child"}
+	other := &cobra.Command{Use: "other", Annotations: map[string]string{timeoutAnnotation: "5s"}}
+	bare := &cobra.Command{Use: "bare"}
+	parent.AddCommand(child, other)
+
+	var tt = []struct {
+		cmd      *cobra.Command
+		expected time.Duration
+	}{
+		{cmd: child, expected: 5 * time.Minute},
+		{cmd: other, expected: 5 * time.Second},
+		{cmd: bare, expected: defaultTimeout},
+	}
+
+	for _, entry := range tt {
+		got, err := commandTimeout(entry.cmd)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", entry.cmd.Name(), err)
+		}
+		if got != entry.expected {
+			t.Errorf("%s: expected %v, got %v", entry.cmd.Name(), entry.expected, got)
+		}
+	}
+
+	// an explicit --timeout beats any annotation
+	other.Flags().DurationVar(&timeoutFlag, "timeout", 0, "")
+	other.Flags().Set("timeout", "42s")
+	got, err := commandTimeout(other)
+	if err != nil || got != 42*time.Second {
+		t.Errorf("explicit --timeout: expected 42s, got %v (%v)", got, err)
+	}
+}
@@ -8,13 +8,14 @@ import (
 )
 
 var statusToggleCmd = &cobra.Command{
-	Use:   "toggle",
-	Short: "Toggle adblocker between enabled and disabled.",
-	RunE:  ToggleCmdE,
+	Use:         "toggle",
+	Short:       "Toggle adblocker between enabled and disabled.",
+	RunE:        ToggleCmdE,
+	Annotations: map[string]string{timeoutAnnotation: "5s"},
 }
 
 func ToggleCmdE(cmd *cobra.Command, args []string) error {
-	return printToggle()
+	return printToggle(cmd.Context())
 }
 
 func init() {
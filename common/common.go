@@ -2,26 +2,51 @@ package common
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"time"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
 // ServerConfig represents a single AdGuard server configuration
 type ServerConfig struct {
-	Name     string `mapstructure:"name" yaml:"name"`
+	Name string `mapstructure:"name" yaml:"name"`
+	// Host is host:port, or a full URL such as https://router.example.com/adguard
 	Host     string `mapstructure:"host" yaml:"host"`
 	Username string `mapstructure:"username" yaml:"username"`
-	Password string `mapstructure:"password" yaml:"password"`
+	Password string `mapstructure:"password" yaml:"password,omitempty"`
+
+	// PasswordEnv, PasswordCommand and PasswordSecret are alternatives to
+	// keeping Password in the config file. See credentials.go.
+	PasswordEnv     string `mapstructure:"password_env" yaml:"password_env,omitempty"`
+	PasswordCommand string `mapstructure:"password_command" yaml:"password_command,omitempty"`
+	PasswordSecret  string `mapstructure:"password_secret" yaml:"password_secret,omitempty"`
+
+	// Scheme is http or https. Ignored if Host is a full URL. Defaults to http.
+	Scheme string `mapstructure:"scheme" yaml:"scheme,omitempty"`
+	// CACert is a path to a PEM bundle of extra CAs to trust for this server
+	CACert string `mapstructure:"ca_cert" yaml:"ca_cert,omitempty"`
+	// ClientCert and ClientKey are paths to a PEM certificate and key for mTLS
+	ClientCert         string `mapstructure:"client_cert" yaml:"client_cert,omitempty"`
+	ClientKey          string `mapstructure:"client_key" yaml:"client_key,omitempty"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify,omitempty"`
+
+	// Auth is "basic" (the default) to send the password on every request, or
+	// "session" to log in once and reuse the agh_session cookie
+	Auth string `mapstructure:"auth" yaml:"auth,omitempty"`
+
+	// Tags and Groups let --server pick servers by site=home or by group name
+	Tags   map[string]string `mapstructure:"tags" yaml:"tags,omitempty"`
+	Groups []string          `mapstructure:"groups" yaml:"groups,omitempty"`
 }
 
 type CommandArgs struct {
-	RequestBody map[string]any
+	RequestBody any
 	Method      string
 	URL         url.URL
 	Server      *ServerConfig // Optional server config, nil means use legacy viper config
@@ -32,15 +57,41 @@ type CommandArgs struct {
 func GetBaseURL(server *ServerConfig) (url.URL, error) {
 	var ret = url.URL{Scheme: "http"}
 
-	var host string
+	var host, scheme string
 	var err error
 	if server != nil {
 		host = server.Host
+		scheme = server.Scheme
 	} else {
 		host, err = getHost()
 		if err != nil {
 			return ret, err
 		}
+		scheme = viper.GetString("scheme")
+	}
+
+	if host == "" {
+		return ret, fmt.Errorf("host is empty")
+	}
+
+	// host can be a full URL, in which case it wins over the scheme setting
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return ret, fmt.Errorf("can't parse host %q: %w", host, err)
+		}
+		scheme = u.Scheme
+		host = u.Host
+		ret.Path = strings.TrimSuffix(u.Path, "/")
+	}
+
+	switch strings.ToLower(scheme) {
+	case "", "http":
+		ret.Scheme = "http"
+	case "https":
+		ret.Scheme = "https"
+	default:
+		return ret, fmt.Errorf("unsupported scheme %q, must be http or https", scheme)
 	}
 
 	if host == "" {
@@ -64,55 +115,13 @@ func getHost() (string, error) {
 	return ret, nil
 }
 
-// AbleCommand enables or disables protection on a server
-func AbleCommand(server *ServerConfig, state bool, durationString string) error {
-	// base url
-	baseURL, err := GetBaseURL(server)
-	if err != nil {
-		return err
-	}
-
-	baseURL.Path = "/control/protection"
-
-	// data for post
-	var requestBody = make(map[string]any)
-	requestBody["enabled"] = state
-
-	var duration uint64
-	if len(durationString) > 0 {
-		tmp, err := time.ParseDuration(durationString)
-		if err != nil {
-			return fmt.Errorf("time.ParseDuration: %w", err)
-		}
-		duration = uint64(tmp.Milliseconds())
-	}
-
-	requestBody["duration"] = duration
-
-	// put it all together
-	enableQuery := CommandArgs{
-		Method:      "POST",
-		URL:         baseURL,
-		RequestBody: requestBody,
-		Server:      server,
-	}
-
-	// don't care about body here
-	_, err = SendCommand(enableQuery)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// AbleCommandLegacy maintains backward compatibility
-func AbleCommandLegacy(state bool, durationString string) error {
-	return AbleCommand(nil, state, durationString)
-}
-
 // SendCommand sends a command to a server
 func SendCommand(ca CommandArgs) ([]byte, error) {
+	return SendCommandContext(context.Background(), ca)
+}
+
+// SendCommandContext sends a command to a server, aborting if ctx is cancelled
+func SendCommandContext(ctx context.Context, ca CommandArgs) ([]byte, error) {
 	var jsonData []byte
 	var err error
 
@@ -124,48 +133,60 @@ func SendCommand(ca CommandArgs) ([]byte, error) {
 		}
 	}
 
-	// create the final request
-	request, err := http.NewRequest(ca.Method, ca.URL.String(), bytes.NewBuffer(jsonData))
+	// connect.  timeouts come from ctx so short status checks and long log fetches
+	//   can each have their own.
+	transport, err := getTransport(tlsSettingsFor(ca.Server))
 	if err != nil {
 		return nil, err
 	}
+	client := &http.Client{Transport: transport}
 
-	// set request headers
-	request.Header.Set("Content-Type", "application/json")
+	mode, err := authMode(ca.Server)
+	if err != nil {
+		return nil, err
+	}
 
-	// set basic auth - use server config if provided, otherwise use legacy viper
-	var username, password string
-	if ca.Server != nil {
-		username = ca.Server.Username
-		password = ca.Server.Password
-	} else {
-		username = viper.GetString("username")
-		if username == "" {
-			return nil, fmt.Errorf("can't find username (set ADCTL_USERNAME environment variable or configure in config file)")
-		}
-		password = viper.GetString("password")
-		if password == "" {
-			return nil, fmt.Errorf("can't find password (set ADCTL_PASSWORD environment variable or configure in config file)")
-		}
+	// the password is only looked up when it's needed, so a cached session
+	//   never runs password_command or asks for the secrets passphrase
+	creds := func() (string, string, error) {
+		promptCtx, stop := promptContext()
+		defer stop()
+		return credentials(promptCtx, ca.Server)
 	}
 
-	if username == "" || password == "" {
-		return nil, fmt.Errorf("username and password are required")
+	if mode == AuthSession {
+		return sendWithSession(ctx, client, ca, jsonData, creds)
+	}
+
+	username, password, err := creds()
+	if err != nil {
+		return nil, err
+	}
+
+	return send(ctx, client, ca, jsonData, func(request *http.Request) {
+		request.SetBasicAuth(username, password)
+	})
+}
+
+// send makes one HTTP request, using auth to attach credentials
+func send(ctx context.Context, client *http.Client, ca CommandArgs, jsonData []byte, auth func(*http.Request)) ([]byte, error) {
+	// create the final request
+	request, err := http.NewRequestWithContext(ctx, ca.Method, ca.URL.String(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
 	}
 
-	request.SetBasicAuth(username, password)
+	// set request headers
+	request.Header.Set("Content-Type", "application/json")
+	auth(request)
 
-	// connect.  Old implementation let me set timeouts to handle short dns timeouts and
-	//   long log fetches.  bother with it here? skipping for now.
-	client := &http.Client{}
 	resp, err := client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("error Do'ing request: %w", err)
+		return nil, classifyTransportError(ca.URL.Host, err)
 	}
 	defer resp.Body.Close()
 
-	// read response
-	// Read response but really I just want to know if there's an error
+	// read response.  on an error the body is the server's explanation, so keep it.
 	body, err := io.ReadAll(resp.Body)
 
 	if err != nil {
@@ -173,39 +194,8 @@ func SendCommand(ca CommandArgs) ([]byte, error) {
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("status code not 200: %v", resp.Status)
+		return nil, newHTTPError(request, resp, body)
 	}
 
 	return body, nil
 }
-
-// SendCommandToAll sends a command to all servers and collects results
-// Returns a map of server name to result (or error)
-func SendCommandToAll(servers []ServerConfig, ca CommandArgs) map[string]interface{} {
-	results := make(map[string]interface{})
-
-	for _, server := range servers {
-		// Create a copy of CommandArgs with this server's config
-		serverCA := ca
-		serverCA.Server = &server
-
-		// Get base URL for this server
-		baseURL, err := GetBaseURL(&server)
-		if err != nil {
-			results[server.Name] = fmt.Errorf("failed to get base URL: %w", err)
-			continue
-		}
-		serverCA.URL = baseURL
-		serverCA.URL.Path = ca.URL.Path // Preserve the path
-
-		// Send command
-		body, err := SendCommand(serverCA)
-		if err != nil {
-			results[server.Name] = err
-		} else {
-			results[server.Name] = body
-		}
-	}
-
-	return results
-}
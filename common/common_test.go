@@ -0,0 +1,35 @@
+package common
+
+import "testing"
+
+func TestGetBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  ServerConfig
+		want    string
+		wantErr bool
+	}{
+		{"host and port", ServerConfig{Host: "192.168.1.2:3000"}, "http://192.168.1.2:3000", false},
+		{"url with prefix", ServerConfig{Host: "https://adguard.lan/adguard"}, "https://adguard.lan/adguard", false},
+		{"trailing slash", ServerConfig{Host: "https://adguard.lan/adguard/"}, "https://adguard.lan/adguard", false},
+		{"bare trailing slash", ServerConfig{Host: "http://adguard.lan/"}, "http://adguard.lan", false},
+		{"scheme setting", ServerConfig{Host: "adguard.lan", Scheme: "HTTPS"}, "https://adguard.lan", false},
+		{"url beats scheme setting", ServerConfig{Host: "http://adguard.lan", Scheme: "https"}, "http://adguard.lan", false},
+		{"bad scheme", ServerConfig{Host: "adguard.lan", Scheme: "ftp"}, "", true},
+		{"bad url scheme", ServerConfig{Host: "ftp://adguard.lan"}, "", true},
+		{"url without host", ServerConfig{Host: "https:///adguard"}, "", true},
+		{"empty", ServerConfig{}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetBaseURL(&tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetBaseURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("GetBaseURL() = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/viper"
+)
+
+// resolved caches passwords we've already looked up, keyed by where they came
+// from, so a password_command runs once per process rather than once per request
+var (
+	resolvedMu sync.Mutex
+	resolved   = make(map[string]string)
+)
+
+// credentials returns the username and password for a server, fetching the
+// password from wherever the config says it lives. Only called when we're about
+// to talk to that server, so other servers' secrets are never touched.
+// If server is nil, uses legacy viper config
+func credentials(ctx context.Context, server *ServerConfig) (string, string, error) {
+	if server == nil {
+		username := viper.GetString("username")
+		if username == "" {
+			return "", "", fmt.Errorf("can't find username (set ADCTL_USERNAME environment variable or configure in config file)")
+		}
+
+		legacy := ServerConfig{
+			Username:        username,
+			Password:        viper.GetString("password"),
+			PasswordEnv:     viper.GetString("password_env"),
+			PasswordCommand: viper.GetString("password_command"),
+			PasswordSecret:  viper.GetString("password_secret"),
+		}
+		if !HasPassword(legacy) {
+			return "", "", fmt.Errorf("can't find password (set ADCTL_PASSWORD environment variable or configure in config file)")
+		}
+		server = &legacy
+	}
+
+	if server.Username == "" || !HasPassword(*server) {
+		return "", "", fmt.Errorf("username and password are required")
+	}
+
+	password, err := resolvePassword(ctx, server)
+	if err != nil {
+		return "", "", fmt.Errorf("can't get password for %s: %w", server.Username, err)
+	}
+
+	return server.Username, password, nil
+}
+
+// HasPassword says whether a server has some way of getting a password
+func HasPassword(server ServerConfig) bool {
+	return server.Password != "" || server.PasswordEnv != "" ||
+		server.PasswordCommand != "" || server.PasswordSecret != ""
+}
+
+// resolvePassword looks up the password. Password wins, then password_env,
+// then password_command, then password_secret.
+func resolvePassword(ctx context.Context, server *ServerConfig) (string, error) {
+	switch {
+	case server.Password != "":
+		return server.Password, nil
+
+	case server.PasswordEnv != "":
+		password := os.Getenv(server.PasswordEnv)
+		if password == "" {
+			return "", fmt.Errorf("environment variable %s is empty", server.PasswordEnv)
+		}
+		return password, nil
+
+	case server.PasswordCommand != "":
+		return cachedPassword("command:"+server.PasswordCommand, func() (string, error) {
+			return runPasswordCommand(ctx, server.PasswordCommand)
+		})
+
+	case server.PasswordSecret != "":
+		return cachedPassword("secret:"+server.PasswordSecret, func() (string, error) {
+			secrets, err := OpenSecrets()
+			if err != nil {
+				return "", err
+			}
+			password, ok := secrets[server.PasswordSecret]
+			if !ok {
+				return "", fmt.Errorf("no secret named %q in %s", server.PasswordSecret, SecretsFile)
+			}
+			return password, nil
+		})
+	}
+
+	return "", fmt.Errorf("no password configured")
+}
+
+// cachedPassword returns the cached password for key, calling fetch the first time.
+// The lock is held while fetching so two servers never prompt at once.
+func cachedPassword(key string, fetch func() (string, error)) (string, error) {
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+
+	if password, ok := resolved[key]; ok {
+		return password, nil
+	}
+
+	password, err := fetch()
+	if err != nil {
+		return "", err
+	}
+
+	resolved[key] = password
+	return password, nil
+}
+
+// promptContext is for looking up credentials, which can mean waiting for the
+// user at a gpg or pinentry prompt. It has no deadline, so a short request
+// timeout can't kill the prompt, and only Ctrl-C cancels it.
+func promptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// runPasswordCommand runs command through the shell and returns the first
+// line it prints, the way `pass show` and friends expect to be used.
+// stderr and the terminal are passed through so gpg and the like can prompt.
+// stdin isn't, since it may be input for adctl, as in rewrite import -.
+func runPasswordCommand(ctx context.Context, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			defer tty.Close()
+			cmd.Stdin = tty
+		}
+	}
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password_command %q failed: %w", command, err)
+	}
+
+	password, _, _ := strings.Cut(string(out), "\n")
+	password = strings.TrimRight(password, "\r")
+	if password == "" {
+		return "", fmt.Errorf("password_command %q printed nothing", command)
+	}
+
+	return password, nil
+}
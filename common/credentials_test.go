@@ -0,0 +1,89 @@
+package common
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolvePassword(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("password_command tests use sh")
+	}
+
+	t.Setenv("ADCTL_TEST_PW", "from-env")
+	counter := filepath.Join(t.TempDir(), "count")
+
+	tests := []struct {
+		name    string
+		server  ServerConfig
+		want    string
+		wantErr bool
+	}{
+		{"plain", ServerConfig{Password: "plain", PasswordEnv: "ADCTL_TEST_PW"}, "plain", false},
+		{"env", ServerConfig{PasswordEnv: "ADCTL_TEST_PW"}, "from-env", false},
+		{"env unset", ServerConfig{PasswordEnv: "ADCTL_TEST_UNSET"}, "", true},
+		{"command first line", ServerConfig{PasswordCommand: "printf 'from-cmd\\r\\nlogin: admin\\n'"}, "from-cmd", false},
+		{"command fails", ServerConfig{PasswordCommand: "exit 3"}, "", true},
+		{"command silent", ServerConfig{PasswordCommand: "true"}, "", true},
+		{"command cached", ServerConfig{PasswordCommand: "echo x >> " + counter + "; wc -l < " + counter + " | tr -d ' '"}, "1", false},
+		{"command cached again", ServerConfig{PasswordCommand: "echo x >> " + counter + "; wc -l < " + counter + " | tr -d ' '"}, "1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePassword(context.Background(), &tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolvePassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolvePassword() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretsRoundTrip(t *testing.T) {
+	SecretsFile = filepath.Join(t.TempDir(), "secrets.enc")
+	t.Setenv("ADCTL_SECRETS_PASSPHRASE", "correct horse")
+
+	reset := func() {
+		secretsUnlocked = nil
+		secretsPassphrase = ""
+	}
+	reset()
+	defer func() {
+		reset()
+		SecretsFile = ""
+	}()
+
+	secrets, err := OpenSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 0 {
+		t.Fatalf("new secrets file has %d entries", len(secrets))
+	}
+
+	secrets["router"] = "hunter2"
+	if err := SaveSecrets(secrets); err != nil {
+		t.Fatal(err)
+	}
+
+	// forget the unlocked copy so the next open really decrypts the file
+	reset()
+	secrets, err = OpenSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secrets["router"] != "hunter2" {
+		t.Errorf("router = %q, want hunter2", secrets["router"])
+	}
+
+	reset()
+	t.Setenv("ADCTL_SECRETS_PASSPHRASE", "wrong")
+	if _, err := OpenSecrets(); err == nil {
+		t.Error("opened secrets with the wrong passphrase")
+	}
+}
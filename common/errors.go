@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// HTTPError is returned when AdGuard Home answers with a status other than 200.
+// Message holds whatever explanation the server put in the response body.
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.URL, e.Status, e.Message)
+}
+
+// AuthError means the server rejected our credentials (401 or 403)
+type AuthError struct{ HTTPError }
+
+func (e *AuthError) Unwrap() error { return &e.HTTPError }
+
+// NotFoundError means the endpoint or object doesn't exist (404)
+type NotFoundError struct{ HTTPError }
+
+func (e *NotFoundError) Unwrap() error { return &e.HTTPError }
+
+// ValidationError means the server refused the request as invalid (400 or 422).
+// Message is the server's explanation, e.g. why a rule didn't parse.
+type ValidationError struct{ HTTPError }
+
+func (e *ValidationError) Unwrap() error { return &e.HTTPError }
+
+// UnreachableError means we never got an HTTP response: DNS failure,
+// connection refused, no route to host and so on
+type UnreachableError struct {
+	Host string
+	Err  error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("can't reach %s: %v", e.Host, e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error { return e.Err }
+
+// TimeoutError means the request didn't finish before its deadline
+type TimeoutError struct {
+	Host string
+	Err  error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out talking to %s: %v", e.Host, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// newHTTPError builds the right error type for a non-200 response
+func newHTTPError(req *http.Request, resp *http.Response, body []byte) error {
+	base := HTTPError{
+		Method:     req.Method,
+		URL:        req.URL.Redacted(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    strings.TrimSpace(string(body)),
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &AuthError{base}
+	case http.StatusNotFound:
+		return &NotFoundError{base}
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return &ValidationError{base}
+	default:
+		return &base
+	}
+}
+
+// classifyTransportError turns an error from http.Client.Do into a
+// TimeoutError or UnreachableError where it can tell which it is
+func classifyTransportError(host string, err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutError{Host: host, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &TimeoutError{Host: host, Err: err}
+	}
+
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr),
+		errors.As(err, &opErr) && opErr.Op == "dial",
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH):
+		return &UnreachableError{Host: host, Err: err}
+	}
+
+	return fmt.Errorf("error Do'ing request: %w", err)
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestNewHTTPError(t *testing.T) {
+	req := &http.Request{Method: "POST", URL: &url.URL{Scheme: "http", Host: "router", Path: "/control/filtering/set_rules"}}
+
+	var tt = []struct {
+		code  int
+		check func(error) bool
+	}{
+		{code: 401, check: func(err error) bool { var e *AuthError; return errors.As(err, &e) }},
+		{code: 403, check: func(err error) bool { var e *AuthError; return errors.As(err, &e) }},
+		{code: 404, check: func(err error) bool { var e *NotFoundError; return errors.As(err, &e) }},
+		{code: 400, check: func(err error) bool { var e *ValidationError; return errors.As(err, &e) }},
+		{code: 500, check: func(err error) bool { var e *HTTPError; return errors.As(err, &e) }},
+	}
+
+	for _, entry := range tt {
+		resp := &http.Response{StatusCode: entry.code, Status: fmt.Sprintf("%d %s", entry.code, http.StatusText(entry.code))}
+		err := newHTTPError(req, resp, []byte("bad rule on line 3\n"))
+		if !entry.check(err) {
+			t.Errorf("%d: wrong error type %T", entry.code, err)
+		}
+
+		// every class still unwraps to an HTTPError carrying the server's message
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Message != "bad rule on line 3" {
+			t.Errorf("%d: server message lost: %v", entry.code, err)
+		}
+	}
+}
+
+func TestClassifyTransportError(t *testing.T) {
+	refused := &url.Error{Op: "Get", URL: "http://router", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}
+	deadline := &url.Error{Op: "Get", URL: "http://router", Err: context.DeadlineExceeded}
+
+	var unreachable *UnreachableError
+	if err := classifyTransportError("router", refused); !errors.As(err, &unreachable) {
+		t.Errorf("connection refused: expected UnreachableError, got %T", err)
+	}
+
+	var timeout *TimeoutError
+	if err := classifyTransportError("router", deadline); !errors.As(err, &timeout) {
+		t.Errorf("deadline: expected TimeoutError, got %T", err)
+	}
+
+	if err := classifyTransportError("router", context.Canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancel: expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultParallel is how many servers FanOut talks to at once when not told otherwise
+const DefaultParallel = 4
+
+// FanOutResult is the outcome of running one call against one server
+type FanOutResult[T any] struct {
+	Server   string
+	Value    T
+	Err      error
+	Duration time.Duration
+}
+
+// FanOut runs fn against every server with at most parallel calls in flight.
+// If timeout is non-zero each server's call is cancelled after that long.
+// Results come back in the same order as servers, whatever order they finish in.
+func FanOut[T any](ctx context.Context, servers []ServerConfig, parallel int, timeout time.Duration,
+	fn func(ctx context.Context, server *ServerConfig) (T, error)) []FanOutResult[T] {
+
+	if parallel < 1 {
+		parallel = len(servers)
+	}
+
+	results := make([]FanOutResult[T], len(servers))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for i := range servers {
+		server := &servers[i]
+		results[i].Server = server.Name
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+
+			serverCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				serverCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			results[i].Value, results[i].Err = fn(serverCtx, server)
+			results[i].Duration = time.Since(start)
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// FanOutError reports that some or all servers in a fan-out failed
+type FanOutError struct {
+	Failed int
+	Total  int
+	// Errs holds each failed server's error, keyed by server name
+	Errs map[string]error
+}
+
+func (e *FanOutError) Error() string {
+	if e.AllFailed() {
+		return fmt.Sprintf("all %d servers failed", e.Total)
+	}
+	return fmt.Sprintf("%d of %d servers failed", e.Failed, e.Total)
+}
+
+// AllFailed says whether every server failed
+func (e *FanOutError) AllFailed() bool {
+	return e.Failed == e.Total
+}
+
+// FanOutErr returns nil if every server succeeded, otherwise a *FanOutError
+func FanOutErr[T any](results []FanOutResult[T]) error {
+	ret := &FanOutError{Total: len(results), Errs: make(map[string]error)}
+	for _, r := range results {
+		if r.Err != nil {
+			ret.Failed++
+			ret.Errs[r.Server] = r.Err
+		}
+	}
+
+	if ret.Failed == 0 {
+		return nil
+	}
+	return ret
+}
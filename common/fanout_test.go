@@ -0,0 +1,74 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOut(t *testing.T) {
+	servers := []ServerConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+
+	var inFlight, maxInFlight atomic.Int32
+	results := FanOut(context.Background(), servers, 2, 0, func(ctx context.Context, server *ServerConfig) (string, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		// finish in reverse order to make sure results still come back in config order
+		time.Sleep(time.Duration('e'-server.Name[0]) * 5 * time.Millisecond)
+		if server.Name == "c" {
+			return "", fmt.Errorf("c is down")
+		}
+		return server.Name, nil
+	})
+
+	if maxInFlight.Load() > 2 {
+		t.Errorf("expected at most 2 calls in flight, saw %d", maxInFlight.Load())
+	}
+
+	for i, r := range results {
+		if r.Server != servers[i].Name {
+			t.Errorf("result %d: expected server %s, got %s", i, servers[i].Name, r.Server)
+		}
+		if r.Server == "c" {
+			if r.Err == nil {
+				t.Errorf("expected an error from c")
+			}
+			continue
+		}
+		if r.Value != r.Server || r.Err != nil {
+			t.Errorf("result %d: unexpected value %q, err %v", i, r.Value, r.Err)
+		}
+	}
+
+	err := FanOutErr(results)
+	fanOutErr, ok := err.(*FanOutError)
+	if !ok || fanOutErr.Failed != 1 || fanOutErr.AllFailed() {
+		t.Errorf("expected one failed server, got %v", err)
+	}
+}
+
+func TestFanOut_Timeout(t *testing.T) {
+	servers := []ServerConfig{{Name: "slow"}}
+
+	results := FanOut(context.Background(), servers, 1, 10*time.Millisecond, func(ctx context.Context, server *ServerConfig) (int, error) {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Second):
+			return 1, nil
+		}
+	})
+
+	if results[0].Err == nil {
+		t.Errorf("expected per-server timeout to fire")
+	}
+}
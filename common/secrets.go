@@ -0,0 +1,215 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SecretsFile is the passphrase-encrypted file password_secret looks in
+var SecretsFile string
+
+// SecretsPassphrase supplies the passphrase for SecretsFile. create is true
+// when the file is about to be written for the first time, so a prompt can ask
+// twice. The default reads ADCTL_SECRETS_PASSPHRASE; cmd swaps in a terminal prompt.
+var SecretsPassphrase = func(create bool) (string, error) {
+	if p := os.Getenv("ADCTL_SECRETS_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	return "", fmt.Errorf("no passphrase for %s (set ADCTL_SECRETS_PASSPHRASE)", SecretsFile)
+}
+
+// Secrets maps secret names to values
+type Secrets map[string]string
+
+const (
+	secretsVersion    = 1
+	secretsKDF        = "pbkdf2-sha256"
+	secretsIterations = 600_000
+)
+
+// secretsFile is what's on disk. Data is the AES-256-GCM sealed JSON of a Secrets.
+type secretsFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// the secrets file is unlocked at most once per process
+var (
+	secretsMu         sync.Mutex
+	secretsUnlocked   Secrets
+	secretsPassphrase string
+)
+
+// OpenSecrets decrypts SecretsFile, asking for the passphrase the first time.
+// A missing file is an empty Secrets, not an error.
+func OpenSecrets() (Secrets, error) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	if secretsUnlocked != nil {
+		return maps.Clone(secretsUnlocked), nil
+	}
+
+	if SecretsFile == "" {
+		return nil, fmt.Errorf("no secrets file configured")
+	}
+
+	data, err := os.ReadFile(SecretsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return Secrets{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	var file secretsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file %s: %w", SecretsFile, err)
+	}
+	if file.Version != secretsVersion || file.KDF != secretsKDF {
+		return nil, fmt.Errorf("secrets file %s is version %d/%s, this adctl only reads %d/%s",
+			SecretsFile, file.Version, file.KDF, secretsVersion, secretsKDF)
+	}
+
+	passphrase, err := SecretsPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := secretsCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt %s: wrong passphrase or corrupt file", SecretsFile)
+	}
+
+	var secrets Secrets
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted secrets: %w", err)
+	}
+	if secrets == nil {
+		secrets = Secrets{}
+	}
+
+	secretsUnlocked = secrets
+	secretsPassphrase = passphrase
+	return maps.Clone(secrets), nil
+}
+
+// SaveSecrets encrypts secrets into SecretsFile with a fresh salt and nonce.
+// It reuses the passphrase the file was opened with, or asks for one if the
+// file is new.
+func SaveSecrets(secrets Secrets) error {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	if SecretsFile == "" {
+		return fmt.Errorf("no secrets file configured")
+	}
+
+	passphrase := secretsPassphrase
+	if passphrase == "" {
+		var err error
+		passphrase, err = SecretsPassphrase(true)
+		if err != nil {
+			return err
+		}
+		if passphrase == "" {
+			return fmt.Errorf("passphrase cannot be empty")
+		}
+	}
+
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	file := secretsFile{
+		Version:    secretsVersion,
+		KDF:        secretsKDF,
+		Iterations: secretsIterations,
+		Salt:       make([]byte, 16),
+	}
+	rand.Read(file.Salt)
+
+	aead, err := secretsCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+
+	file.Nonce = make([]byte, aead.NonceSize())
+	rand.Read(file.Nonce)
+	file.Data = aead.Seal(nil, file.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(file, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(SecretsFile), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	if err := writePrivateFile(SecretsFile, data); err != nil {
+		return fmt.Errorf("failed to save secrets: %w", err)
+	}
+
+	secretsUnlocked = maps.Clone(secrets)
+	secretsPassphrase = passphrase
+	return nil
+}
+
+// secretsCipher derives the AES-256-GCM key from the passphrase
+func secretsCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// writePrivateFile writes data to path with mode 0600, via a temp file and a
+// rename so nobody ever reads half a file
+func writePrivateFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,327 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Auth modes for ServerConfig.Auth
+const (
+	AuthBasic   = "basic"
+	AuthSession = "session"
+)
+
+// SessionCookieName is the cookie AdGuard Home hands out on /control/login
+const SessionCookieName = "agh_session"
+
+// SessionDir is where session cookies are cached between runs, one file per
+// server. Empty means they're only kept in memory for the life of the process.
+var SessionDir string
+
+// session is a cached login
+type session struct {
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+func (s session) expired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = make(map[string]session)
+
+	// loginLocks holds a *sync.Mutex per server so concurrent requests to
+	// the same server log in once rather than once each
+	loginLocks sync.Map
+)
+
+// authMode returns which auth mode to use for a server.
+// If server is nil, uses legacy viper config
+func authMode(server *ServerConfig) (string, error) {
+	var mode string
+	if server != nil {
+		mode = server.Auth
+	} else {
+		mode = viper.GetString("auth")
+	}
+
+	switch strings.ToLower(mode) {
+	case "", AuthBasic:
+		return AuthBasic, nil
+	case AuthSession:
+		return AuthSession, nil
+	default:
+		return "", fmt.Errorf("unknown auth mode %q, must be %s or %s", mode, AuthBasic, AuthSession)
+	}
+}
+
+// sessionKey names the cached session for a server
+func sessionKey(server *ServerConfig, host string) string {
+	if server != nil && server.Name != "" {
+		return server.Name
+	}
+	return host
+}
+
+// controlPrefix returns whatever comes before /control/ in an API path,
+// which is the reverse proxy prefix if there is one
+func controlPrefix(path string) string {
+	if i := strings.Index(path, "/control/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// sendWithSession sends a request using a cached session cookie, logging in
+// first if there isn't one and again if the server says it has expired.
+// creds is only called to log in.
+func sendWithSession(ctx context.Context, client *http.Client, ca CommandArgs, jsonData []byte, creds func() (string, string, error)) ([]byte, error) {
+	key := sessionKey(ca.Server, ca.URL.Host)
+
+	s, ok := loadSession(key)
+	if !ok {
+		var err error
+		s, err = login(ctx, client, ca, key, creds, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := send(ctx, client, ca, jsonData, withSession(s))
+
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		return body, err
+	}
+
+	// session expired or was revoked, log in again and retry once
+	s, err = login(ctx, client, ca, key, creds, s.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return send(ctx, client, ca, jsonData, withSession(s))
+}
+
+func withSession(s session) func(*http.Request) {
+	return func(request *http.Request) {
+		request.AddCookie(&http.Cookie{Name: SessionCookieName, Value: s.Value})
+	}
+}
+
+// login posts credentials to /control/login and caches the session cookie.
+// stale is the cookie value that just failed, if any. If another goroutine has
+// already replaced it by the time we get the lock, its session is used instead.
+func login(ctx context.Context, client *http.Client, ca CommandArgs, key string, creds func() (string, string, error), stale string) (session, error) {
+	lock, _ := loginLocks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if s, ok := loadSession(key); ok && s.Value != stale {
+		return s, nil
+	}
+
+	username, password, err := creds()
+	if err != nil {
+		return session{}, err
+	}
+
+	loginURL := ca.URL
+	loginURL.Path = controlPrefix(ca.URL.Path) + "/control/login"
+	loginURL.RawQuery = ""
+
+	jsonData, err := json.Marshal(map[string]string{"name": username, "password": password})
+	if err != nil {
+		return session{}, fmt.Errorf("error marshaling json: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", loginURL.String(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return session{}, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return session{}, classifyTransportError(loginURL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return session{}, fmt.Errorf("error reading response: %v", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return session{}, newHTTPError(request, resp, body)
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name != SessionCookieName {
+			continue
+		}
+
+		s := session{Value: cookie.Value, Expires: cookie.Expires}
+		if cookie.MaxAge > 0 {
+			s.Expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+
+		if err := saveSession(key, s); err != nil {
+			return session{}, err
+		}
+		return s, nil
+	}
+
+	return session{}, fmt.Errorf("login to %s succeeded but no %s cookie came back", loginURL.Host, SessionCookieName)
+}
+
+// Logout ends a server's session, if there is one, and forgets the cached cookie
+func Logout(ctx context.Context, server *ServerConfig) error {
+	baseURL, err := GetBaseURL(server)
+	if err != nil {
+		return err
+	}
+
+	key := sessionKey(server, baseURL.Host)
+	s, ok := loadSession(key)
+	if err := forgetSession(key); err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	transport, err := getTransport(tlsSettingsFor(server))
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Transport: transport,
+		// logout redirects to the login page, which we don't care about
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	logoutURL := baseURL
+	logoutURL.Path = baseURL.Path + "/control/logout"
+
+	request, err := http.NewRequestWithContext(ctx, "GET", logoutURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	withSession(s)(request)
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return classifyTransportError(logoutURL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 && resp.StatusCode != http.StatusFound {
+		body, _ := io.ReadAll(resp.Body)
+		return newHTTPError(request, resp, body)
+	}
+
+	return nil
+}
+
+// sessionPath is the cache file for a session key
+func sessionPath(key string) string {
+	return filepath.Join(SessionDir, url.PathEscape(key)+".json")
+}
+
+// loadSession returns the cached, unexpired session for key, checking memory then disk
+func loadSession(key string) (session, bool) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	if s, ok := sessions[key]; ok && !s.expired() {
+		return s, true
+	}
+
+	if SessionDir == "" {
+		return session{}, false
+	}
+
+	data, err := os.ReadFile(sessionPath(key))
+	if err != nil {
+		return session{}, false
+	}
+
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil || s.Value == "" || s.expired() {
+		return session{}, false
+	}
+
+	sessions[key] = s
+	return s, true
+}
+
+// saveSession caches a session in memory and, if SessionDir is set, on disk.
+// The directory is 0700 and the file 0600 since the cookie is as good as a password.
+func saveSession(key string, s session) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	sessions[key] = s
+
+	if SessionDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(SessionDir, 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err := writePrivateFile(sessionPath(key), data); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return nil
+}
+
+// forgetSession drops a cached session from memory and disk
+func forgetSession(key string) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	delete(sessions, key)
+
+	if SessionDir == "" {
+		return nil
+	}
+
+	err := os.Remove(sessionPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cached session: %w", err)
+	}
+
+	return nil
+}
+
+// ForgetSession deletes the cached session cookie for a named server without
+// logging out, e.g. when the server is removed from the config
+func ForgetSession(name string) error {
+	return forgetSession(name)
+}
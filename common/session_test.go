@@ -0,0 +1,112 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSendWithSession(t *testing.T) {
+	SessionDir = t.TempDir()
+	defer func() { SessionDir = "" }()
+
+	var logins atomic.Int32
+	var current atomic.Value
+	current.Store("one")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/control/login":
+			logins.Add(1)
+			http.SetCookie(w, &http.Cookie{Name: SessionCookieName, Value: current.Load().(string)})
+		case "/control/status":
+			if _, _, ok := r.BasicAuth(); ok {
+				t.Error("basic auth sent in session mode")
+			}
+			c, err := r.Cookie(SessionCookieName)
+			if err != nil || c.Value != current.Load().(string) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	server := &ServerConfig{Name: "test", Host: srv.URL, Username: "admin", Password: "pw", Auth: AuthSession}
+	u, _ := url.Parse(srv.URL + "/control/status")
+	ca := CommandArgs{Method: "GET", URL: *u, Server: server}
+
+	for range 3 {
+		if _, err := SendCommandContext(context.Background(), ca); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := logins.Load(); n != 1 {
+		t.Errorf("logged in %d times, want 1", n)
+	}
+
+	info, err := os.Stat(filepath.Join(SessionDir, "test.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("session file mode %o, want 600", perm)
+	}
+
+	// the server forgets the session, so the next request has to log in again
+	current.Store("two")
+	if _, err := SendCommandContext(context.Background(), ca); err != nil {
+		t.Fatal(err)
+	}
+	if n := logins.Load(); n != 2 {
+		t.Errorf("logged in %d times, want 2", n)
+	}
+}
+
+func TestAuthMode(t *testing.T) {
+	tests := []struct {
+		auth    string
+		want    string
+		wantErr bool
+	}{
+		{"", AuthBasic, false},
+		{"basic", AuthBasic, false},
+		{"Session", AuthSession, false},
+		{"digest", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := authMode(&ServerConfig{Auth: tt.auth})
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("authMode(%q) = %q, %v; want %q, err %v", tt.auth, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSendWithSessionCachedSkipsPassword(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(SessionCookieName); err != nil || c.Value != "cached" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	// the password can't be had, so the request only works if it's never asked for
+	server := &ServerConfig{Name: "cached", Host: srv.URL, Username: "admin", PasswordCommand: "exit 1", Auth: AuthSession}
+	if err := saveSession("cached", session{Value: "cached"}); err != nil {
+		t.Fatal(err)
+	}
+	defer forgetSession("cached")
+
+	u, _ := url.Parse(srv.URL + "/control/status")
+	if _, err := SendCommandContext(context.Background(), CommandArgs{Method: "GET", URL: *u, Server: server}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,105 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// tlsSettings are the parts of a ServerConfig that affect the HTTP transport
+type tlsSettings struct {
+	caCert             string
+	clientCert         string
+	clientKey          string
+	insecureSkipVerify bool
+}
+
+// transports caches one transport per distinct set of TLS settings so
+// connections are reused across requests
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[tlsSettings]*http.Transport)
+)
+
+// tlsSettingsFor pulls the TLS settings out of a server config.
+// If server is nil, uses legacy viper config
+func tlsSettingsFor(server *ServerConfig) tlsSettings {
+	if server == nil {
+		return tlsSettings{
+			caCert:             viper.GetString("ca_cert"),
+			clientCert:         viper.GetString("client_cert"),
+			clientKey:          viper.GetString("client_key"),
+			insecureSkipVerify: viper.GetBool("insecure_skip_verify"),
+		}
+	}
+	return tlsSettings{
+		caCert:             server.CACert,
+		clientCert:         server.ClientCert,
+		clientKey:          server.ClientKey,
+		insecureSkipVerify: server.InsecureSkipVerify,
+	}
+}
+
+// getTransport returns a transport built from the given TLS settings
+func getTransport(ts tlsSettings) (*http.Transport, error) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	if t, ok := transports[ts]; ok {
+		return t, nil
+	}
+
+	tlsConfig, err := buildTLSConfig(ts)
+	if err != nil {
+		return nil, err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+	transports[ts] = t
+
+	return t, nil
+}
+
+// buildTLSConfig turns TLS settings into a tls.Config
+func buildTLSConfig(ts tlsSettings) (*tls.Config, error) {
+	ret := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: ts.insecureSkipVerify,
+	}
+
+	if ts.caCert != "" {
+		pem, err := os.ReadFile(ts.caCert)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA bundle: %w", err)
+		}
+
+		// start from the system pool so a private CA doesn't break public certs
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", ts.caCert)
+		}
+		ret.RootCAs = pool
+	}
+
+	switch {
+	case ts.clientCert != "" && ts.clientKey != "":
+		cert, err := tls.LoadX509KeyPair(ts.clientCert, ts.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate: %w", err)
+		}
+		ret.Certificates = []tls.Certificate{cert}
+	case ts.clientCert != "" || ts.clientKey != "":
+		return nil, fmt.Errorf("client_cert and client_key must be set together")
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name     string
+		settings tlsSettings
+		wantErr  bool
+	}{
+		{"defaults", tlsSettings{}, false},
+		{"insecure", tlsSettings{insecureSkipVerify: true}, false},
+		{"cert without key", tlsSettings{clientCert: notPEM}, true},
+		{"key without cert", tlsSettings{clientKey: notPEM}, true},
+		{"unloadable cert and key", tlsSettings{clientCert: notPEM, clientKey: notPEM}, true},
+		{"missing CA file", tlsSettings{caCert: missing}, true},
+		{"CA file without certificates", tlsSettings{caCert: notPEM}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildTLSConfig(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.MinVersion != tls.VersionTLS12 || got.InsecureSkipVerify != tt.settings.insecureSkipVerify {
+				t.Errorf("buildTLSConfig() = MinVersion %x, InsecureSkipVerify %v", got.MinVersion, got.InsecureSkipVerify)
+			}
+		})
+	}
+}
@@ -0,0 +1,505 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type builtin struct {
+	args  int
+	build func(args []Query) Query
+}
+
+// simple wraps a builtin that takes no arguments and has exactly one output
+func simple(f func(v any) (any, error)) builtin {
+	return builtin{0, func([]Query) Query {
+		return func(v any) ([]any, error) {
+			x, err := f(v)
+			if err != nil {
+				return nil, err
+			}
+			return []any{x}, nil
+		}
+	}}
+}
+
+// withArg wraps a builtin whose one argument is evaluated against the input
+func withArg(f func(v, arg any) (any, error)) builtin {
+	return builtin{1, func(args []Query) Query {
+		return func(v any) ([]any, error) {
+			outs, err := args[0](v)
+			if err != nil {
+				return nil, err
+			}
+			var ret []any
+			for _, arg := range outs {
+				x, err := f(v, arg)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, x)
+			}
+			return ret, nil
+		}
+	}}
+}
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"empty": {0, func([]Query) Query {
+			return func(any) ([]any, error) { return nil, nil }
+		}},
+		"not":  simple(func(v any) (any, error) { return !truthy(v), nil }),
+		"type": simple(func(v any) (any, error) { return typeName(v), nil }),
+		"length": simple(func(v any) (any, error) {
+			switch t := v.(type) {
+			case nil:
+				return json.Number("0"), nil
+			case string:
+				return number(float64(len([]rune(t)))), nil
+			case []any:
+				return number(float64(len(t))), nil
+			case *Object:
+				return number(float64(len(t.Keys))), nil
+			case json.Number:
+				f, _ := t.Float64()
+				return number(math.Abs(f)), nil
+			}
+			return nil, fmt.Errorf("%s has no length", typeName(v))
+		}),
+		"keys": simple(func(v any) (any, error) {
+			switch t := v.(type) {
+			case *Object:
+				keys := slices.Sorted(slices.Values(t.Keys))
+				ret := make([]any, len(keys))
+				for i, k := range keys {
+					ret[i] = k
+				}
+				return ret, nil
+			case []any:
+				ret := make([]any, len(t))
+				for i := range t {
+					ret[i] = number(float64(i))
+				}
+				return ret, nil
+			}
+			return nil, fmt.Errorf("%s has no keys", typeName(v))
+		}),
+		"to_entries": simple(func(v any) (any, error) {
+			o, ok := v.(*Object)
+			if !ok {
+				return nil, fmt.Errorf("%s has no entries", typeName(v))
+			}
+			ret := make([]any, len(o.Keys))
+			for i, k := range o.Keys {
+				ret[i] = &Object{Keys: []string{"key", "value"}, Vals: map[string]any{"key": k, "value": o.Vals[k]}}
+			}
+			return ret, nil
+		}),
+		"has": withArg(func(v, key any) (any, error) {
+			switch t := v.(type) {
+			case *Object:
+				k, ok := key.(string)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether object has a %s key", typeName(key))
+				}
+				_, has := t.Vals[k]
+				return has, nil
+			case []any:
+				f, ok := toFloat(key)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether array has a %s key", typeName(key))
+				}
+				return f >= 0 && int(f) < len(t), nil
+			}
+			return nil, fmt.Errorf("cannot check whether %s has a key", typeName(v))
+		}),
+		"select": {1, func(args []Query) Query {
+			return func(v any) ([]any, error) {
+				outs, err := args[0](v)
+				if err != nil {
+					return nil, err
+				}
+				var ret []any
+				for _, out := range outs {
+					if truthy(out) {
+						ret = append(ret, v)
+					}
+				}
+				return ret, nil
+			}
+		}},
+		"map": {1, func(args []Query) Query {
+			return func(v any) ([]any, error) {
+				elems, err := iterate(v)
+				if err != nil {
+					return nil, err
+				}
+				ret := []any{}
+				for _, elem := range elems {
+					outs, err := args[0](elem)
+					if err != nil {
+						return nil, err
+					}
+					ret = append(ret, outs...)
+				}
+				return []any{ret}, nil
+			}
+		}},
+		"sort_by": {1, func(args []Query) Query {
+			return func(v any) ([]any, error) {
+				arr, ok := v.([]any)
+				if !ok {
+					return nil, fmt.Errorf("cannot sort %s", typeName(v))
+				}
+				keys := make([]any, len(arr))
+				for i, elem := range arr {
+					outs, err := args[0](elem)
+					if err != nil {
+						return nil, err
+					}
+					keys[i] = outs
+					if len(outs) == 1 {
+						keys[i] = outs[0]
+					}
+				}
+				idx := make([]int, len(arr))
+				for i := range idx {
+					idx[i] = i
+				}
+				slices.SortStableFunc(idx, func(a, b int) int { return compareValues(keys[a], keys[b]) })
+				ret := make([]any, len(arr))
+				for i, j := range idx {
+					ret[i] = arr[j]
+				}
+				return []any{ret}, nil
+			}
+		}},
+		"first": simple(func(v any) (any, error) { return index(v, json.Number("0")) }),
+		"last":  simple(func(v any) (any, error) { return index(v, json.Number("-1")) }),
+		"reverse": simple(func(v any) (any, error) {
+			return arrayOp(v, func(a []any) any { c := slices.Clone(a); slices.Reverse(c); return c })
+		}),
+		"sort": simple(func(v any) (any, error) {
+			return arrayOp(v, func(a []any) any { c := slices.Clone(a); slices.SortStableFunc(c, compareValues); return c })
+		}),
+		"unique": simple(func(v any) (any, error) {
+			return arrayOp(v, func(a []any) any {
+				c := slices.Clone(a)
+				slices.SortStableFunc(c, compareValues)
+				return slices.CompactFunc(c, func(x, y any) bool { return compareValues(x, y) == 0 })
+			})
+		}),
+		"min": simple(func(v any) (any, error) {
+			return arrayOp(v, func(a []any) any {
+				if len(a) == 0 {
+					return nil
+				}
+				return slices.MinFunc(a, compareValues)
+			})
+		}),
+		"max": simple(func(v any) (any, error) {
+			return arrayOp(v, func(a []any) any {
+				if len(a) == 0 {
+					return nil
+				}
+				return slices.MaxFunc(a, compareValues)
+			})
+		}),
+		"add": simple(func(v any) (any, error) {
+			elems, err := iterate(v)
+			if err != nil {
+				return nil, err
+			}
+			var sum any
+			for _, elem := range elems {
+				if sum, err = arith("+", sum, elem); err != nil {
+					return nil, err
+				}
+			}
+			return sum, nil
+		}),
+		"tostring": simple(func(v any) (any, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return compactJSON(v), nil
+		}),
+		"tonumber": simple(func(v any) (any, error) {
+			switch t := v.(type) {
+			case json.Number:
+				return t, nil
+			case string:
+				f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse %q as a number", t)
+				}
+				return number(f), nil
+			}
+			return nil, fmt.Errorf("cannot convert %s to a number", typeName(v))
+		}),
+		"ascii_downcase": simple(func(v any) (any, error) { return stringOp(v, strings.ToLower) }),
+		"ascii_upcase":   simple(func(v any) (any, error) { return stringOp(v, strings.ToUpper) }),
+		"join": withArg(func(v, sep any) (any, error) {
+			arr, ok := v.([]any)
+			s, sok := sep.(string)
+			if !ok || !sok {
+				return nil, fmt.Errorf("join needs an array and a string separator")
+			}
+			parts := make([]string, len(arr))
+			for i, elem := range arr {
+				// like jq, scalars join as themselves and null as nothing
+				switch t := elem.(type) {
+				case nil:
+				case string:
+					parts[i] = t
+				case json.Number, bool:
+					parts[i] = fmt.Sprint(t)
+				default:
+					return nil, fmt.Errorf("cannot join %s", typeName(elem))
+				}
+			}
+			return strings.Join(parts, s), nil
+		}),
+		"split": withArg(func(v, sep any) (any, error) {
+			s, ok := v.(string)
+			p, pok := sep.(string)
+			if !ok || !pok {
+				return nil, fmt.Errorf("split needs a string and a string separator")
+			}
+			ret := []any{}
+			for _, part := range strings.Split(s, p) {
+				ret = append(ret, part)
+			}
+			return ret, nil
+		}),
+		"test": withArg(func(v, re any) (any, error) {
+			s, ok := v.(string)
+			pattern, pok := re.(string)
+			if !ok || !pok {
+				return nil, fmt.Errorf("test needs a string and a regular expression")
+			}
+			r, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			return r.MatchString(s), nil
+		}),
+		"startswith": withArg(func(v, x any) (any, error) { return stringTest(v, x, strings.HasPrefix) }),
+		"endswith":   withArg(func(v, x any) (any, error) { return stringTest(v, x, strings.HasSuffix) }),
+		"contains": withArg(func(v, x any) (any, error) {
+			if s, ok := v.(string); ok {
+				return stringTest(s, x, strings.Contains)
+			}
+			if arr, ok := v.([]any); ok {
+				want, ok := x.([]any)
+				if !ok {
+					want = []any{x}
+				}
+				for _, w := range want {
+					if !slices.ContainsFunc(arr, func(e any) bool { return compareValues(e, w) == 0 }) {
+						return false, nil
+					}
+				}
+				return true, nil
+			}
+			return compareValues(v, x) == 0, nil
+		}),
+	}
+}
+
+func arrayOp(v any, f func([]any) any) (any, error) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %s", typeName(v))
+	}
+	return f(arr), nil
+}
+
+func stringOp(v any, f func(string) string) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a string, got %s", typeName(v))
+	}
+	return f(s), nil
+}
+
+func stringTest(v, x any, f func(s, sub string) bool) (any, error) {
+	s, ok := v.(string)
+	sub, sok := x.(string)
+	if !ok || !sok {
+		return nil, fmt.Errorf("expected strings, got %s and %s", typeName(v), typeName(x))
+	}
+	return f(s, sub), nil
+}
+
+// arith does + - * / % on numbers, + on strings, arrays and objects, and treats null as nothing for +
+func arith(op string, a, b any) (any, error) {
+	if op == "+" {
+		if a == nil {
+			return b, nil
+		}
+		if b == nil {
+			return a, nil
+		}
+	}
+
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		switch op {
+		case "+":
+			return number(af + bf), nil
+		case "-":
+			return number(af - bf), nil
+		case "*":
+			return number(af * bf), nil
+		case "/":
+			if bf == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return number(af / bf), nil
+		case "%":
+			if int64(bf) == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return number(float64(int64(af) % int64(bf))), nil
+		}
+	}
+
+	if op == "+" {
+		switch at := a.(type) {
+		case string:
+			if bt, ok := b.(string); ok {
+				return at + bt, nil
+			}
+		case []any:
+			if bt, ok := b.([]any); ok {
+				return append(slices.Clone(at), bt...), nil
+			}
+		case *Object:
+			if bt, ok := b.(*Object); ok {
+				ret := &Object{Keys: slices.Clone(at.Keys), Vals: make(map[string]any)}
+				for k, x := range at.Vals {
+					ret.Vals[k] = x
+				}
+				for _, k := range bt.Keys {
+					if _, dup := ret.Vals[k]; !dup {
+						ret.Keys = append(ret.Keys, k)
+					}
+					ret.Vals[k] = bt.Vals[k]
+				}
+				return ret, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("cannot %s %s and %s", map[string]string{"+": "add", "-": "subtract", "*": "multiply", "/": "divide", "%": "divide"}[op], typeName(a), typeName(b))
+}
+
+// number turns a float into a json.Number without a trailing .0 for whole numbers
+func number(f float64) json.Number {
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func toFloat(v any) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// truthy is jq's idea of true: anything but false and null
+func truthy(v any) bool {
+	return v != nil && v != false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case *Object:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// compareValues orders values the way jq does:
+// null < false < true < numbers < strings < arrays < objects
+func compareValues(a, b any) int {
+	rank := func(v any) int {
+		switch t := v.(type) {
+		case nil:
+			return 0
+		case bool:
+			if t {
+				return 2
+			}
+			return 1
+		case json.Number:
+			return 3
+		case string:
+			return 4
+		case []any:
+			return 5
+		}
+		return 6
+	}
+
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch at := a.(type) {
+	case json.Number:
+		af, _ := toFloat(at)
+		bf, _ := toFloat(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(at, b.(string))
+	case []any:
+		bt := b.([]any)
+		for i := 0; i < len(at) && i < len(bt); i++ {
+			if c := compareValues(at[i], bt[i]); c != 0 {
+				return c
+			}
+		}
+		return len(at) - len(bt)
+	case *Object:
+		bt := b.(*Object)
+		ak := slices.Sorted(slices.Values(at.Keys))
+		bk := slices.Sorted(slices.Values(bt.Keys))
+		if c := slices.Compare(ak, bk); c != 0 {
+			return c
+		}
+		for _, k := range ak {
+			if c := compareValues(at.Vals[k], bt.Vals[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,821 @@
+// Package query is the small jq-flavoured language behind adctl's --query, so
+// there's no need for jq on boxes that don't have it. It runs over the trees
+// Parse and FromValue make: *Object, []any, string, json.Number, bool and nil.
+//
+// It is a subset of jq. This is all of it:
+//
+//	paths      .  .foo  .foo.bar  ."odd key"  .[0]  .[-1]  .[2:5]  .[]  .["k"]  .foo?
+//	literals   "string"  42  1.5  true  false  null  [ ... ]  { name: .x, "k": .y, z }
+//	operators  a | b   a, b   a // b   (a)   == != < <= > >=   and or   + - * / %   -a
+//	functions  empty not type length keys to_entries has(k) select(f) map(f)
+//	           sort sort_by(f) unique reverse first last min max add
+//	           tostring tonumber ascii_downcase ascii_upcase join(s) split(s)
+//	           test(re) startswith(s) endswith(s) contains(x)
+//
+// Anything else is rejected by Compile rather than when the query runs. That
+// includes recursion (..), variables ($x, as), if/then/else, reduce, foreach,
+// try/catch, def, label, string interpolation and @formats.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a compiled expression. It maps one input to any number of outputs.
+type Query func(v any) ([]any, error)
+
+// Compile parses an expression, failing on syntax outside the supported subset
+func Compile(src string) (Query, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	q, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		if err := unsupported(t); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+
+	return q, nil
+}
+
+// Run applies q to v, returning each of its outputs in order
+func (q Query) Run(v any) ([]any, error) {
+	return q(v)
+}
+
+// jqKeywords are jq syntax this package doesn't have, with what to call them in errors
+var jqKeywords = map[string]string{
+	"if": "if/then/else", "then": "if/then/else", "elif": "if/then/else", "else": "if/then/else", "end": "if/then/else",
+	"reduce": "reduce", "foreach": "foreach", "as": "variables",
+	"try": "try/catch", "catch": "try/catch", "label": "label",
+	"def": "def", "import": "modules", "include": "modules",
+}
+
+// unsupported says so when t is jq syntax outside the subset, and is nil otherwise
+func unsupported(t token) error {
+	if t.kind != tokIdent {
+		return nil
+	}
+	if what, ok := jqKeywords[t.text]; ok {
+		return fmt.Errorf("%s is not supported (%q at offset %d)", what, t.text, t.pos)
+	}
+	return nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokPunct
+	tokIdent
+	tokString
+	tokNumber
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+	// glued is true when there was no space before the token, which is how
+	// `.foo` (a field) is told apart from `. foo` (identity, then a function)
+	glued bool
+}
+
+var punct = []string{"==", "!=", "<=", ">=", "//", "|", ",", ".", "[", "]", "(", ")", "{", "}", ":", ";", "?", "<", ">", "+", "-", "*", "/", "%"}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	glued := true
+
+	for i < len(src) {
+		c := rune(src[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			glued = false
+			continue
+
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					if j+1 < len(src) && src[j+1] == '(' {
+						return nil, fmt.Errorf("string interpolation is not supported (offset %d)", j)
+					}
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("bad string at offset %d: %w", i, err)
+			}
+			toks = append(toks, token{tokString, s, i, glued})
+			i = j + 1
+
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' || src[j] == 'e' || src[j] == 'E') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i, glued})
+			i = j
+
+		case strings.HasPrefix(src[i:], ".."):
+			return nil, fmt.Errorf("recursion (..) is not supported (offset %d)", i)
+
+		case c == '$':
+			return nil, fmt.Errorf("variables are not supported (offset %d)", i)
+
+		case c == '@':
+			return nil, fmt.Errorf("@formats are not supported (offset %d)", i)
+
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i, glued})
+			i = j
+
+		default:
+			matched := false
+			for _, p := range punct {
+				if strings.HasPrefix(src[i:], p) {
+					toks = append(toks, token{tokPunct, p, i, glued})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+		}
+		glued = true
+	}
+
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) at(kind tokKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && (text == "" || t.text == text)
+}
+
+func (p *parser) accept(kind tokKind, text string) bool {
+	if p.at(kind, text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(tokPunct, text) {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of query", text)
+		}
+		return fmt.Errorf("expected %q at offset %d, got %q", text, t.pos, t.text)
+	}
+	return nil
+}
+
+// parsePipe parses a | b | c
+func (p *parser) parsePipe() (Query, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokPunct, "|") {
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipe(left, right)
+	}
+
+	return left, nil
+}
+
+func pipe(left, right Query) Query {
+	return func(v any) ([]any, error) {
+		ins, err := left(v)
+		if err != nil {
+			return nil, err
+		}
+		var ret []any
+		for _, in := range ins {
+			outs, err := right(in)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, outs...)
+		}
+		return ret, nil
+	}
+}
+
+// parseComma parses a, b
+func (p *parser) parseComma() (Query, error) {
+	left, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokPunct, ",") {
+		right, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v any) ([]any, error) {
+			a, err := l(v)
+			if err != nil {
+				return nil, err
+			}
+			b, err := right(v)
+			if err != nil {
+				return nil, err
+			}
+			return append(a, b...), nil
+		}
+	}
+
+	return left, nil
+}
+
+// parseAlt parses a // b, which is a's truthy outputs or else b
+func (p *parser) parseAlt() (Query, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokPunct, "//") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v any) ([]any, error) {
+			a, _ := l(v)
+			var ret []any
+			for _, x := range a {
+				if truthy(x) {
+					ret = append(ret, x)
+				}
+			}
+			if len(ret) > 0 {
+				return ret, nil
+			}
+			return right(v)
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOr() (Query, error) {
+	return p.parseBool("or", p.parseAnd, func(a, b bool) bool { return a || b })
+}
+
+func (p *parser) parseAnd() (Query, error) {
+	return p.parseBool("and", p.parseCompare, func(a, b bool) bool { return a && b })
+}
+
+func (p *parser) parseBool(word string, sub func() (Query, error), op func(a, b bool) bool) (Query, error) {
+	left, err := sub()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokIdent, word) {
+		right, err := sub()
+		if err != nil {
+			return nil, err
+		}
+		left = binary(left, right, func(a, b any) (any, error) {
+			return op(truthy(a), truthy(b)), nil
+		})
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseCompare() (Query, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	ops := map[string]func(int) bool{
+		"==": func(c int) bool { return c == 0 },
+		"!=": func(c int) bool { return c != 0 },
+		"<":  func(c int) bool { return c < 0 },
+		"<=": func(c int) bool { return c <= 0 },
+		">":  func(c int) bool { return c > 0 },
+		">=": func(c int) bool { return c >= 0 },
+	}
+
+	if t := p.peek(); t.kind == tokPunct && ops[t.text] != nil {
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		test := ops[t.text]
+		left = binary(left, right, func(a, b any) (any, error) {
+			return test(compareValues(a, b)), nil
+		})
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (Query, error) {
+	return p.parseArith([]string{"+", "-"}, p.parseMultiplicative)
+}
+
+func (p *parser) parseMultiplicative() (Query, error) {
+	return p.parseArith([]string{"*", "/", "%"}, p.parsePostfix)
+}
+
+func (p *parser) parseArith(ops []string, sub func() (Query, error)) (Query, error) {
+	left, err := sub()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != tokPunct || !slices.Contains(ops, t.text) {
+			return left, nil
+		}
+		p.next()
+
+		right, err := sub()
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		left = binary(left, right, func(a, b any) (any, error) {
+			return arith(op, a, b)
+		})
+	}
+}
+
+// binary runs left and right against the same input and combines every pair of outputs
+func binary(left, right Query, op func(a, b any) (any, error)) Query {
+	return func(v any) ([]any, error) {
+		as, err := left(v)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := right(v)
+		if err != nil {
+			return nil, err
+		}
+		var ret []any
+		for _, a := range as {
+			for _, b := range bs {
+				x, err := op(a, b)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, x)
+			}
+		}
+		return ret, nil
+	}
+}
+
+// parsePostfix parses a primary followed by any number of .field, [..] and ?
+func (p *parser) parsePostfix() (Query, error) {
+	q, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.at(tokPunct, "."):
+			p.next()
+			field, ok := p.fieldName()
+			if !ok {
+				return nil, fmt.Errorf("expected a field name after '.' at offset %d", p.peek().pos)
+			}
+			q = pipe(q, indexQuery(field))
+
+		case p.at(tokPunct, "[") && p.peek().glued:
+			p.next()
+			sub, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			q = pipe(q, sub)
+
+		case p.accept(tokPunct, "?"):
+			inner := q
+			q = func(v any) ([]any, error) {
+				ret, err := inner(v)
+				if err != nil {
+					return nil, nil
+				}
+				return ret, nil
+			}
+
+		default:
+			return q, nil
+		}
+	}
+}
+
+// fieldName reads the name in .foo or ."foo bar", if the next token is glued to the dot
+func (p *parser) fieldName() (string, bool) {
+	t := p.peek()
+	if !t.glued || (t.kind != tokIdent && t.kind != tokString) {
+		return "", false
+	}
+	p.next()
+	return t.text, true
+}
+
+// parseBracket parses what follows '[' in .[], .[n], .["k"] and .[a:b]
+func (p *parser) parseBracket() (Query, error) {
+	if p.accept(tokPunct, "]") {
+		return iterate, nil
+	}
+
+	var from, to Query
+	var err error
+	if !p.at(tokPunct, ":") {
+		if from, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept(tokPunct, ":") {
+		if !p.at(tokPunct, "]") {
+			if to, err = p.parsePipe(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return sliceQuery(from, to), nil
+	}
+
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+
+	return func(v any) ([]any, error) {
+		keys, err := from(v)
+		if err != nil {
+			return nil, err
+		}
+		var ret []any
+		for _, k := range keys {
+			x, err := index(v, k)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, x)
+		}
+		return ret, nil
+	}, nil
+}
+
+func (p *parser) parsePrimary() (Query, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+
+	case tokNumber:
+		if _, err := strconv.ParseFloat(t.text, 64); err != nil {
+			return nil, fmt.Errorf("bad number %q at offset %d", t.text, t.pos)
+		}
+		return constant(json.Number(t.text)), nil
+
+	case tokString:
+		return constant(t.text), nil
+
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return constant(true), nil
+		case "false":
+			return constant(false), nil
+		case "null":
+			return constant(nil), nil
+		}
+		if err := unsupported(t); err != nil {
+			return nil, err
+		}
+		return p.parseFunc(t)
+	}
+
+	switch t.text {
+	case ".":
+		if field, ok := p.fieldName(); ok {
+			return indexQuery(field), nil
+		}
+		if p.at(tokPunct, "[") && p.peek().glued {
+			p.next()
+			return p.parseBracket()
+		}
+		return identity, nil
+
+	case "(":
+		q, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return q, p.expect(")")
+
+	case "[":
+		if p.accept(tokPunct, "]") {
+			return constant([]any{}), nil
+		}
+		q, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return func(v any) ([]any, error) {
+			ret, err := q(v)
+			if err != nil {
+				return nil, err
+			}
+			if ret == nil {
+				ret = []any{}
+			}
+			return []any{ret}, nil
+		}, nil
+
+	case "{":
+		return p.parseObject()
+
+	case "-":
+		q, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return binary(constant(json.Number("0")), q, func(a, b any) (any, error) {
+			return arith("-", a, b)
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+// parseObject parses { key: expr, "other key": expr, shorthand }
+func (p *parser) parseObject() (Query, error) {
+	type pair struct {
+		key string
+		val Query
+	}
+	var pairs []pair
+
+	for !p.accept(tokPunct, "}") {
+		if len(pairs) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokString {
+			return nil, fmt.Errorf("expected an object key at offset %d", t.pos)
+		}
+
+		if !p.accept(tokPunct, ":") {
+			pairs = append(pairs, pair{t.text, indexQuery(t.text)})
+			continue
+		}
+
+		val, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair{t.text, val})
+	}
+
+	return func(v any) ([]any, error) {
+		// every combination of each key's outputs, like jq
+		objs := []*Object{{Vals: map[string]any{}}}
+		for _, kv := range pairs {
+			outs, err := kv.val(v)
+			if err != nil {
+				return nil, err
+			}
+			var next []*Object
+			for _, o := range objs {
+				for _, out := range outs {
+					n := &Object{Keys: slices.Clone(o.Keys), Vals: make(map[string]any, len(o.Vals)+1)}
+					for k, x := range o.Vals {
+						n.Vals[k] = x
+					}
+					if _, dup := n.Vals[kv.key]; !dup {
+						n.Keys = append(n.Keys, kv.key)
+					}
+					n.Vals[kv.key] = out
+					next = append(next, n)
+				}
+			}
+			objs = next
+		}
+
+		ret := make([]any, len(objs))
+		for i, o := range objs {
+			ret[i] = o
+		}
+		return ret, nil
+	}, nil
+}
+
+// parseFunc parses a builtin, with its arguments if it takes any
+func (p *parser) parseFunc(name token) (Query, error) {
+	var args []Query
+	if p.at(tokPunct, "(") && p.peek().glued {
+		p.next()
+		for {
+			arg, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(tokPunct, ")") {
+				break
+			}
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	b, ok := builtins[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown or unsupported function %q at offset %d", name.text, name.pos)
+	}
+	if len(args) != b.args {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", name.text, b.args, len(args))
+	}
+
+	return b.build(args), nil
+}
+
+func identity(v any) ([]any, error) { return []any{v}, nil }
+
+func constant(x any) Query {
+	return func(any) ([]any, error) { return []any{x}, nil }
+}
+
+func indexQuery(field string) Query {
+	return func(v any) ([]any, error) {
+		x, err := index(v, field)
+		if err != nil {
+			return nil, err
+		}
+		return []any{x}, nil
+	}
+}
+
+// index looks up a field of an object or an element of an array. Missing things are null.
+func index(v any, key any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t := v.(type) {
+	case *Object:
+		k, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot index object with %s", typeName(key))
+		}
+		return t.Vals[k], nil
+
+	case []any:
+		n, ok := key.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("cannot index array with %s", typeName(key))
+		}
+		f, _ := n.Float64()
+		i := int(math.Floor(f))
+		if i < 0 {
+			i += len(t)
+		}
+		if i < 0 || i >= len(t) {
+			return nil, nil
+		}
+		return t[i], nil
+	}
+
+	return nil, fmt.Errorf("cannot index %s with %s", typeName(v), typeName(key))
+}
+
+func iterate(v any) ([]any, error) {
+	switch t := v.(type) {
+	case []any:
+		return t, nil
+	case *Object:
+		ret := make([]any, len(t.Keys))
+		for i, k := range t.Keys {
+			ret[i] = t.Vals[k]
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", typeName(v))
+}
+
+func sliceQuery(from, to Query) Query {
+	return func(v any) ([]any, error) {
+		bound := func(q Query, def int, length int) (int, error) {
+			if q == nil {
+				return def, nil
+			}
+			outs, err := q(v)
+			if err != nil || len(outs) != 1 {
+				return 0, fmt.Errorf("slice bounds must be a single number")
+			}
+			f, ok := toFloat(outs[0])
+			if !ok {
+				return 0, fmt.Errorf("slice bounds must be numbers")
+			}
+			i := int(f)
+			if i < 0 {
+				i += length
+			}
+			return max(0, min(i, length)), nil
+		}
+
+		var length int
+		switch t := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case []any:
+			length = len(t)
+		case string:
+			length = len([]rune(t))
+		default:
+			return nil, fmt.Errorf("cannot slice %s", typeName(v))
+		}
+
+		lo, err := bound(from, 0, length)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := bound(to, length, length)
+		if err != nil {
+			return nil, err
+		}
+		hi = max(hi, lo)
+
+		if s, ok := v.(string); ok {
+			return []any{string([]rune(s)[lo:hi])}, nil
+		}
+		return []any{slices.Clone(v.([]any)[lo:hi])}, nil
+	}
+}
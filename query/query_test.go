@@ -0,0 +1,141 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+const testInput = `[
+ {"server": "pi", "ok": true, "data": {"version": "v0.107.52", "num_dns_queries": 120, "tags": ["home", "dns"]}},
+ {"server": "nas", "ok": false, "error": "connection refused"},
+ {"server": "vm", "ok": true, "data": {"version": "v0.107.43", "num_dns_queries": 30, "tags": []}}
+]`
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`.`, `[{"server":"pi","ok":true,"data":{"version":"v0.107.52","num_dns_queries":120,"tags":["home","dns"]}},{"server":"nas","ok":false,"error":"connection refused"},{"server":"vm","ok":true,"data":{"version":"v0.107.43","num_dns_queries":30,"tags":[]}}]`},
+		{`.[0].server`, `"pi"`},
+		{`.[-1].server`, `"vm"`},
+		{`.[].server`, "\"pi\"\n\"nas\"\n\"vm\""},
+		{`.[1:].server?`, ``},
+		{`[.[1:][] | .server]`, `["nas","vm"]`},
+		{`map(.server)`, `["pi","nas","vm"]`},
+		{`.[] | select(.ok | not) | .error`, `"connection refused"`},
+		{`[.[] | select(.ok) | .data.num_dns_queries] | add`, `150`},
+		{`map(.data.num_dns_queries // 0) | max`, `120`},
+		{`map(select(.data.num_dns_queries > 50 and .ok)) | length`, `1`},
+		{`.[0] | {server, v: .data.version}`, `{"server":"pi","v":"v0.107.52"}`},
+		{`.[0].data | keys`, `["num_dns_queries","tags","version"]`},
+		{`.[0].data.tags | join(",")`, `"home,dns"`},
+		{`.[0] | has("error"), has("server")`, "false\ntrue"},
+		{`sort_by(.data.num_dns_queries) | map(.server)`, `["nas","vm","pi"]`},
+		{`map(.server | ascii_upcase | test("^[NP]"))`, `[true,true,false]`},
+		{`map(.data.version | startswith("v0.107.5")?) | unique`, `[false,true]`},
+		{`.[0].data.num_dns_queries / 8 - 1`, `14`},
+		{`[.[] | .ok] | map(type)`, `["boolean","boolean","boolean"]`},
+		{`.[0]."server"`, `"pi"`},
+		{`.[] | select(.server == "none")`, ``},
+		{`"x" + (.[0].data.num_dns_queries | tostring)`, `"x120"`},
+		{`.[0].data | to_entries | map(.key)`, `["version","num_dns_queries","tags"]`},
+		{`.[0]["server"]`, `"pi"`},
+		{`map(.server) | first, last, min, max, reverse`, "\"pi\"\n\"vm\"\n\"nas\"\n\"vm\"\n[\"vm\",\"nas\",\"pi\"]"},
+		{`[.[].data.num_dns_queries] | sort, (.[0] % 7), -(.[2])`, "[null,30,120]\n1\n-30"},
+		{`map(.error) | join("/")`, `"/connection refused/"`},
+		{`.[0].data.version | split(".") | map(tonumber? // .)`, `["v0",107,52]`},
+		{`.[1].error | contains("refused"), endswith("used")`, "true\ntrue"},
+		{`{"a b": 1, c: [null, false, "x"], d: empty}`, ``},
+		{`{"a b": 1, c: [null, false, "x"]} | ."a b", (.c | length)`, "1\n3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			tree, err := Parse([]byte(testInput))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			q, err := Compile(tt.query)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.query, err)
+			}
+			got, err := q.Run(tree)
+			if err != nil {
+				t.Fatalf("Run(%q): %v", tt.query, err)
+			}
+			lines := make([]string, len(got))
+			for i, v := range got {
+				lines[i] = compactJSON(v)
+			}
+			if s := strings.Join(lines, "\n"); s != tt.want {
+				t.Errorf("query %q = %s, want %s", tt.query, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_errors(t *testing.T) {
+	for _, q := range []string{`.[`, `.foo |`, `nope`, `map`, `select(.a; .b)`, `"open`, `.a ==`, `{1: 2}`, `.a )`} {
+		if _, err := Compile(q); err == nil {
+			t.Errorf("Compile(%q) should have failed", q)
+		}
+	}
+}
+
+func TestCompile_unsupported(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`..`, "recursion (..) is not supported"},
+		{`.[] | ..`, "recursion (..) is not supported"},
+		{`.[] as $x | $x`, "variables are not supported"},
+		{`$ENV`, "variables are not supported"},
+		{`if .ok then 1 else 2 end`, "if/then/else is not supported"},
+		{`map(if .ok then 1 end)`, "if/then/else is not supported"},
+		{`reduce .[] as $x (0; . + $x)`, "variables are not supported"},
+		{`reduce .[] (0; .)`, "reduce is not supported"},
+		{`foreach .[] (0; .)`, "foreach is not supported"},
+		{`try .a catch "x"`, "try/catch is not supported"},
+		{`.a try`, "try/catch is not supported"},
+		{`def f: .; f`, "def is not supported"},
+		{`"x\(.a)"`, "string interpolation is not supported"},
+		{`@csv`, "@formats are not supported"},
+		{`paths`, `unknown or unsupported function "paths"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Compile(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile(%q) = %v, want an error with %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tree, err := Parse([]byte(`{"b": 1, "a": {"y": [true, null], "x": "s"}, "b": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// key order is kept, and a repeated key keeps its first place and last value
+	want := `{"b":2,"a":{"y":[true,null],"x":"s"}}`
+	if got := compactJSON(tree); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	tree, err = FromValue(struct {
+		Z int    `json:"z"`
+		A string `json:"a"`
+	}{1, "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := compactJSON(tree); got != `{"z":1,"a":"x"}` {
+		t.Errorf("got %s", got)
+	}
+}
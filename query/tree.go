@@ -0,0 +1,114 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Object is a decoded JSON object that remembers its key order,
+// so what comes out of a query is in the same order as what went in
+type Object struct {
+	Keys []string
+	Vals map[string]any
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.Keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(o.Vals[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// FromValue turns v into *Object, []any, string, json.Number, bool and nil by way of its JSON encoding
+func FromValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes JSON into the same shapes as FromValue
+func Parse(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decode(dec)
+}
+
+func decode(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		o := &Object{Vals: make(map[string]any)}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+
+			val, err := decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := o.Vals[key]; !dup {
+				o.Keys = append(o.Keys, key)
+			}
+			o.Vals[key] = val
+		}
+		_, err = dec.Token() // '}'
+		return o, err
+
+	case '[':
+		arr := []any{}
+		for dec.More() {
+			val, err := decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err = dec.Token() // ']'
+		return arr, err
+	}
+
+	return nil, fmt.Errorf("unexpected %v in JSON", delim)
+}
+
+// compactJSON encodes v as compact JSON without escaping <, > and &
+func compactJSON(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}